@@ -543,7 +543,7 @@ export function useThemes() {
 
     if (!response.ok) {
       const result = await response.json();
-      throw new Error(result.error || 'Failed to save theme to server');
+      throw new Error(result.error?.message || 'Failed to save theme to server');
     }
   };
 
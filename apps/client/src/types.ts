@@ -18,6 +18,10 @@ export interface FilterOptions {
 export interface WebSocketMessage {
   type: 'initial' | 'event';
   data: HookEvent | HookEvent[];
+  // Per-connection frame counter; a gap means frames were dropped
+  seq?: number;
+  // Server send time in milliseconds
+  timestamp?: number;
 }
 
 export type TimeRange = '1m' | '3m' | '5m';
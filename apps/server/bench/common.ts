@@ -0,0 +1,36 @@
+import { Database } from 'bun:sqlite';
+import { runMigrations } from '../src/migrations';
+
+// A fresh, migrated in-memory database
+export function migratedDatabase(): Database {
+  const db = new Database(':memory:');
+  runMigrations(db);
+  return db;
+}
+
+// Store count events spread over sourceApps apps and sessions sessions, one
+// second apart, in a single transaction
+export function seedEvents(db: Database, count: number, sourceApps: number, sessions: number): void {
+  const insert = db.prepare('INSERT INTO events (source_app, session_id, hook_event_type, payload, timestamp) VALUES (?, ?, ?, ?, ?)');
+  const start = Date.now() - count * 1000;
+  db.transaction(() => {
+    for (let i = 0; i < count; i++) {
+      insert.run(`app-${i % sourceApps}`, `session-${i % sessions}`, i % 2 ? 'PreToolUse' : 'PostToolUse', '{"tool_name":"Bash"}', start + i * 1000);
+    }
+  })();
+}
+
+// Run fn iterations times and print the median and total wall time
+export function measure(label: string, iterations: number, fn: () => void): number {
+  const samples: number[] = [];
+  for (let i = 0; i < iterations; i++) {
+    const started = performance.now();
+    fn();
+    samples.push(performance.now() - started);
+  }
+  samples.sort((a, b) => a - b);
+  const median = samples[Math.floor(samples.length / 2)]!;
+  const total = samples.reduce((sum, sample) => sum + sample, 0);
+  console.log(`${label.padEnd(40)} median ${median.toFixed(3)}ms  total ${total.toFixed(1)}ms`);
+  return median;
+}
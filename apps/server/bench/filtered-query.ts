@@ -0,0 +1,44 @@
+import { measure, migratedDatabase, seedEvents } from './common';
+
+// Filtered listings with and without the composite (source_app, timestamp)
+// and (session_id, timestamp) indexes, over 100k events.
+// Run with: bun bench/filtered-query.ts
+
+const EVENTS = 100_000;
+const ITERATIONS = 200;
+
+const SOURCE_APPS = 20;
+const SESSIONS = 500;
+
+// The listing queries the composite indexes are meant to serve
+const queries = [
+  {
+    label: 'source_app, newest first',
+    sql: 'SELECT id FROM events WHERE is_deleted = 0 AND source_app = ? ORDER BY timestamp DESC LIMIT 50',
+    value: (n: number) => `app-${n % SOURCE_APPS}`
+  },
+  {
+    label: 'session_id, oldest first',
+    sql: 'SELECT id FROM events WHERE is_deleted = 0 AND session_id = ? ORDER BY timestamp ASC, id ASC LIMIT 50',
+    value: (n: number) => `session-${n % SESSIONS}`
+  }
+];
+
+const db = migratedDatabase();
+seedEvents(db, EVENTS, SOURCE_APPS, SESSIONS);
+
+for (const composite of [true, false]) {
+  if (!composite) {
+    db.exec('DROP INDEX idx_source_app_timestamp');
+    db.exec('DROP INDEX idx_session_id_timestamp');
+  }
+  console.log(composite ? 'With composite indexes' : 'Without composite indexes');
+  
+  for (const query of queries) {
+    const stmt = db.prepare(query.sql);
+    let n = 0;
+    measure(`  ${query.label}`, ITERATIONS, () => {
+      stmt.all(query.value(n++));
+    });
+  }
+}
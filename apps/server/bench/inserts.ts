@@ -0,0 +1,27 @@
+import { INSERT_EVENT_SQL } from '../src/db';
+import { measure, migratedDatabase } from './common';
+
+// Event inserts through the statement prepared once in initDatabase versus
+// preparing the same SQL for every insert, over 10k events.
+// Run with: bun bench/inserts.ts
+
+const EVENTS = 10_000;
+const ROUNDS = 5;
+
+function eventRow(i: number): (string | number | null)[] {
+  const payload = JSON.stringify({ tool_name: 'Bash', tool_input: { command: `echo ${i}` } });
+  return [`app-${i % 20}`, `session-${i % 500}`, 'PreToolUse', payload, 0, null, null, null, Date.now(), null, 1];
+}
+
+const rows = Array.from({ length: EVENTS }, (_, i) => eventRow(i));
+
+const prepared = migratedDatabase();
+const insert = prepared.prepare(INSERT_EVENT_SQL);
+measure(`prepared once, ${EVENTS} inserts`, ROUNDS, () => {
+  for (const row of rows) insert.run(...row);
+});
+
+const adHoc = migratedDatabase();
+measure(`prepared per insert, ${EVENTS} inserts`, ROUNDS, () => {
+  for (const row of rows) adHoc.prepare(INSERT_EVENT_SQL).run(...row);
+});
@@ -0,0 +1,134 @@
+import { createHmac, timingSafeEqual } from 'node:crypto';
+import { config } from './config';
+
+export type AuthResult =
+  | { ok: true; subject: string }
+  | { ok: false; error: string };
+
+function base64UrlDecode(segment: string): Buffer {
+  return Buffer.from(segment.replace(/-/g, '+').replace(/_/g, '/'), 'base64');
+}
+
+// Verify an HS256-signed JWT and return its subject claim
+export function verifyJwt(token: string, secret: string): AuthResult {
+  const parts = token.split('.');
+  if (parts.length !== 3) {
+    return { ok: false, error: 'Malformed token' };
+  }
+  const [headerPart, payloadPart, signaturePart] = parts as [string, string, string];
+  
+  let header: any;
+  let claims: any;
+  try {
+    header = JSON.parse(base64UrlDecode(headerPart).toString());
+    claims = JSON.parse(base64UrlDecode(payloadPart).toString());
+  } catch (error) {
+    return { ok: false, error: 'Malformed token' };
+  }
+  
+  if (header.alg !== 'HS256') {
+    return { ok: false, error: 'Unsupported token algorithm' };
+  }
+  
+  const expected = createHmac('sha256', secret).update(`${headerPart}.${payloadPart}`).digest();
+  const actual = base64UrlDecode(signaturePart);
+  if (expected.length !== actual.length || !timingSafeEqual(expected, actual)) {
+    return { ok: false, error: 'Invalid token signature' };
+  }
+  
+  const now = Math.floor(Date.now() / 1000);
+  if (typeof claims.exp === 'number' && claims.exp <= now) {
+    return { ok: false, error: 'Token expired' };
+  }
+  if (typeof claims.nbf === 'number' && claims.nbf > now) {
+    return { ok: false, error: 'Token not yet valid' };
+  }
+  if (typeof claims.sub !== 'string' || !claims.sub) {
+    return { ok: false, error: 'Token missing subject' };
+  }
+  
+  return { ok: true, subject: claims.sub };
+}
+
+// Authenticate a request with its Bearer token. Returns null when JWT auth
+// is not configured, so callers can keep the unauthenticated behaviour.
+export function authenticateRequest(req: Request): AuthResult | null {
+  if (!config.JWT_SECRET) return null;
+  
+  const authorization = req.headers.get('authorization');
+  if (!authorization || !authorization.startsWith('Bearer ')) {
+    return { ok: false, error: 'Missing bearer token' };
+  }
+  
+  return verifyJwt(authorization.slice('Bearer '.length).trim(), config.JWT_SECRET);
+}
+
+// Subprotocol clients offer alongside the token, e.g.
+// new WebSocket(url, ['bearer', token]); echoed back on a successful upgrade
+export const BEARER_SUBPROTOCOL = 'bearer';
+
+// The credential a streaming client presented. Browsers cannot set headers
+// on these, so besides the Authorization and X-API-Key headers it may come
+// from the ?token= query parameter or the Sec-WebSocket-Protocol list.
+function streamCredential(req: Request): string | null {
+  const authorization = req.headers.get('authorization');
+  if (authorization?.startsWith('Bearer ')) {
+    return authorization.slice('Bearer '.length).trim();
+  }
+  
+  const apiKey = req.headers.get('x-api-key');
+  if (apiKey) return apiKey;
+  
+  const queryToken = new URL(req.url).searchParams.get('token');
+  if (queryToken) return queryToken;
+  
+  const protocols = (req.headers.get('sec-websocket-protocol') || '').split(',').map(p => p.trim());
+  const bearerIndex = protocols.indexOf(BEARER_SUBPROTOCOL);
+  return bearerIndex !== -1 && protocols[bearerIndex + 1] ? protocols[bearerIndex + 1]! : null;
+}
+
+function isApiKey(presented: string): boolean {
+  if (!config.API_KEY) return false;
+  const expected = Buffer.from(config.API_KEY);
+  const actual = Buffer.from(presented);
+  return expected.length === actual.length && timingSafeEqual(expected, actual);
+}
+
+// Authenticate a streaming connection (WebSocket upgrade or SSE) with a JWT
+// or with API_KEY, whichever is configured; the key authenticates as admin.
+// Returns null only when neither is configured.
+export function authenticateStream(req: Request): AuthResult | null {
+  if (!config.JWT_SECRET && !config.API_KEY) return null;
+  
+  const presented = streamCredential(req);
+  if (!presented) {
+    return { ok: false, error: config.JWT_SECRET ? 'Missing bearer token' : 'Missing API key' };
+  }
+  if (isApiKey(presented)) {
+    return { ok: true, subject: 'admin' };
+  }
+  
+  return config.JWT_SECRET ? verifyJwt(presented, config.JWT_SECRET) : { ok: false, error: 'Invalid API key' };
+}
+
+// Authenticate an admin-only request with API_KEY, sent as X-API-Key or as
+// a Bearer token. Unlike authenticateRequest this never returns null: admin
+// operations stay closed until API_KEY is configured.
+export function authenticateAdmin(req: Request): AuthResult {
+  if (!config.API_KEY) {
+    return { ok: false, error: 'Admin endpoints are disabled; set API_KEY to enable them' };
+  }
+  
+  const authorization = req.headers.get('authorization');
+  const presented = req.headers.get('x-api-key')
+    ?? (authorization?.startsWith('Bearer ') ? authorization.slice('Bearer '.length).trim() : null);
+  if (!presented) {
+    return { ok: false, error: 'Missing API key' };
+  }
+  
+  if (!isApiKey(presented)) {
+    return { ok: false, error: 'Invalid API key' };
+  }
+  
+  return { ok: true, subject: 'admin' };
+}
@@ -0,0 +1,56 @@
+export type BreakerState = 'closed' | 'open' | 'half_open';
+
+// Consecutive-failure circuit breaker. Closed lets every call through; after
+// failureThreshold consecutive failures it opens and rejects calls until
+// cooldownMs has passed, then half-opens to let a single trial call through.
+// The trial's outcome closes the breaker again or re-opens it.
+export class CircuitBreaker {
+  private failures = 0;
+  private openedAt = 0;
+  private trialInFlight = false;
+  private current: BreakerState = 'closed';
+  
+  constructor(
+    private readonly failureThreshold: number,
+    private readonly cooldownMs: number,
+    private readonly onStateChange?: (state: BreakerState) => void
+  ) {}
+  
+  get state(): BreakerState {
+    return this.current;
+  }
+  
+  // Whether a call may proceed now. In half-open state only the first caller
+  // gets through until it reports its outcome.
+  allowRequest(now: number = Date.now()): boolean {
+    if (this.current === 'open' && now - this.openedAt >= this.cooldownMs) {
+      this.transition('half_open');
+    }
+    if (this.current === 'closed') return true;
+    if (this.current === 'half_open' && !this.trialInFlight) {
+      this.trialInFlight = true;
+      return true;
+    }
+    return false;
+  }
+  
+  recordSuccess(): void {
+    this.failures = 0;
+    this.trialInFlight = false;
+    if (this.current !== 'closed') this.transition('closed');
+  }
+  
+  recordFailure(now: number = Date.now()): void {
+    this.failures++;
+    this.trialInFlight = false;
+    if (this.current === 'half_open' || this.failures >= this.failureThreshold) {
+      this.openedAt = now;
+      if (this.current !== 'open') this.transition('open');
+    }
+  }
+  
+  private transition(state: BreakerState): void {
+    this.current = state;
+    this.onStateChange?.(state);
+  }
+}
@@ -0,0 +1,34 @@
+import { config } from './config';
+
+interface CacheEntry {
+  value: any;
+  expiresAt: number;
+}
+
+const entries = new Map<string, CacheEntry>();
+
+// Return the cached value for key, computing and storing it on a miss.
+// The boolean reports whether the value came from the cache.
+export async function cached<T>(key: string, compute: () => T | Promise<T>): Promise<[T, boolean]> {
+  const ttl = config.READ_CACHE_TTL_MS;
+  const entry = entries.get(key);
+  
+  if (ttl > 0 && entry && entry.expiresAt > Date.now()) {
+    return [entry.value as T, true];
+  }
+  
+  const value = await compute();
+  if (ttl > 0) {
+    entries.set(key, { value, expiresAt: Date.now() + ttl });
+  }
+  return [value, false];
+}
+
+// Drop cached entries whose key starts with the given prefix
+export function invalidateCache(prefix: string): void {
+  for (const key of entries.keys()) {
+    if (key.startsWith(prefix)) {
+      entries.delete(key);
+    }
+  }
+}
@@ -0,0 +1,39 @@
+import { config } from './config';
+
+// Content types worth compressing; streamed exports (text/csv) are left alone
+const COMPRESSIBLE_TYPES = ['application/json', 'application/msgpack', 'text/plain'];
+
+function acceptsGzip(req: Request): boolean {
+  const acceptEncoding = req.headers.get('accept-encoding') || '';
+  return acceptEncoding
+    .split(',')
+    .some(part => {
+      const [encoding, ...params] = part.trim().split(';');
+      if (encoding.trim().toLowerCase() !== 'gzip') return false;
+      const q = params.find(p => p.trim().startsWith('q='));
+      return !q || parseFloat(q.trim().slice(2)) > 0;
+    });
+}
+
+// Gzip a buffered response body when the client accepts it and the body is
+// at least COMPRESSION_MIN_BYTES long
+export async function compressResponse(req: Request, response: Response): Promise<Response> {
+  if (config.COMPRESSION_MIN_BYTES === 0 || !acceptsGzip(req)) return response;
+  if (response.headers.has('Content-Encoding') || response.status === 204 || response.status === 304) return response;
+  
+  const contentType = response.headers.get('Content-Type') || '';
+  if (!COMPRESSIBLE_TYPES.some(type => contentType.startsWith(type))) return response;
+  
+  const body = new Uint8Array(await response.arrayBuffer());
+  const headers = new Headers(response.headers);
+  headers.append('Vary', 'Accept-Encoding');
+  
+  if (body.byteLength < config.COMPRESSION_MIN_BYTES) {
+    return new Response(body, { status: response.status, statusText: response.statusText, headers });
+  }
+  
+  const compressed = Bun.gzipSync(body);
+  headers.set('Content-Encoding', 'gzip');
+  headers.set('Content-Length', String(compressed.byteLength));
+  return new Response(compressed, { status: response.status, statusText: response.statusText, headers });
+}
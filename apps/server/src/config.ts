@@ -3,16 +3,36 @@ import { z } from 'zod';
 // Define the configuration schema
 const configSchema = z.object({
   // Server configuration
-  PORT: z.coerce.number().min(1).max(65535).default(4000),
+  PORT: z.coerce.number().min(0).max(65535).default(4000), // 0 binds any free port
   
   // Database configuration
   DATABASE_PATH: z.string().min(1).default('events.db'),
+  DB_BUSY_TIMEOUT_MS: z.coerce.number().min(0).default(5000),
+  EVENT_TTL_HOURS: z.coerce.number().min(0).default(0), // 0 = events never expire
+  COMPRESS_PAYLOADS: z.enum(['true', 'false']).default('false').transform((val) => val === 'true'),
+  DB_BUSY_RETRIES: z.coerce.number().min(0).default(3),
+  DB_BUSY_BACKOFF_MS: z.coerce.number().min(1).default(50),
   
   // CORS configuration
   CORS_ORIGINS: z
     .string()
     .default('*')
     .transform((val) => val === '*' ? ['*'] : val.split(',').map(s => s.trim())),
+  CORS_ALLOW_METHODS: z
+    .string()
+    .default('GET, POST, PUT, PATCH, DELETE, OPTIONS')
+    .transform((val) => val.split(',').map(s => s.trim().toUpperCase()).filter(Boolean)),
+  CORS_ALLOW_HEADERS: z
+    .string()
+    .default('Content-Type, Authorization, X-Request-ID, If-None-Match, If-Modified-Since')
+    .transform((val) => val.split(',').map(s => s.trim()).filter(Boolean)),
+  CORS_MAX_AGE_SECONDS: z.coerce.number().min(0).default(0), // 0 = no Access-Control-Max-Age
+  
+  // Proxies allowed to set X-Forwarded-For (IPs or IPv4 CIDRs); empty = none
+  TRUSTED_PROXIES: z
+    .string()
+    .default('')
+    .transform((val) => val.split(',').map(s => s.trim()).filter(Boolean)),
   
   // Optional: Future PostgreSQL support
   POSTGRES_URL: z.string().optional(),
@@ -32,12 +52,107 @@ const configSchema = z.object({
   // Optional: Rate limiting
   RATE_LIMIT_WINDOW_MS: z.coerce.number().default(900000), // 15 minutes
   RATE_LIMIT_MAX_REQUESTS: z.coerce.number().default(100),
+  PER_SOURCE_RATE_LIMIT: z.coerce.number().min(0).default(0), // 0 = disabled
+  
+  // Optional: Keep 1 in N events per "source_app" or "source_app:hook_event_type"
+  SAMPLE_RATE: z
+    .string()
+    .default('')
+    .transform((val) => val.split(',').map(s => s.trim()).filter(Boolean).map(rule => {
+      const separator = rule.lastIndexOf('=');
+      return [rule.slice(0, separator).trim(), Number(rule.slice(separator + 1))] as const;
+    }))
+    .pipe(z.array(z.tuple([z.string().min(1), z.number().int().min(1)])))
+    .transform((rules) => Object.fromEntries(rules) as Record<string, number>),
   
   // Optional: WebSocket configuration
   WS_HEARTBEAT_INTERVAL: z.coerce.number().default(30000), // 30 seconds
+  WS_STATS_INTERVAL_MS: z.coerce.number().min(0).default(0), // 0 = disabled
+  WS_BACKPRESSURE_LIMIT_BYTES: z.coerce.number().min(1).default(1048576), // 1 MB per client
+  WS_SLOW_CLIENT_POLICY: z.enum(['disconnect', 'drop']).default('disconnect'),
+  WS_PAYLOAD_PREVIEW_BYTES: z.coerce.number().min(0).default(0), // 0 = send full payloads
+  WS_MAX_MESSAGE_BYTES: z.coerce.number().min(128).default(65536), // inbound frames
+  WS_RESUME_BUFFER_SIZE: z.coerce.number().int().min(0).default(100), // 0 = no resume
+  WS_RESUME_RETENTION_MS: z.coerce.number().min(0).default(60000), // 1 minute
+  
+  // Optional: Buffered (async) event ingestion
+  INGEST_BUFFER_ENABLED: z.enum(['true', 'false']).default('false').transform((val) => val === 'true'),
+  INGEST_BATCH_SIZE: z.coerce.number().min(1).default(100),
+  INGEST_FLUSH_INTERVAL_MS: z.coerce.number().min(1).default(250),
+  INGEST_QUEUE_MAX: z.coerce.number().min(1).default(10000),
+  INGEST_FLUSH_RETRIES: z.coerce.number().int().min(0).default(3),
+  
+  // Optional: Page size for list endpoints
+  DEFAULT_PAGE_SIZE: z.coerce.number().min(1).default(100),
+  MAX_PAGE_SIZE: z.coerce.number().min(1).default(1000),
+  
+  // Optional: Deadline for non-streaming requests
+  REQUEST_TIMEOUT_MS: z.coerce.number().min(0).default(30000), // 0 = disabled
+  
+  // Optional: Upper bound on request body size
+  MAX_BODY_BYTES: z.coerce.number().min(1).default(1048576), // 1 MB
+  
+  // Optional: Cap on an event's serialized payload
+  MAX_PAYLOAD_BYTES: z.coerce.number().min(0).default(0), // 0 = unlimited
+  PAYLOAD_OVERFLOW_POLICY: z.enum(['reject', 'truncate']).default('reject'),
+  
+  // Optional: Payload keys whose values are masked, matched case-insensitively
+  REDACT_KEYS: z.string().default('').transform((val) => val.split(',').map(s => s.trim().toLowerCase()).filter(Boolean)),
+  REDACT_MODE: z.enum(['write', 'read']).default('write'),
+  
+  // Optional: Modules of event processors run before each event is stored
+  EVENT_PROCESSORS: z.string().default('').transform((val) => val.split(',').map(s => s.trim()).filter(Boolean)),
+  
+  // Optional: Minimum body size for gzip-compressed responses
+  COMPRESSION_MIN_BYTES: z.coerce.number().min(0).default(1024), // 0 = disabled
+  
+  // Optional: How ids are generated for themes created without one
+  THEME_ID_STRATEGY: z.enum(['random', 'slug']).default('random'),
+  
+  // Optional: Length of the summary_preview field on events
+  SUMMARY_PREVIEW_CHARS: z.coerce.number().min(0).default(0), // 0 = no previews
+  
+  // Optional: TTL for cached read endpoints (stats, filter options, counts)
+  READ_CACHE_TTL_MS: z.coerce.number().min(0).default(5000), // 0 = disabled
+  
+  // Optional: Upper bound on sessions kept in in-memory caches
+  MAX_TRACKED_SESSIONS: z.coerce.number().min(1).default(10000),
+  
+  // Optional: Time to keep serving after SIGTERM while /readyz reports draining
+  SHUTDOWN_DRAIN_MS: z.coerce.number().min(0).default(0),
+  
+  // Optional: External service that summarizes events saved without a summary
+  SUMMARY_WEBHOOK_URL: z.string().url().optional(),
+  SUMMARY_WEBHOOK_TIMEOUT_MS: z.coerce.number().min(1).default(10000),
+  
+  // Optional: Outbound webhooks that receive every new event
+  EVENT_WEBHOOKS: z
+    .string()
+    .default('')
+    .transform((val) => val.split(',').map(s => s.trim()).filter(Boolean))
+    .pipe(z.array(z.string().url())),
+  EVENT_WEBHOOK_TYPES: z
+    .string()
+    .default('')
+    .transform((val) => val.split(',').map(s => s.trim()).filter(Boolean)), // empty = all types
+  EVENT_WEBHOOK_RETRIES: z.coerce.number().min(0).default(3),
+  EVENT_WEBHOOK_BACKOFF_MS: z.coerce.number().min(1).default(500),
+  EVENT_WEBHOOK_TIMEOUT_MS: z.coerce.number().min(1).default(5000),
+  EVENT_WEBHOOK_BREAKER_THRESHOLD: z.coerce.number().min(1).default(5),
+  EVENT_WEBHOOK_BREAKER_COOLDOWN_MS: z.coerce.number().min(0).default(30000),
+  
+  // Optional: Start in read-only maintenance mode (writes get 503)
+  READ_ONLY: z.enum(['true', 'false']).default('false').transform((val) => val === 'true'),
   
   // Optional: Logging level
   LOG_LEVEL: z.enum(['error', 'warn', 'info', 'debug']).default('info'),
+  LOG_FORMAT: z.enum(['text', 'json']).default('text'),
+  LOG_FILE: z.string().optional(), // empty = stdout/stderr
+  LOG_FILE_MAX_BYTES: z.coerce.number().min(1024).default(10485760), // 10 MB
+  LOG_FILE_MAX_FILES: z.coerce.number().min(1).default(5),
+  
+  // Optional: Expose /debug/pprof runtime introspection endpoints
+  ENABLE_PPROF: z.enum(['true', 'false']).default('false').transform((val) => val === 'true'),
   
   // Environment
   NODE_ENV: z.enum(['development', 'production', 'test']).default('development')
@@ -52,7 +167,16 @@ function loadConfig(): Config {
     const config = configSchema.parse({
       PORT: process.env.PORT,
       DATABASE_PATH: process.env.DATABASE_PATH,
+      DB_BUSY_TIMEOUT_MS: process.env.DB_BUSY_TIMEOUT_MS,
+      EVENT_TTL_HOURS: process.env.EVENT_TTL_HOURS,
+      COMPRESS_PAYLOADS: process.env.COMPRESS_PAYLOADS,
+      DB_BUSY_RETRIES: process.env.DB_BUSY_RETRIES,
+      DB_BUSY_BACKOFF_MS: process.env.DB_BUSY_BACKOFF_MS,
       CORS_ORIGINS: process.env.CORS_ORIGINS,
+      CORS_ALLOW_METHODS: process.env.CORS_ALLOW_METHODS || undefined,
+      CORS_ALLOW_HEADERS: process.env.CORS_ALLOW_HEADERS || undefined,
+      CORS_MAX_AGE_SECONDS: process.env.CORS_MAX_AGE_SECONDS,
+      TRUSTED_PROXIES: process.env.TRUSTED_PROXIES,
       POSTGRES_URL: process.env.POSTGRES_URL,
       DATABASE_URL: process.env.DATABASE_URL,
       DB_PASSWORD: process.env.DB_PASSWORD,
@@ -64,8 +188,52 @@ function loadConfig(): Config {
       JWT_SECRET: process.env.JWT_SECRET,
       RATE_LIMIT_WINDOW_MS: process.env.RATE_LIMIT_WINDOW_MS,
       RATE_LIMIT_MAX_REQUESTS: process.env.RATE_LIMIT_MAX_REQUESTS,
+      PER_SOURCE_RATE_LIMIT: process.env.PER_SOURCE_RATE_LIMIT,
+      SAMPLE_RATE: process.env.SAMPLE_RATE,
       WS_HEARTBEAT_INTERVAL: process.env.WS_HEARTBEAT_INTERVAL,
+      WS_STATS_INTERVAL_MS: process.env.WS_STATS_INTERVAL_MS,
+      WS_BACKPRESSURE_LIMIT_BYTES: process.env.WS_BACKPRESSURE_LIMIT_BYTES,
+      WS_SLOW_CLIENT_POLICY: process.env.WS_SLOW_CLIENT_POLICY,
+      WS_PAYLOAD_PREVIEW_BYTES: process.env.WS_PAYLOAD_PREVIEW_BYTES,
+      WS_MAX_MESSAGE_BYTES: process.env.WS_MAX_MESSAGE_BYTES,
+      WS_RESUME_BUFFER_SIZE: process.env.WS_RESUME_BUFFER_SIZE,
+      WS_RESUME_RETENTION_MS: process.env.WS_RESUME_RETENTION_MS,
+      INGEST_BUFFER_ENABLED: process.env.INGEST_BUFFER_ENABLED,
+      INGEST_BATCH_SIZE: process.env.INGEST_BATCH_SIZE,
+      INGEST_FLUSH_INTERVAL_MS: process.env.INGEST_FLUSH_INTERVAL_MS,
+      INGEST_QUEUE_MAX: process.env.INGEST_QUEUE_MAX,
+      INGEST_FLUSH_RETRIES: process.env.INGEST_FLUSH_RETRIES,
+      DEFAULT_PAGE_SIZE: process.env.DEFAULT_PAGE_SIZE,
+      MAX_PAGE_SIZE: process.env.MAX_PAGE_SIZE,
+      REQUEST_TIMEOUT_MS: process.env.REQUEST_TIMEOUT_MS,
+      MAX_BODY_BYTES: process.env.MAX_BODY_BYTES,
+      MAX_PAYLOAD_BYTES: process.env.MAX_PAYLOAD_BYTES,
+      PAYLOAD_OVERFLOW_POLICY: process.env.PAYLOAD_OVERFLOW_POLICY,
+      REDACT_KEYS: process.env.REDACT_KEYS,
+      REDACT_MODE: process.env.REDACT_MODE,
+      EVENT_PROCESSORS: process.env.EVENT_PROCESSORS,
+      COMPRESSION_MIN_BYTES: process.env.COMPRESSION_MIN_BYTES,
+      THEME_ID_STRATEGY: process.env.THEME_ID_STRATEGY,
+      SUMMARY_PREVIEW_CHARS: process.env.SUMMARY_PREVIEW_CHARS,
+      READ_CACHE_TTL_MS: process.env.READ_CACHE_TTL_MS,
+      MAX_TRACKED_SESSIONS: process.env.MAX_TRACKED_SESSIONS,
+      SHUTDOWN_DRAIN_MS: process.env.SHUTDOWN_DRAIN_MS,
+      SUMMARY_WEBHOOK_URL: process.env.SUMMARY_WEBHOOK_URL || undefined,
+      SUMMARY_WEBHOOK_TIMEOUT_MS: process.env.SUMMARY_WEBHOOK_TIMEOUT_MS,
+      EVENT_WEBHOOKS: process.env.EVENT_WEBHOOKS,
+      EVENT_WEBHOOK_TYPES: process.env.EVENT_WEBHOOK_TYPES,
+      EVENT_WEBHOOK_RETRIES: process.env.EVENT_WEBHOOK_RETRIES,
+      EVENT_WEBHOOK_BACKOFF_MS: process.env.EVENT_WEBHOOK_BACKOFF_MS,
+      EVENT_WEBHOOK_TIMEOUT_MS: process.env.EVENT_WEBHOOK_TIMEOUT_MS,
+      EVENT_WEBHOOK_BREAKER_THRESHOLD: process.env.EVENT_WEBHOOK_BREAKER_THRESHOLD,
+      EVENT_WEBHOOK_BREAKER_COOLDOWN_MS: process.env.EVENT_WEBHOOK_BREAKER_COOLDOWN_MS,
+      READ_ONLY: process.env.READ_ONLY,
       LOG_LEVEL: process.env.LOG_LEVEL,
+      LOG_FORMAT: process.env.LOG_FORMAT,
+      LOG_FILE: process.env.LOG_FILE || undefined,
+      LOG_FILE_MAX_BYTES: process.env.LOG_FILE_MAX_BYTES,
+      LOG_FILE_MAX_FILES: process.env.LOG_FILE_MAX_FILES,
+      ENABLE_PPROF: process.env.ENABLE_PPROF,
       NODE_ENV: process.env.NODE_ENV
     });
     
@@ -104,6 +272,23 @@ export function validateRequiredConfig(): void {
   console.log('✅ Configuration loaded successfully');
   console.log(`📦 Environment: ${config.NODE_ENV}`);
   console.log(`🚀 Server will run on port: ${config.PORT}`);
-  console.log(`💾 Database path: ${config.DATABASE_PATH}`);
+  console.log(`💾 Database path: ${config.DATABASE_PATH}${config.DATABASE_PATH === ':memory:' ? ' (in-memory, not persisted)' : ''}`);
   console.log(`🌐 CORS origins: ${Array.isArray(config.CORS_ORIGINS) ? config.CORS_ORIGINS.join(', ') : config.CORS_ORIGINS}`);
 }
+
+// Settings safe to expose to clients via GET /config. Never add secrets,
+// credentials or filesystem paths here.
+export function publicConfig(): Record<string, unknown> {
+  return {
+    defaultPageSize: Math.min(config.DEFAULT_PAGE_SIZE, config.MAX_PAGE_SIZE),
+    maxPageSize: config.MAX_PAGE_SIZE,
+    wsHeartbeatIntervalMs: config.WS_HEARTBEAT_INTERVAL,
+    wsStatsIntervalMs: config.WS_STATS_INTERVAL_MS,
+    wsPayloadPreviewBytes: config.WS_PAYLOAD_PREVIEW_BYTES,
+    maxBodyBytes: config.MAX_BODY_BYTES,
+    maxPayloadBytes: config.MAX_PAYLOAD_BYTES,
+    payloadOverflowPolicy: config.PAYLOAD_OVERFLOW_POLICY,
+    ingestBuffered: config.INGEST_BUFFER_ENABLED,
+    authEnabled: Boolean(config.JWT_SECRET)
+  };
+}
@@ -0,0 +1,32 @@
+import { config } from './config';
+
+// Build CORS response headers for a request. A wildcard policy answers with a
+// literal "*" and never allows credentials; listed origins are echoed back
+// with credentials allowed. Unlisted origins get no Allow-Origin header.
+export function corsHeaders(req: Request): Record<string, string> {
+  const allowedOrigins = config.CORS_ORIGINS;
+  const requestOrigin = req.headers.get('origin');
+  
+  const headers: Record<string, string> = {
+    'Access-Control-Allow-Methods': config.CORS_ALLOW_METHODS.join(', '),
+    'Access-Control-Allow-Headers': config.CORS_ALLOW_HEADERS.join(', '),
+    'Access-Control-Expose-Headers': 'X-Request-ID, Idempotent-Replayed, ETag, Last-Modified',
+  };
+  
+  // Lets browsers cache the preflight result
+  if (req.method === 'OPTIONS' && config.CORS_MAX_AGE_SECONDS > 0) {
+    headers['Access-Control-Max-Age'] = String(config.CORS_MAX_AGE_SECONDS);
+  }
+  
+  if (allowedOrigins.includes('*')) {
+    headers['Access-Control-Allow-Origin'] = '*';
+  } else {
+    headers['Vary'] = 'Origin';
+    if (requestOrigin && allowedOrigins.includes(requestOrigin)) {
+      headers['Access-Control-Allow-Origin'] = requestOrigin;
+      headers['Access-Control-Allow-Credentials'] = 'true';
+    }
+  }
+  
+  return headers;
+}
@@ -0,0 +1,53 @@
+import type { HookEvent } from './types';
+
+export const EVENT_CSV_COLUMNS = ['id', 'source_app', 'session_id', 'hook_event_type', 'summary', 'timestamp', 'payload'];
+
+// Quote a field per RFC 4180 when it contains a delimiter, quote or newline
+function escapeField(value: unknown): string {
+  if (value === undefined || value === null) return '';
+  const text = String(value);
+  return /[",\r\n]/.test(text) ? `"${text.replace(/"/g, '""')}"` : text;
+}
+
+export function toCsvRow(fields: unknown[]): string {
+  return fields.map(escapeField).join(',') + '\r\n';
+}
+
+export function eventToCsvRow(event: HookEvent): string {
+  return toCsvRow([
+    event.id,
+    event.source_app,
+    event.session_id,
+    event.hook_event_type,
+    event.summary,
+    event.timestamp,
+    JSON.stringify(event.payload)
+  ]);
+}
+
+// Stream events as CSV without buffering the whole export in memory
+export function eventsCsvStream(events: Iterable<HookEvent>): ReadableStream<Uint8Array> {
+  const encoder = new TextEncoder();
+  const iterator = events[Symbol.iterator]();
+  let headerSent = false;
+  
+  return new ReadableStream({
+    pull(controller) {
+      if (!headerSent) {
+        headerSent = true;
+        controller.enqueue(encoder.encode(toCsvRow(EVENT_CSV_COLUMNS)));
+        return;
+      }
+      
+      const next = iterator.next();
+      if (next.done) {
+        controller.close();
+      } else {
+        controller.enqueue(encoder.encode(eventToCsvRow(next.value)));
+      }
+    },
+    cancel() {
+      iterator.return?.();
+    }
+  });
+}
@@ -1,173 +1,684 @@
 import { Database } from 'bun:sqlite';
-import type { HookEvent, FilterOptions, Theme, ThemeSearchQuery } from './types';
+import type { Statement } from 'bun:sqlite';
+import type { HookEvent, InsertEventResult, FilterOptions, FilterOptionsQuery, EventFilter, EventPage, EventStats, DuplicateGroup, TimelineBucket, SessionSummary, TopSession, Theme, ThemeSearchQuery, Author } from './types';
 import { config } from './config';
+import { runMigrations } from './migrations';
+import { getRequestDeadline, getRequestSignal, logger } from './logger';
+import { RequestAbortedError, RequestTimeoutError } from './errors';
+import { redactPayload, summaryPreview } from './event';
 
 let db: Database;
 
-export function initDatabase(): void {
-  db = new Database(config.DATABASE_PATH);
+const EVENT_COLUMNS = 'id, source_app, session_id, hook_event_type, payload, payload_compressed, chat, summary, timestamp, event_uuid, sample_rate';
+
+export const INSERT_EVENT_SQL = `
+  INSERT INTO events (source_app, session_id, hook_event_type, payload, payload_compressed, payload_hash, chat, summary, timestamp, event_uuid, sample_rate)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`;
+
+// Prepared once in initDatabase; InsertEvent is on the ingestion hot path
+let insertEventStmt: Statement;
+// Whether any stored payload is gzipped; set at startup and on insert
+let hasCompressedRows = false;
+
+export const IN_MEMORY_DATABASE = ':memory:';
+
+// Open the database and apply migrations. ':memory:' gives an ephemeral
+// database; bun:sqlite keeps a single connection, so its schema and rows
+// live for as long as the process.
+export function initDatabase(path: string = config.DATABASE_PATH): void {
+  db = new Database(path);
   
-  // Enable WAL mode for better concurrent performance
-  db.exec('PRAGMA journal_mode = WAL');
+  // Enable WAL mode for better concurrent performance (a no-op in memory)
+  if (path !== IN_MEMORY_DATABASE) {
+    db.exec('PRAGMA journal_mode = WAL');
+  }
   db.exec('PRAGMA synchronous = NORMAL');
   
-  // Create events table
-  db.exec(`
-    CREATE TABLE IF NOT EXISTS events (
-      id INTEGER PRIMARY KEY AUTOINCREMENT,
-      source_app TEXT NOT NULL,
-      session_id TEXT NOT NULL,
-      hook_event_type TEXT NOT NULL,
-      payload TEXT NOT NULL,
-      chat TEXT,
-      summary TEXT,
-      timestamp INTEGER NOT NULL
-    )
-  `);
+  // bun:sqlite uses a single connection, so there is no pool to size; wait
+  // on locks held by other processes instead of failing immediately
+  db.exec(`PRAGMA busy_timeout = ${Math.floor(config.DB_BUSY_TIMEOUT_MS)}`);
   
-  // Check if chat column exists, add it if not (for migration)
+  runMigrations(db);
+  hasCompressedRows = db.prepare('SELECT 1 FROM events WHERE payload_compressed = 1 LIMIT 1').get() != null;
+  
+  insertEventStmt = db.prepare(INSERT_EVENT_SQL);
+}
+
+// Ephemeral database for integration tests and throwaway runs
+export function initInMemoryDatabase(): void {
+  initDatabase(IN_MEMORY_DATABASE);
+}
+
+export function closeDatabase(): void {
+  insertEventStmt?.finalize();
+  db?.close();
+}
+
+// Current value of a connection setting, e.g. readPragma('busy_timeout')
+export function readPragma(name: string): unknown {
+  const row = db.prepare(`PRAGMA ${name}`).get() as Record<string, unknown> | null;
+  return row ? Object.values(row)[0] : undefined;
+}
+
+// Verify the database is reachable
+export function pingDatabase(): boolean {
   try {
-    const columns = db.prepare("PRAGMA table_info(events)").all() as any[];
-    const hasChatColumn = columns.some((col: any) => col.name === 'chat');
-    if (!hasChatColumn) {
-      db.exec('ALTER TABLE events ADD COLUMN chat TEXT');
-    }
-    
-    // Check if summary column exists, add it if not (for migration)
-    const hasSummaryColumn = columns.some((col: any) => col.name === 'summary');
-    if (!hasSummaryColumn) {
-      db.exec('ALTER TABLE events ADD COLUMN summary TEXT');
+    db.prepare('SELECT 1').get();
+    return true;
+  } catch (error) {
+    logger.error('Database ping failed:', error);
+    return false;
+  }
+}
+
+function isBusyError(error: unknown): boolean {
+  if (!(error instanceof Error)) return false;
+  const code = (error as { code?: string }).code || '';
+  return code.startsWith('SQLITE_BUSY') || code.startsWith('SQLITE_LOCKED') || /database (table )?is locked/.test(error.message);
+}
+
+// Run a write, retrying busy/locked failures with exponential backoff
+// (DB_BUSY_BACKOFF_MS, doubling) up to DB_BUSY_RETRIES times. busy_timeout
+// already waits inside SQLite; this covers locks that outlast it. The backoff
+// sleeps asynchronously so other requests are served meanwhile.
+export async function withBusyRetry<T>(write: () => T): Promise<T> {
+  for (let attempt = 0; ; attempt++) {
+    checkDeadline();
+    try {
+      return write();
+    } catch (error) {
+      if (!isBusyError(error) || attempt >= config.DB_BUSY_RETRIES) throw error;
+      const delay = config.DB_BUSY_BACKOFF_MS * 2 ** attempt;
+      logger.warn(`Database busy, retrying in ${delay}ms (attempt ${attempt + 1}/${config.DB_BUSY_RETRIES})`);
+      await Bun.sleep(delay);
     }
+  }
+}
+
+function isUuidConflict(error: unknown): boolean {
+  return error instanceof Error && error.message.includes('UNIQUE constraint failed: events.event_uuid');
+}
+
+export function getEventByUuid(eventUuid: string): HookEvent | null {
+  const row = db.prepare(`SELECT ${EVENT_COLUMNS} FROM events WHERE event_uuid = ?`).get(eventUuid) as any;
+  return row ? rowToEvent(row) : null;
+}
+
+// Insert an event. An event whose event_uuid was already stored is not
+// inserted again; the existing row is returned with created = false.
+export async function insertEvent(event: HookEvent): Promise<InsertEventResult> {
+  return withBusyRetry(() => insertEventRow(event));
+}
+
+function insertEventRow(event: HookEvent): InsertEventResult {
+  if (event.event_uuid) {
+    const existing = getEventByUuid(event.event_uuid);
+    if (existing) return { event: existing, created: false };
+  }
+  
+  const timestamp = event.timestamp || Date.now();
+  const payloadJson = JSON.stringify(event.payload);
+  const [storedPayload, compressed] = encodePayload(payloadJson);
+  let result;
+  try {
+    result = insertEventStmt.run(
+      event.source_app,
+      event.session_id,
+      event.hook_event_type,
+      storedPayload,
+      compressed,
+      payloadHash(payloadJson),
+      event.chat ? JSON.stringify(event.chat) : null,
+      event.summary || null,
+      timestamp,
+      event.event_uuid || null,
+      event.sample_rate || 1
+    );
   } catch (error) {
-    // If the table doesn't exist yet, the CREATE TABLE above will handle it
+    // Lost a race with a concurrent insert of the same uuid
+    if (event.event_uuid && isUuidConflict(error)) {
+      return { event: getEventByUuid(event.event_uuid)!, created: false };
+    }
+    throw error;
   }
+  if (compressed) hasCompressedRows = true;
   
-  // Create indexes for common queries
-  db.exec('CREATE INDEX IF NOT EXISTS idx_source_app ON events(source_app)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_session_id ON events(session_id)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_hook_event_type ON events(hook_event_type)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_timestamp ON events(timestamp)');
-  
-  // Create themes table
-  db.exec(`
-    CREATE TABLE IF NOT EXISTS themes (
-      id TEXT PRIMARY KEY,
-      name TEXT NOT NULL UNIQUE,
-      displayName TEXT NOT NULL,
-      description TEXT,
-      colors TEXT NOT NULL,
-      isPublic INTEGER NOT NULL DEFAULT 0,
-      authorId TEXT,
-      authorName TEXT,
-      createdAt INTEGER NOT NULL,
-      updatedAt INTEGER NOT NULL,
-      tags TEXT,
-      downloadCount INTEGER DEFAULT 0,
-      rating REAL DEFAULT 0,
-      ratingCount INTEGER DEFAULT 0
-    )
+  return {
+    event: {
+      ...event,
+      id: result.lastInsertRowid as number,
+      payload: redactPayload(event.payload, 'read'),
+      summary_preview: summaryPreview(event.summary),
+      timestamp
+    },
+    created: true
+  };
+}
+
+// Insert several events in one transaction
+export async function insertEvents(events: HookEvent[]): Promise<InsertEventResult[]> {
+  return withBusyRetry(() => db.transaction((batch: HookEvent[]) => batch.map(insertEventRow))(events));
+}
+
+// Escape LIKE wildcards so user input matches literally (use with ESCAPE '\\')
+function escapeLike(value: string): string {
+  return value.replace(/[\\%_]/g, char => `\\${char}`);
+}
+
+// Oldest visible timestamp under EVENT_TTL_HOURS, or undefined when events
+// never expire (or the caller asked for expired ones too)
+function ttlCutoff(includeExpired: boolean = false): number | undefined {
+  if (config.EVENT_TTL_HOURS <= 0 || includeExpired) return undefined;
+  return Date.now() - config.EVENT_TTL_HOURS * 3600 * 1000;
+}
+
+function distinctValues(column: string, order: 'ASC' | 'DESC', prefix?: string, limit?: number): string[] {
+  const params: (string | number)[] = [];
+  let sql = `SELECT DISTINCT ${column} AS value FROM events WHERE is_deleted = 0`;
+  const cutoff = ttlCutoff();
+  if (cutoff !== undefined) {
+    sql += ' AND timestamp >= ?';
+    params.push(cutoff);
+  }
+  if (prefix) {
+    sql += ` AND ${column} LIKE ? ESCAPE '\\'`;
+    params.push(`${escapeLike(prefix)}%`);
+  }
+  sql += ` ORDER BY ${column} ${order}`;
+  if (limit !== undefined) {
+    sql += ' LIMIT ?';
+    params.push(limit);
+  }
+  const rows = db.prepare(sql).all(...params) as { value: string }[];
+  return rows.map(row => row.value);
+}
+
+// Distinct values per filter category. Each category takes an optional
+// prefix and limit; session ids keep their default limit of 100.
+export function getFilterOptions(query: FilterOptionsQuery = {}): FilterOptions {
+  return {
+    source_apps: distinctValues('source_app', 'ASC', query.sourceAppPrefix, query.sourceAppLimit),
+    session_ids: distinctValues('session_id', 'DESC', query.sessionPrefix, query.sessionLimit ?? 100),
+    hook_event_types: distinctValues('hook_event_type', 'ASC', query.hookEventTypePrefix, query.hookEventTypeLimit)
+  };
+}
+
+// Prepare serialized payload JSON for storage, gzipped when
+// COMPRESS_PAYLOADS is on. Returns the stored value and the
+// payload_compressed flag.
+function encodePayload(json: string): [string | Uint8Array, number] {
+  return config.COMPRESS_PAYLOADS ? [Bun.gzipSync(json), 1] : [json, 0];
+}
+
+// Hash of the serialized payload, used to group identical events
+function payloadHash(json: string): string {
+  return new Bun.CryptoHasher('sha1').update(json).digest('hex');
+}
+
+// Rows written before compression was enabled stay plain JSON text
+function decodePayload(stored: string | Uint8Array, compressed: number): Record<string, any> {
+  if (compressed) {
+    return JSON.parse(new TextDecoder().decode(Bun.gunzipSync(stored as Uint8Array)));
+  }
+  return JSON.parse(stored as string);
+}
+
+function rowToEvent(row: any): HookEvent {
+  return {
+    id: row.id,
+    source_app: row.source_app,
+    session_id: row.session_id,
+    hook_event_type: row.hook_event_type,
+    payload: redactPayload(decodePayload(row.payload, row.payload_compressed), 'read'),
+    chat: row.chat ? JSON.parse(row.chat) : undefined,
+    summary: row.summary || undefined,
+    summary_preview: summaryPreview(row.summary || undefined),
+    timestamp: row.timestamp,
+    event_uuid: row.event_uuid || undefined,
+    ...(row.sample_rate > 1 && { sampled: true, sample_rate: row.sample_rate })
+  };
+}
+
+// payload.<path> filters run in SQL, which cannot read gzipped payloads, so
+// they are only exact while no payload is or will be stored compressed
+export function payloadFiltersSupported(): boolean {
+  return !config.COMPRESS_PAYLOADS && !hasCompressedRows;
+}
+
+// Dotted identifier paths only; array indexes and quoting are not supported
+export function isPayloadPath(path: string): boolean {
+  return /^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$/.test(path);
+}
+
+function typedQueryValue(value: string): string | number {
+  if (value === 'true') return 1;
+  if (value === 'false') return 0;
+  if (/^-?\d+(\.\d+)?$/.test(value)) return Number(value);
+  return value;
+}
+
+// bun:sqlite cannot interrupt a running statement, so reads and writes check
+// the request's deadline and abort signal before starting one instead. A
+// request that already got its 504 therefore stores nothing more.
+function checkDeadline(): void {
+  const deadline = getRequestDeadline();
+  if (deadline !== undefined && Date.now() > deadline) {
+    throw new RequestTimeoutError();
+  }
+  if (getRequestSignal()?.aborted) {
+    throw new RequestAbortedError();
+  }
+}
+
+// Build a WHERE clause from the optional event filter fields. Soft-deleted
+// events are excluded unless includeDeleted is set, and events older than
+// EVENT_TTL_HOURS unless includeExpired is set.
+function buildEventFilter(filter: EventFilter): { where: string; params: any[] } {
+  checkDeadline();
+  let where = 'WHERE 1=1';
+  const params: any[] = [];
+  
+  if (!filter.includeDeleted) {
+    where += ' AND is_deleted = 0';
+  }
+  const cutoff = ttlCutoff(filter.includeExpired);
+  if (cutoff !== undefined) {
+    where += ' AND timestamp >= ?';
+    params.push(cutoff);
+  }
+  for (const column of ['source_app', 'session_id', 'hook_event_type'] as const) {
+    const values = ([] as string[]).concat(filter[column] ?? []).filter(Boolean);
+    if (values.length === 1) {
+      where += ` AND ${column} = ?`;
+    } else if (values.length > 1) {
+      where += ` AND ${column} IN (${values.map(() => '?').join(', ')})`;
+    }
+    params.push(...values);
+  }
+  if (filter.start !== undefined) {
+    where += ' AND timestamp >= ?';
+    params.push(filter.start);
+  }
+  if (filter.end !== undefined) {
+    where += ' AND timestamp <= ?';
+    params.push(filter.end);
+  }
+  for (const [path, value] of Object.entries(filter.payload || {})) {
+    if (!isPayloadPath(path)) {
+      throw new Error(`Invalid payload path: ${path}`);
+    }
+    // Compressed payloads are not JSON to SQLite and would never match;
+    // callers reject payload filters then (payloadFiltersSupported). A
+    // numeric or boolean query value also matches the typed JSON value.
+    where += ` AND (CASE WHEN payload_compressed = 0 THEN json_extract(payload, ?) END) IN (?, ?)`;
+    params.push(`$.${path}`, value, typedQueryValue(value));
+  }
+  
+  return { where, params };
+}
+
+export function getRecentEvents(limit: number = config.DEFAULT_PAGE_SIZE, offset: number = 0, filter: EventFilter = {}): HookEvent[] {
+  const { where, params } = buildEventFilter(filter);
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where}
+    ORDER BY timestamp DESC
+    LIMIT ? OFFSET ?
   `);
   
-  // Create theme shares table
-  db.exec(`
-    CREATE TABLE IF NOT EXISTS theme_shares (
-      id TEXT PRIMARY KEY,
-      themeId TEXT NOT NULL,
-      shareToken TEXT NOT NULL UNIQUE,
-      expiresAt INTEGER,
-      isPublic INTEGER NOT NULL DEFAULT 0,
-      allowedUsers TEXT,
-      createdAt INTEGER NOT NULL,
-      accessCount INTEGER DEFAULT 0,
-      FOREIGN KEY (themeId) REFERENCES themes (id) ON DELETE CASCADE
-    )
+  const rows = stmt.all(...params, limit, offset) as any[];
+  
+  return rows.map(rowToEvent).reverse();
+}
+
+// Newest event by timestamp, or null when none match
+export function getLatestEvent(filter: EventFilter = {}): HookEvent | null {
+  const { where, params } = buildEventFilter(filter);
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where}
+    ORDER BY timestamp DESC, id DESC
+    LIMIT 1
   `);
   
-  // Create theme ratings table
-  db.exec(`
-    CREATE TABLE IF NOT EXISTS theme_ratings (
-      id TEXT PRIMARY KEY,
-      themeId TEXT NOT NULL,
-      userId TEXT NOT NULL,
-      rating INTEGER NOT NULL,
-      comment TEXT,
-      createdAt INTEGER NOT NULL,
-      UNIQUE(themeId, userId),
-      FOREIGN KEY (themeId) REFERENCES themes (id) ON DELETE CASCADE
-    )
+  const row = stmt.get(...params) as any;
+  return row ? rowToEvent(row) : null;
+}
+
+// Highest id among matching events, 0 when none match. Ids only grow, so
+// this changes whenever a matching event is added.
+export function getMaxEventId(filter: EventFilter = {}): number {
+  const { where, params } = buildEventFilter(filter);
+  const row = db.prepare(`SELECT MAX(id) as id FROM events ${where}`).get(...params) as { id: number | null };
+  return row.id ?? 0;
+}
+
+export function getEventById(id: number, includeDeleted: boolean = false, includeExpired: boolean = false): HookEvent | null {
+  const { where, params } = buildEventFilter({ includeDeleted, includeExpired });
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where} AND id = ?
   `);
   
-  // Create indexes for theme tables
-  db.exec('CREATE INDEX IF NOT EXISTS idx_themes_name ON themes(name)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_themes_isPublic ON themes(isPublic)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_themes_createdAt ON themes(createdAt)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_theme_shares_token ON theme_shares(shareToken)');
-  db.exec('CREATE INDEX IF NOT EXISTS idx_theme_ratings_theme ON theme_ratings(themeId)');
+  const row = stmt.get(...params, id) as any;
+  return row ? rowToEvent(row) : null;
 }
 
-export function insertEvent(event: HookEvent): HookEvent {
+// Events with id > afterId, oldest first
+export function getEventsAfter(afterId: number, limit: number = config.DEFAULT_PAGE_SIZE, filter: EventFilter = {}): HookEvent[] {
+  const { where, params } = buildEventFilter(filter);
   const stmt = db.prepare(`
-    INSERT INTO events (source_app, session_id, hook_event_type, payload, chat, summary, timestamp)
-    VALUES (?, ?, ?, ?, ?, ?, ?)
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where} AND id > ?
+    ORDER BY id ASC
+    LIMIT ?
   `);
   
-  const timestamp = event.timestamp || Date.now();
-  const result = stmt.run(
-    event.source_app,
-    event.session_id,
-    event.hook_event_type,
-    JSON.stringify(event.payload),
-    event.chat ? JSON.stringify(event.chat) : null,
-    event.summary || null,
-    timestamp
-  );
+  const rows = stmt.all(...params, afterId, limit) as any[];
+  return rows.map(rowToEvent);
+}
+
+// Keyset pagination: returns events with id < beforeId (newest first) so pages
+// stay stable while new events are being inserted.
+export function getEventsBefore(beforeId: number | undefined, limit: number = config.DEFAULT_PAGE_SIZE, filter: EventFilter = {}): EventPage {
+  const { where, params } = buildEventFilter(filter);
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where} AND id < ?
+    ORDER BY id DESC
+    LIMIT ?
+  `);
+  
+  const rows = stmt.all(...params, beforeId ?? Number.MAX_SAFE_INTEGER, limit) as any[];
+  const events = rows.map(rowToEvent);
+  const last = events[events.length - 1];
   
   return {
-    ...event,
-    id: result.lastInsertRowid as number,
-    timestamp
+    data: events,
+    nextCursor: events.length === limit && last ? last.id! : null
   };
 }
 
-export function getFilterOptions(): FilterOptions {
-  const sourceApps = db.prepare('SELECT DISTINCT source_app FROM events ORDER BY source_app').all() as { source_app: string }[];
-  const sessionIds = db.prepare('SELECT DISTINCT session_id FROM events ORDER BY session_id DESC LIMIT 100').all() as { session_id: string }[];
-  const hookEventTypes = db.prepare('SELECT DISTINCT hook_event_type FROM events ORDER BY hook_event_type').all() as { hook_event_type: string }[];
+export function countEvents(filter: EventFilter = {}): number {
+  const { where, params } = buildEventFilter(filter);
+  const row = db.prepare(`SELECT COUNT(*) as count FROM events ${where}`).get(...params) as { count: number };
+  return row.count;
+}
+
+// Set an event's summary. Deleted and expired events count as missing, as
+// they do for getEventById.
+export async function updateEventSummary(id: number, summary: string): Promise<boolean> {
+  const { where, params } = buildEventFilter({});
+  const result = await withBusyRetry(() => db.prepare(`UPDATE events SET summary = ? ${where} AND id = ?`).run(summary, ...params, id));
+  return result.changes > 0;
+}
+
+// Append messages to an event's chat transcript. Read and write happen in one
+// transaction so concurrent appends are not lost. Returns false if the event
+// does not exist, is deleted or has expired.
+export async function appendEventChat(id: number, messages: any[]): Promise<boolean> {
+  const { where, params } = buildEventFilter({});
+  return withBusyRetry(() => db.transaction(() => {
+    const row = db.prepare(`SELECT chat FROM events ${where} AND id = ?`).get(...params, id) as { chat: string | null } | null;
+    if (!row) return false;
+    
+    const chat = row.chat ? JSON.parse(row.chat) as any[] : [];
+    chat.push(...messages);
+    db.prepare('UPDATE events SET chat = ? WHERE id = ?').run(JSON.stringify(chat), id);
+    return true;
+  }).immediate());
+}
+
+// Hide an event from queries without removing it
+export async function softDeleteEvent(id: number): Promise<boolean> {
+  const result = await withBusyRetry(() => db.prepare('UPDATE events SET is_deleted = 1 WHERE id = ? AND is_deleted = 0').run(id));
+  return result.changes > 0;
+}
+
+// Permanently delete events older than the given timestamp
+export async function deleteEventsBefore(before: number): Promise<number> {
+  const result = await withBusyRetry(() => db.prepare('DELETE FROM events WHERE timestamp < ?').run(before));
+  return result.changes;
+}
+
+// Hard-delete every event of a session, soft-deleted ones included
+export async function deleteSession(sessionId: string): Promise<number> {
+  const result = await withBusyRetry(() => db.prepare('DELETE FROM events WHERE session_id = ?').run(sessionId));
+  return result.changes;
+}
+
+// Permanently delete all but the newest keepLast events
+export async function deleteEventsKeepLast(keepLast: number): Promise<number> {
+  const result = await withBusyRetry(() => db.prepare(`
+    DELETE FROM events WHERE id NOT IN (
+      SELECT id FROM events ORDER BY timestamp DESC, id DESC LIMIT ?
+    )
+  `).run(keepLast));
+  return result.changes;
+}
+
+// Events matching the filter in chronological order
+export function getFilteredEvents(filter: EventFilter = {}, limit: number = 1000): HookEvent[] {
+  const { where, params } = buildEventFilter(filter);
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where}
+    ORDER BY timestamp ASC
+    LIMIT ?
+  `);
   
-  return {
-    source_apps: sourceApps.map(row => row.source_app),
-    session_ids: sessionIds.map(row => row.session_id),
-    hook_event_types: hookEventTypes.map(row => row.hook_event_type)
-  };
+  const rows = stmt.all(...params, limit) as any[];
+  return rows.map(rowToEvent);
+}
+
+// Lazily iterate events matching the filter in chronological order
+export function* iterateFilteredEvents(filter: EventFilter = {}): Generator<HookEvent> {
+  const { where, params } = buildEventFilter(filter);
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where}
+    ORDER BY timestamp ASC
+  `);
+  
+  for (const row of stmt.iterate(...params)) {
+    yield rowToEvent(row);
+  }
+}
+
+export function getEventTypeCounts(sourceApp: string): { hook_event_type: string; count: number }[] {
+  const { where, params } = buildEventFilter({ source_app: sourceApp });
+  const stmt = db.prepare(`
+    SELECT hook_event_type, COUNT(*) as count
+    FROM events
+    ${where}
+    GROUP BY hook_event_type
+    ORDER BY hook_event_type
+  `);
+  
+  return stmt.all(...params) as { hook_event_type: string; count: number }[];
 }
 
-export function getRecentEvents(limit: number = 100): HookEvent[] {
+// Case-insensitive substring search of payloads, newest first. LIKE narrows
+// the plain-text rows in SQL; every candidate, including all gzipped rows, is
+// then checked against its payload as returned, so paging happens here and
+// values masked by REDACT_MODE=read cannot be probed by searching for them.
+export function searchEvents(query: string, limit: number = config.DEFAULT_PAGE_SIZE, offset: number = 0, filter: EventFilter = {}): HookEvent[] {
+  const pattern = `%${escapeLike(query)}%`;
+  const needle = query.toLowerCase();
+  const { where, params } = buildEventFilter(filter);
   const stmt = db.prepare(`
-    SELECT id, source_app, session_id, hook_event_type, payload, chat, summary, timestamp
+    SELECT ${EVENT_COLUMNS}
     FROM events
+    ${where} AND (payload_compressed = 1 OR payload LIKE ? ESCAPE '\\')
     ORDER BY timestamp DESC
-    LIMIT ?
   `);
   
-  const rows = stmt.all(limit) as any[];
+  const events: HookEvent[] = [];
+  let skipped = 0;
+  for (const row of stmt.iterate(...params, pattern) as IterableIterator<any>) {
+    const event = rowToEvent(row);
+    if (!JSON.stringify(event.payload).toLowerCase().includes(needle)) continue;
+    if (skipped < offset) {
+      skipped++;
+      continue;
+    }
+    events.push(event);
+    if (events.length >= limit) break;
+  }
+  return events;
+}
+
+// Whether an event with this source app, event type or session is stored
+// before the given one. Later rows do not count: a batch flush saves a new
+// session's events together.
+export function hasEarlierEvent(column: 'source_app' | 'hook_event_type' | 'session_id', value: string, beforeId: number): boolean {
+  const row = db.prepare(`SELECT 1 FROM events WHERE ${column} = ? AND id < ? AND is_deleted = 0 LIMIT 1`).get(value, beforeId);
+  return row !== null && row !== undefined;
+}
+
+// Every event for one session, oldest first
+export function getEventsBySession(sessionId: string, limit: number = config.DEFAULT_PAGE_SIZE, offset: number = 0): HookEvent[] {
+  const { where, params } = buildEventFilter({ session_id: sessionId });
+  const stmt = db.prepare(`
+    SELECT ${EVENT_COLUMNS}
+    FROM events
+    ${where}
+    ORDER BY timestamp ASC, id ASC
+    LIMIT ? OFFSET ?
+  `);
   
-  return rows.map(row => ({
-    id: row.id,
-    source_app: row.source_app,
-    session_id: row.session_id,
-    hook_event_type: row.hook_event_type,
-    payload: JSON.parse(row.payload),
-    chat: row.chat ? JSON.parse(row.chat) : undefined,
-    summary: row.summary || undefined,
-    timestamp: row.timestamp
-  })).reverse();
+  const rows = stmt.all(...params, limit, offset) as any[];
+  return rows.map(rowToEvent);
+}
+
+// One row per session, most recently active first
+export function getSessionSummaries(limit: number = config.DEFAULT_PAGE_SIZE, offset: number = 0): SessionSummary[] {
+  checkDeadline();
+  const stmt = db.prepare(`
+    SELECT
+      e.session_id,
+      (SELECT source_app FROM events s
+        WHERE s.session_id = e.session_id AND s.is_deleted = 0
+        ORDER BY s.timestamp DESC LIMIT 1) as source_app,
+      COUNT(*) as event_count,
+      MIN(e.timestamp) as first_timestamp,
+      MAX(e.timestamp) as last_timestamp,
+      (SELECT summary FROM events s
+        WHERE s.session_id = e.session_id AND s.is_deleted = 0 AND s.summary IS NOT NULL
+        ORDER BY s.timestamp DESC LIMIT 1) as latest_summary
+    FROM events e
+    WHERE e.is_deleted = 0 AND e.timestamp >= ?
+    GROUP BY e.session_id
+    ORDER BY last_timestamp DESC
+    LIMIT ? OFFSET ?
+  `);
+  
+  return stmt.all(ttlCutoff() ?? 0, limit, offset) as SessionSummary[];
+}
+
+// Sessions with the most events since the given time, busiest first
+export function getTopSessions(since: number, limit: number = config.DEFAULT_PAGE_SIZE): TopSession[] {
+  const { where, params } = buildEventFilter({ start: since });
+  return db.prepare(`
+    SELECT e.session_id,
+      (SELECT source_app FROM events s
+        WHERE s.session_id = e.session_id AND s.is_deleted = 0
+        ORDER BY s.timestamp DESC LIMIT 1) as source_app,
+      COUNT(*) as count
+    FROM (SELECT session_id FROM events ${where}) e
+    GROUP BY e.session_id
+    ORDER BY count DESC, e.session_id ASC
+    LIMIT ?
+  `).all(...params, limit) as TopSession[];
+}
+
+// Event counts grouped by type, source app and session
+export function getEventStats(since?: number): EventStats {
+  const { where, params } = buildEventFilter({ start: since });
+  const groupBy = (column: string): Record<string, number> => {
+    const rows = db.prepare(`
+      SELECT ${column} as key, COUNT(*) as count
+      FROM events
+      ${where}
+      GROUP BY ${column}
+    `).all(...params) as { key: string; count: number }[];
+    
+    return Object.fromEntries(rows.map(row => [row.key, row.count]));
+  };
+  
+  const { estimated } = db.prepare(`SELECT COALESCE(SUM(sample_rate), 0) as estimated FROM events ${where}`).get(...params) as { estimated: number };
+  
+  return {
+    total: countEvents({ start: since }),
+    estimated_total: estimated,
+    by_type: groupBy('hook_event_type'),
+    by_source: groupBy('source_app'),
+    by_session: groupBy('session_id')
+  };
+}
+
+// Groups of events sharing source app, type and payload hash within the
+// window, most repeated first. Only groups of at least minCount are returned.
+export function getDuplicateGroups(since: number, until: number | undefined, minCount: number = 2, limit: number = config.DEFAULT_PAGE_SIZE): DuplicateGroup[] {
+  const { where, params } = buildEventFilter({ start: since, end: until });
+  return db.prepare(`
+    SELECT source_app, hook_event_type, payload_hash,
+      COUNT(*) as count,
+      MIN(timestamp) as first_seen,
+      MAX(timestamp) as last_seen,
+      MIN(id) as sample_event_id
+    FROM events
+    ${where} AND payload_hash IS NOT NULL
+    GROUP BY source_app, hook_event_type, payload_hash
+    HAVING COUNT(*) >= ?
+    ORDER BY count DESC, last_seen DESC
+    LIMIT ?
+  `).all(...params, minCount, limit) as DuplicateGroup[];
+}
+
+// Event counts per fixed-width time bucket, oldest first. Buckets with no
+// events are omitted.
+export function getEventTimeline(bucketSeconds: number, since?: number, until?: number): TimelineBucket[] {
+  if (!(bucketSeconds > 0)) {
+    throw new Error('bucketSeconds must be positive');
+  }
+  
+  const bucketMs = Math.floor(bucketSeconds * 1000);
+  const { where, params } = buildEventFilter({ start: since, end: until });
+  const stmt = db.prepare(`
+    SELECT (timestamp / ?) * ? as bucketStart, COUNT(*) as count
+    FROM events
+    ${where}
+    GROUP BY bucketStart
+    ORDER BY bucketStart ASC
+  `);
+  
+  return stmt.all(bucketMs, bucketMs, ...params) as TimelineBucket[];
 }
 
 // Theme database functions
-export function insertTheme(theme: Theme): Theme {
+export async function insertTheme(theme: Theme): Promise<Theme> {
+  return withBusyRetry(() => insertThemeRow(theme));
+}
+
+function insertThemeRow(theme: Theme): Theme {
+  // The author's name lives in authors. A name given with the theme only
+  // names a new author; renames go through upsertAuthor.
+  if (theme.authorId) {
+    ensureAuthorRow(theme.authorId, theme.authorName || null, theme.createdAt);
+  }
+  
   const stmt = db.prepare(`
-    INSERT INTO themes (id, name, displayName, description, colors, isPublic, authorId, authorName, createdAt, updatedAt, tags, downloadCount, rating, ratingCount)
-    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    INSERT INTO themes (id, name, displayName, description, colors, isPublic, authorId, createdAt, updatedAt, tags, downloadCount, rating, ratingCount)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
   `);
   
   stmt.run(
@@ -178,7 +689,6 @@ export function insertTheme(theme: Theme): Theme {
     JSON.stringify(theme.colors),
     theme.isPublic ? 1 : 0,
     theme.authorId || null,
-    theme.authorName || null,
     theme.createdAt,
     theme.updatedAt,
     JSON.stringify(theme.tags),
@@ -187,10 +697,17 @@ export function insertTheme(theme: Theme): Theme {
     theme.ratingCount || 0
   );
   
-  return theme;
+  return theme.authorId ? { ...theme, authorName: getAuthor(theme.authorId)?.name ?? undefined } : theme;
 }
 
-export function updateTheme(id: string, updates: Partial<Theme>): boolean {
+// Insert several themes in one transaction
+export async function insertThemes(themes: Theme[]): Promise<Theme[]> {
+  return withBusyRetry(() => db.transaction((batch: Theme[]) => batch.map(insertThemeRow))(themes));
+}
+
+// Apply a partial update. With expectedUpdatedAt the row is only changed if
+// its updatedAt still matches (optimistic concurrency).
+export async function updateTheme(id: string, updates: Partial<Theme>, expectedUpdatedAt?: number): Promise<boolean> {
   const allowedFields = ['displayName', 'description', 'colors', 'isPublic', 'updatedAt', 'tags'];
   const validKeys = Object.keys(updates)
     .filter(key => allowedFields.includes(key) && updates[key as keyof Theme] !== undefined);
@@ -210,18 +727,19 @@ export function updateTheme(id: string, updates: Partial<Theme>): boolean {
     return value;
   });
   
+  if (expectedUpdatedAt !== undefined) {
+    const stmt = db.prepare(`UPDATE themes SET ${setClause} WHERE id = ? AND updatedAt = ?`);
+    const result = await withBusyRetry(() => stmt.run(...(values as any[]), id, expectedUpdatedAt));
+    return result.changes > 0;
+  }
+  
   const stmt = db.prepare(`UPDATE themes SET ${setClause} WHERE id = ?`);
-  const result = stmt.run(...(values as any[]), id);
+  const result = await withBusyRetry(() => stmt.run(...(values as any[]), id));
   
   return result.changes > 0;
 }
 
-export function getTheme(id: string): Theme | null {
-  const stmt = db.prepare('SELECT * FROM themes WHERE id = ?');
-  const row = stmt.get(id) as any;
-  
-  if (!row) return null;
-  
+function rowToTheme(row: any): Theme {
   return {
     id: row.id,
     name: row.name,
@@ -229,8 +747,9 @@ export function getTheme(id: string): Theme | null {
     description: row.description,
     colors: JSON.parse(row.colors),
     isPublic: Boolean(row.isPublic),
+    isFeatured: Boolean(row.isFeatured),
     authorId: row.authorId,
-    authorName: row.authorName,
+    authorName: row.author_name ?? undefined,
     createdAt: row.createdAt,
     updatedAt: row.updatedAt,
     tags: JSON.parse(row.tags || '[]'),
@@ -240,22 +759,60 @@ export function getTheme(id: string): Theme | null {
   };
 }
 
+// Themes joined to their author's current name. Column references in
+// conditions must be qualified: authors also has id and name.
+const THEME_SELECT = 'SELECT themes.*, authors.name AS author_name FROM themes LEFT JOIN authors ON authors.id = themes.authorId';
+
+export function getTheme(id: string): Theme | null {
+  const stmt = db.prepare(`${THEME_SELECT} WHERE themes.id = ?`);
+  const row = stmt.get(id) as any;
+  
+  return row ? rowToTheme(row) : null;
+}
+
+// Theme names are unique per author; authorless themes share one namespace
+export function getThemeByAuthorAndName(authorId: string | null | undefined, name: string): Theme | null {
+  const row = db.prepare(`${THEME_SELECT} WHERE COALESCE(themes.authorId, '') = ? AND themes.name = ?`).get(authorId || '', name) as any;
+  return row ? rowToTheme(row) : null;
+}
+
+// Themes with the given ids, in the order requested; unknown ids are skipped
+export function getThemesByIds(ids: string[]): Theme[] {
+  if (ids.length === 0) return [];
+  const rows = db.prepare(`${THEME_SELECT} WHERE themes.id IN (${ids.map(() => '?').join(', ')})`).all(...ids) as any[];
+  const byId = new Map(rows.map(row => [row.id as string, rowToTheme(row)]));
+  return ids.filter(id => byId.has(id)).map(id => byId.get(id)!);
+}
+
 export function getThemes(query: ThemeSearchQuery = {}): Theme[] {
-  let sql = 'SELECT * FROM themes WHERE 1=1';
+  checkDeadline();
+  let sql = `${THEME_SELECT} WHERE 1=1`;
   const params: any[] = [];
   
   if (query.isPublic !== undefined) {
-    sql += ' AND isPublic = ?';
+    sql += ' AND themes.isPublic = ?';
     params.push(query.isPublic ? 1 : 0);
   }
   
+  if (query.visibleTo === null) {
+    sql += ' AND themes.isPublic = 1';
+  } else if (query.visibleTo !== undefined) {
+    sql += ' AND (themes.isPublic = 1 OR themes.authorId = ?)';
+    params.push(query.visibleTo);
+  }
+  
+  if (query.isFeatured !== undefined) {
+    sql += ' AND themes.isFeatured = ?';
+    params.push(query.isFeatured ? 1 : 0);
+  }
+  
   if (query.authorId) {
-    sql += ' AND authorId = ?';
+    sql += ' AND themes.authorId = ?';
     params.push(query.authorId);
   }
   
   if (query.query) {
-    sql += ' AND (name LIKE ? OR displayName LIKE ? OR description LIKE ?)';
+    sql += ' AND (themes.name LIKE ? OR themes.displayName LIKE ? OR themes.description LIKE ?)';
     const searchTerm = `%${query.query}%`;
     params.push(searchTerm, searchTerm, searchTerm);
   }
@@ -271,7 +828,7 @@ export function getThemes(query: ThemeSearchQuery = {}): Theme[] {
     rating: 'rating'
   }[sortBy] || 'createdAt';
   
-  sql += ` ORDER BY ${sortColumn} ${sortOrder.toUpperCase()}`;
+  sql += ` ORDER BY themes.${sortColumn} ${sortOrder.toUpperCase()}`;
   
   // Add pagination
   if (query.limit) {
@@ -287,22 +844,57 @@ export function getThemes(query: ThemeSearchQuery = {}): Theme[] {
   const stmt = db.prepare(sql);
   const rows = stmt.all(...params) as any[];
   
-  return rows.map(row => ({
-    id: row.id,
-    name: row.name,
-    displayName: row.displayName,
-    description: row.description,
-    colors: JSON.parse(row.colors),
-    isPublic: Boolean(row.isPublic),
-    authorId: row.authorId,
-    authorName: row.authorName,
-    createdAt: row.createdAt,
-    updatedAt: row.updatedAt,
-    tags: JSON.parse(row.tags || '[]'),
-    downloadCount: row.downloadCount,
-    rating: row.rating,
-    ratingCount: row.ratingCount
-  }));
+  return rows.map(rowToTheme);
+}
+
+// Record an author if unknown. An existing name is never replaced; one that
+// was never set is filled in.
+function ensureAuthorRow(id: string, name: string | null, now: number): void {
+  db.prepare(`
+    INSERT INTO authors (id, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)
+    ON CONFLICT(id) DO UPDATE SET name = excluded.name, updatedAt = excluded.updatedAt
+    WHERE authors.name IS NULL AND excluded.name IS NOT NULL
+  `).run(id, name, now, now);
+}
+
+function upsertAuthorRow(id: string, name: string | null, now: number): void {
+  // A null name never clears a known one
+  db.prepare(`
+    INSERT INTO authors (id, name, createdAt, updatedAt) VALUES (?, ?, ?, ?)
+    ON CONFLICT(id) DO UPDATE SET
+      name = COALESCE(excluded.name, authors.name),
+      updatedAt = CASE WHEN excluded.name IS NOT NULL THEN excluded.updatedAt ELSE authors.updatedAt END
+  `).run(id, name, now, now);
+}
+
+export function getAuthor(id: string): Author | null {
+  const row = db.prepare('SELECT id, name, createdAt, updatedAt FROM authors WHERE id = ?').get(id) as any;
+  return row ? { id: row.id, name: row.name ?? undefined, createdAt: row.createdAt, updatedAt: row.updatedAt } : null;
+}
+
+// Create an author or rename an existing one; every theme by them picks up
+// the new name
+export async function upsertAuthor(id: string, name: string): Promise<Author> {
+  await withBusyRetry(() => upsertAuthorRow(id, name, Date.now()));
+  return getAuthor(id)!;
+}
+
+// Curate a theme into (or out of) the featured set. Does not touch updatedAt,
+// which tracks edits by the author.
+export async function setThemeFeatured(id: string, featured: boolean): Promise<boolean> {
+  const result = await withBusyRetry(() => db.prepare('UPDATE themes SET isFeatured = ? WHERE id = ?').run(featured ? 1 : 0, id));
+  return result.changes > 0;
+}
+
+// Distinct tags across all themes with how many themes use each
+export function getThemeTagCounts(): { tag: string; count: number }[] {
+  return db.prepare(`
+    SELECT tag.value as tag, COUNT(DISTINCT themes.id) as count
+    FROM themes, json_each(themes.tags) as tag
+    WHERE tag.type = 'text'
+    GROUP BY tag.value
+    ORDER BY tag.value
+  `).all() as { tag: string; count: number }[];
 }
 
 export function deleteTheme(id: string): boolean {
@@ -0,0 +1,47 @@
+import { generateHeapSnapshot } from 'bun';
+import { heapStats } from 'bun:jsc';
+import { getClientCount } from './websocket';
+import { getSseClientCount } from './sse';
+import { getQueueDepth } from './ingest';
+
+// Runtime introspection for leak hunting, mounted under /debug/pprof when
+// ENABLE_PPROF is set. Bun has no pprof; these are the JavaScriptCore
+// equivalents: a process/heap summary and a full heap snapshot that loads in
+// Safari/WebKit Web Inspector.
+export function handleDebugRequest(url: URL, headers: Record<string, string>): Response | undefined {
+  if (url.pathname === '/debug/pprof') {
+    const stats = heapStats();
+    const topObjectTypes = Object.entries(stats.objectTypeCounts)
+      .sort(([, a], [, b]) => b - a)
+      .slice(0, 25);
+    
+    return new Response(JSON.stringify({
+      uptimeSeconds: process.uptime(),
+      memory: process.memoryUsage(),
+      heap: {
+        heapSize: stats.heapSize,
+        heapCapacity: stats.heapCapacity,
+        objectCount: stats.objectCount,
+        protectedObjectCount: stats.protectedObjectCount,
+        topObjectTypes: Object.fromEntries(topObjectTypes)
+      },
+      websocketClients: getClientCount(),
+      sseClients: getSseClientCount(),
+      ingestQueueDepth: getQueueDepth()
+    }), {
+      headers: { ...headers, 'Content-Type': 'application/json' }
+    });
+  }
+  
+  if (url.pathname === '/debug/pprof/heap') {
+    return new Response(JSON.stringify(generateHeapSnapshot()), {
+      headers: {
+        ...headers,
+        'Content-Type': 'application/json',
+        'Content-Disposition': `attachment; filename="heap-${Date.now()}.heapsnapshot"`
+      }
+    });
+  }
+  
+  return undefined;
+}
@@ -0,0 +1,116 @@
+import type { ApiResponse } from './types';
+
+// Stable, machine-readable error codes. Clients branch on these; the
+// message is for humans and may change.
+export type ErrorCode =
+  | 'INVALID_BODY'
+  | 'INVALID_PARAMETER'
+  | 'EVENT_INVALID'
+  | 'EVENT_NOT_FOUND'
+  | 'EVENT_REJECTED'
+  | 'SESSION_NOT_FOUND'
+  | 'THEME_INVALID'
+  | 'THEME_NOT_FOUND'
+  | 'THEME_DUPLICATE'
+  | 'THEME_CONFLICT'
+  | 'THEME_VERSION_REQUIRED'
+  | 'AUTHOR_NOT_FOUND'
+  | 'FORBIDDEN'
+  | 'UNAUTHORIZED'
+  | 'NOT_FOUND'
+  | 'METHOD_NOT_ALLOWED'
+  | 'PAYLOAD_TOO_LARGE'
+  | 'RATE_LIMITED'
+  | 'INGEST_QUEUE_FULL'
+  | 'UPGRADE_REQUIRED'
+  | 'READ_ONLY'
+  | 'DATABASE_UNAVAILABLE'
+  | 'TIMEOUT'
+  | 'INTERNAL';
+
+// Thrown by the database layer once the current request's deadline has passed
+export class RequestTimeoutError extends Error {
+  constructor() {
+    super('Request deadline exceeded');
+    this.name = 'RequestTimeoutError';
+  }
+}
+
+// Thrown by the database layer once the client has gone away
+export class RequestAbortedError extends Error {
+  constructor() {
+    super('Request aborted by client');
+    this.name = 'RequestAbortedError';
+  }
+}
+
+// Thrown by an event processor to refuse an event; the message is returned
+// to the client
+export class EventRejectedError extends Error {
+  processor?: string;
+  
+  constructor(message: string, processor?: string) {
+    super(message);
+    this.name = 'EventRejectedError';
+    this.processor = processor;
+  }
+}
+
+export interface ErrorBody {
+  error: {
+    code: ErrorCode;
+    message: string;
+    details?: unknown;
+  };
+}
+
+// Build the standard error envelope {error: {code, message, details}}
+export function respondError(
+  headers: Record<string, string>,
+  status: number,
+  code: ErrorCode,
+  message: string,
+  details?: unknown,
+  extraHeaders: Record<string, string> = {}
+): Response {
+  const body: ErrorBody = { error: { code, message, ...(details !== undefined && { details }) } };
+  return new Response(JSON.stringify(body), {
+    status,
+    headers: { ...headers, 'Content-Type': 'application/json', ...extraHeaders }
+  });
+}
+
+// HTTP status for each code a theme service failure carries
+const THEME_FAILURE_STATUS: Partial<Record<ErrorCode, number>> = {
+  THEME_INVALID: 400,
+  FORBIDDEN: 403,
+  THEME_NOT_FOUND: 404,
+  AUTHOR_NOT_FOUND: 404,
+  THEME_CONFLICT: 409,
+  THEME_DUPLICATE: 409,
+  INTERNAL: 500
+};
+
+// Status and code for a failed theme service result
+function themeFailure(result: ApiResponse): { status: number; code: ErrorCode } {
+  const code = result.code ?? 'INTERNAL';
+  return { status: THEME_FAILURE_STATUS[code] ?? 500, code };
+}
+
+// Send a theme service result: the ApiResponse itself on success, the error
+// envelope on failure. Validation errors and any current server-side copy
+// (on conflicts) go in details.
+export function respondApiResult(headers: Record<string, string>, result: ApiResponse, successStatus: number = 200): Response {
+  if (result.success) {
+    return new Response(JSON.stringify(result), {
+      status: successStatus,
+      headers: { ...headers, 'Content-Type': 'application/json' }
+    });
+  }
+  
+  const { status, code } = themeFailure(result);
+  const details = result.validationErrors || result.data
+    ? { validationErrors: result.validationErrors, current: result.data }
+    : undefined;
+  return respondError(headers, status, code, result.error || 'Request failed', details);
+}
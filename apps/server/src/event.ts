@@ -0,0 +1,184 @@
+import { HOOK_EVENT_TYPES, isKnownHookEventType } from './types';
+import type { HookEvent, ValidationError } from './types';
+import { config } from './config';
+
+export interface EventValidationOptions {
+  // Accept hook_event_type values outside HOOK_EVENT_TYPES
+  allowUnknownTypes?: boolean;
+}
+
+// Validate an incoming event, reporting each missing or empty field
+export function validateEvent(event: Partial<HookEvent>, options: EventValidationOptions = {}): ValidationError[] {
+  const errors: ValidationError[] = [];
+  
+  for (const field of ['source_app', 'session_id', 'hook_event_type'] as const) {
+    const value = event[field];
+    if (value === undefined || value === null) {
+      errors.push({
+        field,
+        message: `${field} is required`,
+        code: 'REQUIRED'
+      });
+    } else if (typeof value !== 'string' || value.trim() === '') {
+      errors.push({
+        field,
+        message: `${field} must be a non-empty string`,
+        code: 'INVALID_FORMAT'
+      });
+    }
+  }
+  
+  if (
+    !options.allowUnknownTypes &&
+    typeof event.hook_event_type === 'string' &&
+    event.hook_event_type.trim() !== '' &&
+    !isKnownHookEventType(event.hook_event_type)
+  ) {
+    errors.push({
+      field: 'hook_event_type',
+      message: `Unknown hook_event_type "${event.hook_event_type}"; expected one of ${HOOK_EVENT_TYPES.join(', ')}`,
+      code: 'INVALID_ENUM'
+    });
+  }
+  
+  if (event.event_uuid !== undefined && (typeof event.event_uuid !== 'string' || event.event_uuid.trim() === '')) {
+    errors.push({
+      field: 'event_uuid',
+      message: 'event_uuid must be a non-empty string',
+      code: 'INVALID_FORMAT'
+    });
+  }
+  
+  if (event.payload === undefined || event.payload === null) {
+    errors.push({
+      field: 'payload',
+      message: 'payload is required',
+      code: 'REQUIRED'
+    });
+  } else if (typeof event.payload !== 'object' || Array.isArray(event.payload)) {
+    errors.push({
+      field: 'payload',
+      message: 'payload must be a JSON object',
+      code: 'INVALID_FORMAT'
+    });
+  } else if (Object.keys(event.payload).length === 0) {
+    errors.push({
+      field: 'payload',
+      message: 'payload must not be empty',
+      code: 'EMPTY'
+    });
+  } else if (config.PAYLOAD_OVERFLOW_POLICY === 'reject' && payloadSize(event.payload) > config.MAX_PAYLOAD_BYTES) {
+    errors.push({
+      field: 'payload',
+      message: `payload exceeds ${config.MAX_PAYLOAD_BYTES} bytes`,
+      code: 'TOO_LARGE'
+    });
+  }
+  
+  return errors;
+}
+
+function payloadSize(payload: Record<string, any>): number {
+  if (config.MAX_PAYLOAD_BYTES <= 0) return 0;
+  return Buffer.byteLength(JSON.stringify(payload));
+}
+
+// Under the truncate policy, replace an oversized payload with a marker
+// recording its original serialized size. Call after validateEvent.
+export function capPayload(event: HookEvent): HookEvent {
+  if (config.PAYLOAD_OVERFLOW_POLICY !== 'truncate') return event;
+  
+  const size = payloadSize(event.payload);
+  if (size <= config.MAX_PAYLOAD_BYTES) return event;
+  
+  return {
+    ...event,
+    payload: { _truncated: true, _originalSize: size }
+  };
+}
+
+const REDACTED = '***';
+
+function redactValue(value: any, keys: Set<string>): any {
+  if (Array.isArray(value)) return value.map(item => redactValue(item, keys));
+  if (value === null || typeof value !== 'object') return value;
+  
+  return Object.fromEntries(Object.entries(value).map(([key, child]) =>
+    [key, keys.has(key.toLowerCase()) ? REDACTED : redactValue(child, keys)]
+  ));
+}
+
+// Mask REDACT_KEYS values anywhere in the payload when redaction happens at
+// this stage (REDACT_MODE). Returns the payload untouched otherwise.
+export function redactPayload(payload: Record<string, any>, stage: 'write' | 'read'): Record<string, any> {
+  if (config.REDACT_KEYS.length === 0 || config.REDACT_MODE !== stage) return payload;
+  return redactValue(payload, new Set(config.REDACT_KEYS));
+}
+
+// Whether a dotted payload path passes through a key that is masked on
+// read. Filtering on such a path would reveal the hidden value.
+export function isRedactedOnRead(path: string): boolean {
+  if (config.REDACT_KEYS.length === 0 || config.REDACT_MODE !== 'read') return false;
+  return path.split('.').some(segment => config.REDACT_KEYS.includes(segment.toLowerCase()));
+}
+
+// Longest prefix of value that fits in maxBytes of UTF-8. Cuts between code
+// points, so a multibyte character is dropped whole rather than split.
+function truncateUtf8(value: string, maxBytes: number): string {
+  let bytes = 0;
+  let end = 0;
+  for (const char of value) {
+    bytes += Buffer.byteLength(char);
+    if (bytes > maxBytes) break;
+    end += char.length;
+  }
+  return value.slice(0, end);
+}
+
+// Replace large payloads with a short preview so live frames stay small
+export function toBroadcastEvent(event: HookEvent): HookEvent {
+  const limit = config.WS_PAYLOAD_PREVIEW_BYTES;
+  if (limit <= 0) return event;
+  
+  const serialized = JSON.stringify(event.payload);
+  if (Buffer.byteLength(serialized) <= limit) return event;
+  
+  return {
+    ...event,
+    payload: {
+      _truncated: true,
+      event_id: event.id,
+      preview: truncateUtf8(serialized, limit)
+    }
+  };
+}
+
+// First SUMMARY_PREVIEW_CHARS characters of a summary plus an ellipsis.
+// Counts code points, so a surrogate pair is never split. Undefined when
+// previews are disabled or the event has no summary.
+export function summaryPreview(summary: string | undefined): string | undefined {
+  const limit = config.SUMMARY_PREVIEW_CHARS;
+  if (!summary || limit <= 0) return undefined;
+  
+  const chars = Array.from(summary);
+  return chars.length <= limit ? summary : chars.slice(0, limit).join('').trimEnd() + '…';
+}
+
+// Fields a client may select with ?fields=
+export const EVENT_FIELDS: (keyof HookEvent)[] = [
+  'id', 'source_app', 'session_id', 'hook_event_type', 'payload', 'chat',
+  'summary', 'summary_preview', 'timestamp', 'event_uuid', 'sampled', 'sample_rate'
+];
+
+// Parse a comma-separated ?fields= value. Returns the unknown names instead
+// when any field is not an event field.
+export function parseEventFields(value: string): { fields: (keyof HookEvent)[] } | { unknown: string[] } {
+  const requested = value.split(',').map(field => field.trim()).filter(Boolean);
+  const unknown = requested.filter(field => !EVENT_FIELDS.includes(field as keyof HookEvent));
+  return unknown.length > 0 ? { unknown } : { fields: requested as (keyof HookEvent)[] };
+}
+
+// Keep only the selected fields of an event
+export function projectEvent(event: HookEvent, fields: (keyof HookEvent)[]): Partial<HookEvent> {
+  return Object.fromEntries(fields.filter(field => event[field] !== undefined).map(field => [field, event[field]]));
+}
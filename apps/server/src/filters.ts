@@ -0,0 +1,39 @@
+import { getFilterOptions, hasEarlierEvent } from './db';
+import { createSessionCache } from './lru';
+import type { LRUCache } from './lru';
+import type { HookEvent } from './types';
+
+// Filter values already known to dashboards, each bounded like the session
+// LRU. A value that fell out of its cache is looked up in the database, so
+// eviction never makes an old value look new.
+const knownSourceApps = createSessionCache<true>();
+const knownEventTypes = createSessionCache<true>();
+const knownSessions = createSessionCache<true>();
+
+export function initFilterTracking(): void {
+  const options = getFilterOptions();
+  options.source_apps.forEach(app => knownSourceApps.set(app, true));
+  options.hook_event_types.forEach(type => knownEventTypes.set(type, true));
+  options.session_ids.forEach(id => knownSessions.set(id, true));
+}
+
+// Record one filter value of the event and report whether it is new
+function track(known: LRUCache<string, true>, column: 'source_app' | 'hook_event_type' | 'session_id', value: string, eventId: number): boolean {
+  const isNew = !known.has(value) && !hasEarlierEvent(column, value, eventId);
+  known.set(value, true);
+  return isNew;
+}
+
+// Record the event's filter values and report whether any was new
+export function introducesNewFilterValue(event: HookEvent): boolean {
+  // Every value is recorded, so no short-circuiting
+  const newSourceApp = track(knownSourceApps, 'source_app', event.source_app, event.id!);
+  const newEventType = track(knownEventTypes, 'hook_event_type', event.hook_event_type, event.id!);
+  const newSession = track(knownSessions, 'session_id', event.session_id, event.id!);
+  return newSourceApp || newEventType || newSession;
+}
+
+// Stop treating a purged session as known, so a new event for it counts as new
+export function forgetSession(sessionId: string): void {
+  knownSessions.delete(sessionId);
+}
@@ -1,222 +1,1086 @@
-import { initDatabase, insertEvent, getFilterOptions, getRecentEvents } from './db';
-import type { HookEvent } from './types';
+import { initDatabase, closeDatabase, pingDatabase, insertEvent, getFilterOptions, getRecentEvents, getEventsBefore, countEvents, getEventById, getFilteredEvents, getEventTypeCounts, searchEvents, getEventStats, getEventTimeline, iterateFilteredEvents, softDeleteEvent, getSessionSummaries, getEventsBySession, updateEventSummary, appendEventChat, getEventsAfter, getLatestEvent, getDuplicateGroups, getTopSessions, getMaxEventId, isPayloadPath, deleteEventsBefore, deleteEventsKeepLast, deleteSession, payloadFiltersSupported } from './db';
+import { HOOK_EVENT_TYPES } from './types';
+import type { HookEvent, EventFilter, FilterOptionsQuery, HookCoverage } from './types';
 import { 
   createTheme, 
   updateThemeById, 
   getThemeById, 
+  getThemesByIdList,
+  getAuthorThemes,
+  renameAuthor,
   searchThemes, 
   deleteThemeById, 
   exportThemeById, 
   importTheme,
-  getThemeStats 
+  getThemeStats,
+  cloneTheme,
+  generateTheme,
+  bulkCreateThemes,
+  getAllThemeTags,
+  getFeaturedThemes,
+  setFeatured,
+  themeETag
 } from './theme';
-import { config, validateRequiredConfig } from './config';
+import type { ThemeViewer } from './theme';
+import { config, publicConfig, validateRequiredConfig } from './config';
+import { buildSessionTrace } from './trace';
+import { cached, invalidateCache } from './cache';
+import { authenticateAdmin, authenticateRequest, authenticateStream, BEARER_SUBPROTOCOL } from './auth';
+import { logger, runWithRequestId } from './logger';
+import { eventsCsvStream } from './csv';
+import { capPayload, EVENT_FIELDS, isRedactedOnRead, parseEventFields, projectEvent, redactPayload, toBroadcastEvent, validateEvent } from './event';
+import { checkSourceRateLimit } from './ratelimit';
+import { initFilterTracking, forgetSession, introducesNewFilterValue } from './filters';
+import { enqueueEvent, getDroppedEventCount, getQueueDepth, isBufferedIngestion, startIngestBuffer, stopIngestBuffer } from './ingest';
+import { broadcast, describeClients, getClientCount, isSweepAlive, newClientData, shutdownWebSockets, startClientSweep, startStatsBroadcast, websocketHandlers } from './websocket';
+import type { ClientData } from './websocket';
+import { isDatabaseError, recordDbError, recordEventIngested, recordRequest, registerGauge, renderMetrics, routeLabel } from './metrics';
+import { compressResponse } from './compression';
+import { acceptsMsgpack, toMsgpackResponse } from './msgpack';
+import { corsHeaders } from './cors';
+import { clientIp } from './proxy';
+import { EventRejectedError, RequestAbortedError, RequestTimeoutError, respondApiResult, respondError } from './errors';
+import { handleDebugRequest } from './debug';
+import { openSseStream, shutdownSse } from './sse';
+import { requestSummary } from './summary';
+import { notifyWebhooks } from './webhooks';
+import { sampleEvent } from './sampling';
+import { hasEventProcessors, loadEventProcessors, runEventProcessors } from './processors';
 
 // Validate configuration and initialize database
 validateRequiredConfig();
 initDatabase();
+await loadEventProcessors();
 
-// Store WebSocket clients
-const wsClients = new Set<any>();
+initFilterTracking();
+
+registerGauge('websocket_clients', 'Currently connected WebSocket clients', getClientCount);
+registerGauge('ingest_queue_depth', 'Events waiting in the ingestion buffer', getQueueDepth);
+startClientSweep();
+startStatsBroadcast();
+startIngestBuffer(onEventSaved);
+
+// Post-insert work shared by direct and buffered ingestion
+function onEventSaved(savedEvent: HookEvent): void {
+  recordEventIngested(savedEvent.hook_event_type);
+  invalidateCache('events:');
+  
+  // Broadcast to all WebSocket clients
+  broadcast({ type: 'event', data: toBroadcastEvent(savedEvent) });
+  
+  // Let dashboards refresh cached filter dropdowns
+  if (introducesNewFilterValue(savedEvent)) {
+    broadcast({ type: 'filters_updated', data: getFilterOptions() });
+  }
+  
+  requestSummary(savedEvent);
+  notifyWebhooks(savedEvent);
+}
+
+// Read the common event filter fields from query parameters
+function eventFilterFromParams(params: URLSearchParams): EventFilter {
+  const start = params.get('start');
+  const end = params.get('end');
+  return {
+    source_app: multiValueParam(params, 'source_app'),
+    session_id: multiValueParam(params, 'session_id'),
+    hook_event_type: multiValueParam(params, 'hook_event_type'),
+    start: start && !isNaN(parseInt(start)) ? parseInt(start) : undefined,
+    end: end && !isNaN(parseInt(end)) ? parseInt(end) : undefined,
+    includeDeleted: params.get('includeDeleted') === 'true',
+    includeExpired: params.get('includeExpired') === 'true',
+    payload: payloadFiltersFromParams(params)
+  };
+}
+
+// Values of a repeated and/or comma-separated parameter (?a=x,y&a=z)
+function multiValueParam(params: URLSearchParams, name: string): string[] | undefined {
+  const values = params.getAll(name).flatMap(value => value.split(',')).map(value => value.trim()).filter(Boolean);
+  return values.length > 0 ? values : undefined;
+}
+
+// Collect payload.<path>=<value> query parameters
+function payloadFiltersFromParams(params: URLSearchParams): Record<string, string> | undefined {
+  const filters: Record<string, string> = {};
+  for (const [key, value] of params) {
+    if (key.startsWith('payload.')) {
+      filters[key.slice('payload.'.length)] = value;
+    }
+  }
+  return Object.keys(filters).length > 0 ? filters : undefined;
+}
+
+// Who is reading themes: private ones are only for their author or an admin.
+// An invalid token reads as anonymous rather than failing the request.
+function themeViewerOf(req: Request): ThemeViewer {
+  const auth = authenticateRequest(req);
+  return {
+    subject: auth?.ok ? auth.subject : undefined,
+    admin: authenticateAdmin(req).ok
+  };
+}
+
+// Parse limit/offset, clamping limit to MAX_PAGE_SIZE. Missing, zero or
+// negative limits fall back to the default; negative offsets become 0.
+function parsePagination(params: URLSearchParams, defaultLimit: number = config.DEFAULT_PAGE_SIZE): { limit: number; offset: number } {
+  const rawLimit = parseInt(params.get('limit') || '');
+  const rawOffset = parseInt(params.get('offset') || '');
+  const limit = isNaN(rawLimit) || rawLimit <= 0 ? defaultLimit : rawLimit;
+  
+  return {
+    limit: Math.min(limit, config.MAX_PAGE_SIZE),
+    offset: isNaN(rawOffset) || rawOffset < 0 ? 0 : rawOffset
+  };
+}
+
+// Methods served per path, used to tell 404 from 405 for unmatched requests
+const ROUTE_METHODS: [RegExp, string[]][] = [
+  [/^\/$/, ['GET']],
+  [/^\/(health|livez|readyz)$/, ['GET']],
+  [/^\/metrics$/, ['GET']],
+  [/^\/config$/, ['GET']],
+  [/^\/admin\/events\/prune$/, ['POST']],
+  [/^\/admin\/ws\/clients$/, ['GET']],
+  [/^\/admin\/read-only$/, ['GET', 'PUT']],
+  [/^\/admin\/themes\/[^\/]+\/featured$/, ['PUT']],
+  [/^\/stream$/, ['GET']],
+  [/^\/stream\/subscriptions\/preview$/, ['GET']],
+  [/^\/events$/, ['GET', 'POST']],
+  [/^\/events\/validate$/, ['POST']],
+  [/^\/events\/(filter-options|count|recent|latest|since|duplicates|top-sessions|stream|stats|timeline|export\.csv|search|notifications|sessions)$/, ['GET']],
+  [/^\/events\/sessions\/[^\/]+$/, ['GET', 'DELETE']],
+  [/^\/events\/sessions\/[^\/]+\/trace$/, ['GET']],
+  [/^\/events\/\d+$/, ['GET', 'DELETE']],
+  [/^\/events\/\d+\/(summary|chat)$/, ['PATCH']],
+  [/^\/apps\/[^\/]+\/coverage$/, ['GET']],
+  [/^\/api\/themes$/, ['GET', 'POST']],
+  [/^\/api\/themes\/(stats|tags|featured)$/, ['GET']],
+  [/^\/api\/themes\/(import|bulk|generate)$/, ['POST']],
+  [/^\/api\/themes\/[^\/]+$/, ['GET', 'PUT', 'DELETE']],
+  [/^\/api\/themes\/[^\/]+\/export$/, ['GET']],
+  [/^\/api\/themes\/[^\/]+\/clone$/, ['POST']],
+  [/^\/api\/authors\/[^\/]+$/, ['PUT']],
+  [/^\/api\/authors\/[^\/]+\/themes$/, ['GET']]
+];
+
+function allowedMethods(pathname: string): string[] {
+  const match = ROUTE_METHODS.find(([pattern]) => pattern.test(pathname));
+  return match ? match[1] : [];
+}
+
+// Set once a shutdown signal arrives; /readyz then reports not ready
+let draining = false;
+
+export function setDraining(value: boolean): void {
+  draining = value;
+}
+
+// Maintenance mode: writes get 503. Starts from READ_ONLY and can be
+// toggled at runtime via PUT /admin/read-only.
+let readOnly = config.READ_ONLY;
+const WRITE_METHODS = new Set(['POST', 'PUT', 'PATCH', 'DELETE']);
+
+// Streaming endpoints stay open indefinitely and are exempt from REQUEST_TIMEOUT_MS
+const UNTIMED_PATHS = new Set(['/stream', '/events/stream', '/events/export.csv']);
+
+// Reject with RequestTimeoutError if the handler has not settled by the
+// deadline. bun:sqlite queries are synchronous and block the event loop, so
+// this cannot cut a slow query short: the 504 goes out once it returns. What
+// the deadline does bound is time spent awaiting (request bodies, busy-write
+// backoff, outbound calls). The handler itself keeps running after a 504, but
+// every query or write it starts past the deadline throws instead.
+function withDeadline<T>(promise: Promise<T>, deadline: number | undefined): Promise<T> {
+  if (deadline === undefined) return promise;
+  
+  let timer: ReturnType<typeof setTimeout>;
+  const timeout = new Promise<never>((_, reject) => {
+    timer = setTimeout(() => reject(new RequestTimeoutError()), Math.max(0, deadline - Date.now()));
+  });
+  return Promise.race([promise, timeout]).finally(() => clearTimeout(timer));
+}
+
+// Request-level handling around the routes: request id and deadline,
+// timeout and error mapping, metrics, content negotiation and compression
+function instrumented(route: (req: Request) => Promise<Response | undefined>): (req: Request) => Promise<Response | undefined> {
+  return async (req: Request) => {
+    const start = performance.now();
+    const url = new URL(req.url);
+    const requestId = req.headers.get('x-request-id') || crypto.randomUUID();
+    // Long-lived streams have no deadline
+    const timeoutMs = UNTIMED_PATHS.has(url.pathname) ? 0 : config.REQUEST_TIMEOUT_MS;
+    const deadline = timeoutMs > 0 ? Date.now() + timeoutMs : undefined;
+    const response = await runWithRequestId(requestId, async () => {
+      try {
+        return await withDeadline(route(req), deadline);
+      } catch (error) {
+        if (error instanceof RequestTimeoutError) {
+          logger.warn(`Request exceeded ${timeoutMs}ms: ${req.method} ${url.pathname}`);
+          return respondError(corsHeaders(req), 504, 'TIMEOUT', 'Request timed out', { requestId });
+        }
+        if (error instanceof RequestAbortedError) {
+          // Nobody is listening; the status is only for logs and metrics
+          logger.debug(`Client went away: ${req.method} ${url.pathname}`);
+          return new Response(null, { status: 499 });
+        }
+        logger.error('Unhandled error:', error);
+        if (isDatabaseError(error)) recordDbError();
+        return respondError(corsHeaders(req), 500, 'INTERNAL', 'Internal server error', { requestId });
+      }
+    }, deadline, UNTIMED_PATHS.has(url.pathname) ? undefined : req.signal);
+    
+    // Unmatched paths share one label so scanners cannot grow the histogram
+    const label = allowedMethods(url.pathname).length > 0 ? routeLabel(url.pathname) : 'other';
+    recordRequest(req.method, label, response?.status ?? 101, (performance.now() - start) / 1000);
+    if (!response) return response;
+    response.headers.set('X-Request-ID', requestId);
+    
+    // Event endpoints can answer in MessagePack instead of JSON, so every
+    // response from them, JSON ones included, varies by Accept
+    const negotiated = url.pathname.startsWith('/events');
+    if (negotiated) response.headers.append('Vary', 'Accept');
+    const encoded = negotiated && acceptsMsgpack(req) ? await toMsgpackResponse(response) : response;
+    return compressResponse(req, encoded);
+  };
+}
 
 // Create Bun server with HTTP and WebSocket support
-const server = Bun.serve({
+export const server = Bun.serve<ClientData>({
   port: config.PORT,
+  maxRequestBodySize: config.MAX_BODY_BYTES,
   
-  async fetch(req: Request) {
+  fetch: instrumented(async (req: Request) => {
     const url = new URL(req.url);
     
     // Handle CORS
-    const allowedOrigins = Array.isArray(config.CORS_ORIGINS) ? config.CORS_ORIGINS : [config.CORS_ORIGINS];
-    const requestOrigin = req.headers.get('origin');
-    const corsOrigin = allowedOrigins.includes('*') || allowedOrigins.includes(requestOrigin || '') ? (requestOrigin || '*') : 'null';
-    
-    const headers = {
-      'Access-Control-Allow-Origin': corsOrigin,
-      'Access-Control-Allow-Methods': 'GET, POST, PUT, DELETE, OPTIONS',
-      'Access-Control-Allow-Headers': 'Content-Type',
-    };
+    const headers = corsHeaders(req);
     
     // Handle preflight
     if (req.method === 'OPTIONS') {
       return new Response(null, { headers });
     }
     
+    // Reject oversized bodies before parsing them; chunked bodies are capped by
+    // Bun's maxRequestBodySize below
+    const contentLength = Number(req.headers.get('content-length') || 0);
+    if (contentLength > config.MAX_BODY_BYTES) {
+      return respondError(headers, 413, 'PAYLOAD_TOO_LARGE', `Request body exceeds ${config.MAX_BODY_BYTES} bytes`);
+    }
+    
+    // The toggle itself must stay reachable to switch the mode off, and the
+    // dry-run validator writes nothing, so both keep working
+    if (readOnly && WRITE_METHODS.has(req.method) && url.pathname !== '/admin/read-only' && url.pathname !== '/events/validate') {
+      return respondError(headers, 503, 'READ_ONLY', 'Server is in read-only maintenance mode; writes are disabled', undefined, { 'Retry-After': '60' });
+    }
+    
+    const unauthorized = (error: string) => respondError(headers, 401, 'UNAUTHORIZED', error, undefined, { 'WWW-Authenticate': 'Bearer' });
+    
     // POST /events - Receive new events
     if (url.pathname === '/events' && req.method === 'POST') {
+      let event: HookEvent;
+      try {
+        event = await req.json() as HookEvent;
+      } catch (error) {
+        logger.error('Error processing event:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Request body must be JSON');
+      }
+      if (!event || typeof event !== 'object' || Array.isArray(event)) {
+        return respondError(headers, 400, 'INVALID_BODY', 'Request body must be a JSON object');
+      }
+      
       try {
-        const event = await req.json() as HookEvent;
-        
         // Validate required fields
-        if (!event.source_app || !event.session_id || !event.hook_event_type || !event.payload) {
-          return new Response(JSON.stringify({ error: 'Missing required fields' }), {
-            status: 400,
+        const validationErrors = validateEvent(event, {
+          allowUnknownTypes: url.searchParams.get('allowUnknown') === 'true'
+        });
+        if (validationErrors.length > 0) {
+          return respondError(headers, 422, 'EVENT_INVALID', 'Validation failed', { validationErrors });
+        }
+        
+        // The limiter key comes from the body, so it runs after validation
+        const retryAfterMs = checkSourceRateLimit(event.source_app);
+        if (retryAfterMs > 0) {
+          return respondError(headers, 429, 'RATE_LIMITED', `Rate limit exceeded for source_app "${event.source_app}"`, 
+            { source_app: event.source_app }, { 'Retry-After': String(Math.ceil(retryAfterMs / 1000)) });
+        }
+        
+        // Dropped by sampling: accepted, but neither stored nor broadcast
+        if (!sampleEvent(event)) {
+          return new Response(JSON.stringify({ status: 'sampled_out' }), {
+            status: 202,
             headers: { ...headers, 'Content-Type': 'application/json' }
           });
         }
         
-        // Insert event into database
-        const savedEvent = insertEvent(event);
-        
-        // Broadcast to all WebSocket clients
-        const message = JSON.stringify({ type: 'event', data: savedEvent });
-        wsClients.forEach(client => {
+        if (hasEventProcessors()) {
           try {
-            client.send(message);
-          } catch (err) {
-            // Client disconnected, remove from set
-            wsClients.delete(client);
+            event = await runEventProcessors(event);
+          } catch (error) {
+            if (error instanceof EventRejectedError) {
+              return respondError(headers, 422, 'EVENT_REJECTED', error.message, { processor: error.processor });
+            }
+            return respondError(headers, 500, 'INTERNAL', 'Event processing failed');
           }
-        });
+          
+          // Processors may have changed anything, so check the event again
+          const processedErrors = validateEvent(event, { allowUnknownTypes: true });
+          if (processedErrors.length > 0) {
+            return respondError(headers, 422, 'EVENT_INVALID', 'Event processors produced an invalid event', { validationErrors: processedErrors });
+          }
+        }
+        event = capPayload({ ...event, payload: redactPayload(event.payload, 'write') });
+        
+        // In buffered mode the event is written by the background flush
+        if (isBufferedIngestion()) {
+          if (!enqueueEvent(event)) {
+            return respondError(headers, 503, 'INGEST_QUEUE_FULL', 'Ingestion queue is full, retry later', undefined, { 'Retry-After': '1' });
+          }
+          return new Response(JSON.stringify({ status: 'accepted' }), {
+            status: 202,
+            headers: { ...headers, 'Content-Type': 'application/json' }
+          });
+        }
+        
+        // Insert event into database; a repeated event_uuid returns the stored event
+        const { event: savedEvent, created } = await insertEvent(event);
+        if (created) {
+          onEventSaved(savedEvent);
+        }
         
         return new Response(JSON.stringify(savedEvent), {
-          headers: { ...headers, 'Content-Type': 'application/json' }
+          headers: { ...headers, 'Content-Type': 'application/json', 'Idempotent-Replayed': String(!created) }
         });
       } catch (error) {
-        console.error('Error processing event:', error);
-        return new Response(JSON.stringify({ error: 'Invalid request' }), {
-          status: 400,
+        // Timeouts, aborts and bugs go to the shared handler
+        if (!isDatabaseError(error)) throw error;
+        // Still failing after the busy retries: the client should try again
+        logger.error('Error processing event:', error);
+        recordDbError();
+        return respondError(headers, 503, 'DATABASE_UNAVAILABLE', 'Database unavailable, retry later', undefined, { 'Retry-After': '1' });
+      }
+    }
+    
+    // POST /events/validate - Dry run: validate an event as POST /events would, store nothing
+    if (url.pathname === '/events/validate' && req.method === 'POST') {
+      try {
+        const event = await req.json() as HookEvent;
+        const validationErrors = validateEvent(event, {
+          allowUnknownTypes: url.searchParams.get('allowUnknown') === 'true'
+        });
+        
+        return new Response(JSON.stringify({
+          valid: validationErrors.length === 0,
+          validationErrors,
+          // What would be stored, after redaction and payload truncation.
+          // Event processors are not run.
+          ...(validationErrors.length === 0 && { event: capPayload({ ...event, payload: redactPayload(event.payload, 'write') }) })
+        }), {
           headers: { ...headers, 'Content-Type': 'application/json' }
         });
+      } catch (error) {
+        return respondError(headers, 400, 'INVALID_BODY', 'Request body must be JSON');
       }
     }
     
+    // GET /health - Report database and WebSocket status
+    if (url.pathname === '/health' && req.method === 'GET') {
+      const databaseOk = pingDatabase();
+      return new Response(JSON.stringify({
+        status: databaseOk ? 'healthy' : 'unhealthy',
+        timestamp: Date.now(),
+        checks: {
+          database: databaseOk ? 'ok' : 'fail',
+          websocket_clients: getClientCount(),
+          ingest_queue_depth: getQueueDepth(),
+          ingest_dropped_events: getDroppedEventCount()
+        }
+      }), {
+        status: databaseOk ? 200 : 503,
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /livez - Liveness probe; answers as long as the process serves requests
+    if (url.pathname === '/livez' && req.method === 'GET') {
+      return new Response('ok', {
+        headers: { ...headers, 'Content-Type': 'text/plain' }
+      });
+    }
+    
+    // GET /readyz - Readiness probe; fails while draining so the load balancer
+    // stops routing new traffic here
+    if (url.pathname === '/readyz' && req.method === 'GET') {
+      const checks = {
+        database: pingDatabase() ? 'ok' : 'fail',
+        websocket: isSweepAlive() ? 'ok' : 'fail',
+        draining
+      };
+      const ready = checks.database === 'ok' && checks.websocket === 'ok' && !draining;
+      return new Response(JSON.stringify({ status: ready ? 'ready' : 'not_ready', checks }), {
+        status: ready ? 200 : 503,
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /config - Non-secret settings clients need (page sizes, intervals)
+    if (url.pathname === '/config' && req.method === 'GET') {
+      return new Response(JSON.stringify(publicConfig()), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /metrics - Prometheus scrape endpoint
+    if (url.pathname === '/metrics' && req.method === 'GET') {
+      return new Response(renderMetrics(), {
+        headers: { ...headers, 'Content-Type': 'text/plain; version=0.0.4' }
+      });
+    }
+    
+    // Soft-deleted events are only readable with the admin key
+    if (url.pathname.startsWith('/events') && url.searchParams.get('includeDeleted') === 'true') {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
+    }
+    
     // GET /events/filter-options - Get available filter options
     if (url.pathname === '/events/filter-options' && req.method === 'GET') {
-      const options = getFilterOptions();
+      const params = url.searchParams;
+      const limitParam = (name: string): number | undefined => {
+        const value = parseInt(params.get(name) || params.get('limit') || '');
+        return isNaN(value) || value <= 0 ? undefined : Math.min(value, config.MAX_PAGE_SIZE);
+      };
+      const query: FilterOptionsQuery = {
+        sourceAppPrefix: params.get('sourceAppPrefix') || undefined,
+        sourceAppLimit: limitParam('sourceAppLimit'),
+        sessionPrefix: params.get('sessionPrefix') || undefined,
+        sessionLimit: limitParam('sessionLimit'),
+        hookEventTypePrefix: params.get('hookEventTypePrefix') || undefined,
+        hookEventTypeLimit: limitParam('hookEventTypeLimit')
+      };
+      // Prefix searches are typed interactively, so only unfiltered lookups are cached
+      const isPrefixSearch = Boolean(query.sourceAppPrefix || query.sessionPrefix || query.hookEventTypePrefix);
+      const [options, hit] = isPrefixSearch
+        ? [getFilterOptions(query), false]
+        : await cached(`events:filter-options:${JSON.stringify(query)}`, () => getFilterOptions(query));
       return new Response(JSON.stringify(options), {
+        headers: { ...headers, 'Content-Type': 'application/json', 'X-Cache': hit ? 'HIT' : 'MISS' }
+      });
+    }
+    
+    // GET /events/count - Get the total number of events
+    if (url.pathname === '/events/count' && req.method === 'GET') {
+      const includeDeleted = url.searchParams.get('includeDeleted') === 'true';
+      const includeExpired = url.searchParams.get('includeExpired') === 'true';
+      const [count, hit] = await cached(`events:count:${includeDeleted}:${includeExpired}`, () => countEvents({ includeDeleted, includeExpired }));
+      return new Response(JSON.stringify({ count }), {
+        headers: { ...headers, 'Content-Type': 'application/json', 'X-Cache': hit ? 'HIT' : 'MISS' }
+      });
+    }
+    
+    // Reject malformed payload.<path> filters before any event query runs
+    const badPayloadPath = [...url.searchParams.keys()]
+      .find(key => key.startsWith('payload.') && !isPayloadPath(key.slice('payload.'.length)));
+    if (badPayloadPath && url.pathname.startsWith('/events')) {
+      return respondError(headers, 400, 'INVALID_PARAMETER', `Invalid payload filter "${badPayloadPath}": use dotted field names like payload.tool_input.command`);
+    }
+    // SQL cannot see inside gzipped payloads; refuse rather than silently
+    // leave those events out
+    const hasPayloadFilter = [...url.searchParams.keys()].some(key => key.startsWith('payload.'));
+    if (hasPayloadFilter && url.pathname.startsWith('/events') && !payloadFiltersSupported()) {
+      return respondError(headers, 400, 'INVALID_PARAMETER', 'payload.<path> filters are unavailable while payloads are stored compressed (COMPRESS_PAYLOADS)');
+    }
+    // Matching a value that reads back masked would confirm guesses at it
+    const redactedPayloadPath = [...url.searchParams.keys()]
+      .find(key => key.startsWith('payload.') && isRedactedOnRead(key.slice('payload.'.length)));
+    if (redactedPayloadPath && url.pathname.startsWith('/events')) {
+      return respondError(headers, 400, 'INVALID_PARAMETER', `Cannot filter on redacted field "${redactedPayloadPath}"`);
+    }
+    
+    // GET /events - List events matching filters, including payload.<path>=<value>
+    if (url.pathname === '/events' && req.method === 'GET') {
+      const { limit, offset } = parsePagination(url.searchParams);
+      const events = getRecentEvents(limit, offset, eventFilterFromParams(url.searchParams));
+      return new Response(JSON.stringify(events), {
         headers: { ...headers, 'Content-Type': 'application/json' }
       });
     }
     
     // GET /events/recent - Get recent events
     if (url.pathname === '/events/recent' && req.method === 'GET') {
-      const limit = parseInt(url.searchParams.get('limit') || '100');
-      const events = getRecentEvents(limit);
+      const { limit, offset } = parsePagination(url.searchParams);
+      const includeDeleted = url.searchParams.get('includeDeleted') === 'true';
+      const includeExpired = url.searchParams.get('includeExpired') === 'true';
+      
+      // Sparse fieldsets: ?fields=id,timestamp,hook_event_type
+      const fieldsParam = url.searchParams.get('fields');
+      const selection = fieldsParam !== null ? parseEventFields(fieldsParam) : null;
+      if (selection && 'unknown' in selection) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', `Unknown fields: ${selection.unknown.join(', ')}`, { allowed: EVENT_FIELDS });
+      }
+      const project = (events: HookEvent[]) => selection?.fields.length ? events.map(event => projectEvent(event, selection.fields)) : events;
+      
+      // The ETag is the highest matching event id, so it changes with every
+      // new event; it is weak because the body's encoding may vary. Edits and
+      // deletes of existing events move neither validator, so pollers only
+      // learn of new events this way.
+      const etag = `W/"${getMaxEventId({ includeDeleted, includeExpired })}"`;
+      const cacheHeaders: Record<string, string> = { 'ETag': etag };
+      // Last-Modified has one-second resolution, so it is only sent once the
+      // newest event's second is over; another event could still land in it
+      const newest = getLatestEvent({ includeDeleted, includeExpired })?.timestamp;
+      if (newest !== undefined && Math.floor(newest / 1000) < Math.floor(Date.now() / 1000)) {
+        cacheHeaders['Last-Modified'] = new Date(newest).toUTCString();
+      }
+      
+      // If-None-Match takes precedence; If-Modified-Since is only consulted
+      // without it (RFC 9110 13.2.2)
+      const ifNoneMatch = req.headers.get('if-none-match');
+      const since = Date.parse(req.headers.get('if-modified-since') || '');
+      const notModified = ifNoneMatch !== null
+        ? ifNoneMatch.trim() === '*' || ifNoneMatch.split(',').some(tag => tag.trim().replace(/^W\//, '') === etag.replace(/^W\//, ''))
+        : cacheHeaders['Last-Modified'] !== undefined && !isNaN(since) && Math.floor(newest! / 1000) * 1000 <= since;
+      if (notModified) {
+        return new Response(null, { status: 304, headers: { ...headers, ...cacheHeaders } });
+      }
+      
+      // Cursor-based paging (before_id) is stable under concurrent inserts;
+      // plain limit/offset is kept for existing clients.
+      const beforeId = url.searchParams.get('before_id');
+      if (beforeId !== null) {
+        const cursor = beforeId === '' ? undefined : parseInt(beforeId);
+        if (cursor !== undefined && isNaN(cursor)) {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'Invalid before_id');
+        }
+        const page = getEventsBefore(cursor, limit, { includeDeleted, includeExpired });
+        return new Response(JSON.stringify({ ...page, data: project(page.data) }), {
+          headers: { ...headers, 'Content-Type': 'application/json', ...cacheHeaders }
+        });
+      }
+      
+      const events = getRecentEvents(limit, offset, { includeDeleted, includeExpired });
+      
+      // Opt-in envelope with paging metadata; the bare array stays the default
+      if (url.searchParams.get('envelope') === 'true') {
+        const total = countEvents({ includeDeleted, includeExpired });
+        return new Response(JSON.stringify({
+          data: project(events),
+          total,
+          limit,
+          offset,
+          hasMore: offset + events.length < total
+        }), {
+          headers: { ...headers, 'Content-Type': 'application/json', ...cacheHeaders }
+        });
+      }
+      
+      return new Response(JSON.stringify(project(events)), {
+        headers: { ...headers, 'Content-Type': 'application/json', ...cacheHeaders }
+      });
+    }
+    
+    // GET /events/latest - The single newest event; 204 when there is none
+    if (url.pathname === '/events/latest' && req.method === 'GET') {
+      const event = getLatestEvent(eventFilterFromParams(url.searchParams));
+      if (!event) {
+        return new Response(null, { status: 204, headers });
+      }
+      
+      return new Response(JSON.stringify(event), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/since?id=N - Events after a known id, oldest first, for clients
+    // filling a gap in the live feed
+    if (url.pathname === '/events/since' && req.method === 'GET') {
+      const afterId = parseInt(url.searchParams.get('id') || '');
+      if (isNaN(afterId) || afterId < 0) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'id must be a non-negative integer');
+      }
+      
+      const { limit } = parsePagination(url.searchParams, config.MAX_PAGE_SIZE);
+      // Fetch one extra row to tell whether the result was capped
+      const events = getEventsAfter(afterId, limit + 1, eventFilterFromParams(url.searchParams));
+      const truncated = events.length > limit;
+      const data = truncated ? events.slice(0, limit) : events;
+      
+      return new Response(JSON.stringify({
+        data,
+        truncated,
+        lastId: data.length > 0 ? data[data.length - 1]!.id : afterId
+      }), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/stream - Server-sent events feed (WebSocket alternative)
+    if (url.pathname === '/events/stream' && req.method === 'GET') {
+      const auth = authenticateStream(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
+      // Long-lived response; exempt it from the idle timeout
+      server.timeout(req, 0);
+      return openSseStream(req, headers);
+    }
+    
+    // GET /events/stats - Get event counts grouped by type, source and session
+    if (url.pathname === '/events/stats' && req.method === 'GET') {
+      const since = url.searchParams.get('since');
+      if (since && isNaN(parseInt(since))) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'since must be a millisecond timestamp');
+      }
+      
+      const stats = getEventStats(since ? parseInt(since) : undefined);
+      return new Response(JSON.stringify(stats), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/duplicates - Repeated identical events, to spot runaway loops
+    if (url.pathname === '/events/duplicates' && req.method === 'GET') {
+      const since = url.searchParams.get('since');
+      const until = url.searchParams.get('until');
+      const minCount = parseInt(url.searchParams.get('min_count') || '2');
+      if ((since && isNaN(parseInt(since))) || (until && isNaN(parseInt(until))) || isNaN(minCount) || minCount < 2) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'since/until must be millisecond timestamps and min_count an integer >= 2');
+      }
+      
+      // Default window is the last hour
+      const windowStart = since ? parseInt(since) : Date.now() - 60 * 60 * 1000;
+      const { limit } = parsePagination(url.searchParams);
+      const groups = getDuplicateGroups(windowStart, until ? parseInt(until) : undefined, minCount, limit);
+      return new Response(JSON.stringify(groups), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/top-sessions - Get the most active sessions in a window
+    if (url.pathname === '/events/top-sessions' && req.method === 'GET') {
+      const since = url.searchParams.get('since');
+      if (since && isNaN(parseInt(since))) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'since must be a millisecond timestamp');
+      }
+      
+      // Default window is the last hour
+      const windowStart = since ? parseInt(since) : Date.now() - 60 * 60 * 1000;
+      const { limit } = parsePagination(url.searchParams);
+      const sessions = getTopSessions(windowStart, limit);
+      return new Response(JSON.stringify(sessions), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/timeline - Get event counts bucketed over time
+    if (url.pathname === '/events/timeline' && req.method === 'GET') {
+      const bucket = parseInt(url.searchParams.get('bucket') || '60');
+      const since = url.searchParams.get('since');
+      const until = url.searchParams.get('until');
+      if (isNaN(bucket) || bucket <= 0) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'bucket must be a positive number of seconds');
+      }
+      if ((since && isNaN(parseInt(since))) || (until && isNaN(parseInt(until)))) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'since and until must be millisecond timestamps');
+      }
+      
+      const timeline = getEventTimeline(
+        bucket,
+        since ? parseInt(since) : undefined,
+        until ? parseInt(until) : undefined
+      );
+      return new Response(JSON.stringify(timeline), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/export.csv - Stream events as CSV
+    if (url.pathname === '/events/export.csv' && req.method === 'GET') {
+      const events = iterateFilteredEvents(eventFilterFromParams(url.searchParams));
+      return new Response(eventsCsvStream(events), {
+        headers: { 
+          ...headers, 
+          'Content-Type': 'text/csv; charset=utf-8',
+          'Content-Disposition': 'attachment; filename="events.csv"'
+        }
+      });
+    }
+    
+    // GET /events/search - Search event payloads
+    if (url.pathname === '/events/search' && req.method === 'GET') {
+      const q = url.searchParams.get('q');
+      if (!q) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'Query parameter q is required');
+      }
+      
+      const { limit, offset } = parsePagination(url.searchParams);
+      const events = searchEvents(q, limit, offset, { 
+        includeDeleted: url.searchParams.get('includeDeleted') === 'true',
+        includeExpired: url.searchParams.get('includeExpired') === 'true'
+      });
+      return new Response(JSON.stringify(events), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/notifications - Get Notification events in a time range
+    if (url.pathname === '/events/notifications' && req.method === 'GET') {
+      const start = url.searchParams.get('start');
+      const end = url.searchParams.get('end');
+      if ((start && isNaN(parseInt(start))) || (end && isNaN(parseInt(end)))) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'start and end must be millisecond timestamps');
+      }
+      
+      const events = getFilteredEvents({
+        hook_event_type: 'Notification',
+        start: start ? parseInt(start) : undefined,
+        end: end ? parseInt(end) : undefined
+      });
       return new Response(JSON.stringify(events), {
         headers: { ...headers, 'Content-Type': 'application/json' }
       });
     }
     
+    // GET /events/sessions - Get per-session summaries
+    if (url.pathname === '/events/sessions' && req.method === 'GET') {
+      const { limit, offset } = parsePagination(url.searchParams);
+      const sessions = getSessionSummaries(limit, offset);
+      return new Response(JSON.stringify(sessions), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/sessions/:id - Get a session's events in chronological order
+    if (url.pathname.match(/^\/events\/sessions\/[^\/]+$/) && req.method === 'GET') {
+      const sessionId = decodeURIComponent(url.pathname.split('/')[3]!);
+      const { limit, offset } = parsePagination(url.searchParams);
+      const events = getEventsBySession(sessionId, limit, offset);
+      return new Response(JSON.stringify(events), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // DELETE /events/sessions/:id - Purge all of a session's events (admin)
+    if (url.pathname.match(/^\/events\/sessions\/[^\/]+$/) && req.method === 'DELETE') {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
+      
+      const sessionId = decodeURIComponent(url.pathname.split('/')[3]!);
+      const deleted = await deleteSession(sessionId);
+      if (deleted === 0) {
+        return respondError(headers, 404, 'SESSION_NOT_FOUND', 'Session not found');
+      }
+      
+      forgetSession(sessionId);
+      invalidateCache('events:');
+      logger.info(`Deleted session ${sessionId} (${deleted} events)`);
+      broadcast({ type: 'session_deleted', data: { session_id: sessionId, deleted } });
+      
+      return new Response(JSON.stringify({ session_id: sessionId, deleted }), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/sessions/:id/trace - Get a session's events nested by tool call
+    if (url.pathname.match(/^\/events\/sessions\/[^\/]+\/trace$/) && req.method === 'GET') {
+      const sessionId = decodeURIComponent(url.pathname.split('/')[3]!);
+      const events = getFilteredEvents({ session_id: sessionId }, 10000);
+      if (events.length === 0) {
+        return respondError(headers, 404, 'SESSION_NOT_FOUND', 'Session not found');
+      }
+      
+      return new Response(JSON.stringify({ session_id: sessionId, trace: buildSessionTrace(events) }), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /events/:id - Get a single event with its full payload
+    if (url.pathname.match(/^\/events\/\d+$/) && req.method === 'GET') {
+      const id = parseInt(url.pathname.split('/')[2]!);
+      const event = getEventById(id, url.searchParams.get('includeDeleted') === 'true', url.searchParams.get('includeExpired') === 'true');
+      if (!event) {
+        return respondError(headers, 404, 'EVENT_NOT_FOUND', 'Event not found');
+      }
+      
+      return new Response(JSON.stringify(event), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /apps/:sourceApp/coverage - Report which known hook types an app emits
+    if (url.pathname.match(/^\/apps\/[^\/]+\/coverage$/) && req.method === 'GET') {
+      const sourceApp = decodeURIComponent(url.pathname.split('/')[2]!);
+      const emitted = getEventTypeCounts(sourceApp);
+      const seen = new Set(emitted.map(row => row.hook_event_type));
+      
+      const coverage: HookCoverage = {
+        source_app: sourceApp,
+        emitted,
+        missing: HOOK_EVENT_TYPES.filter(type => !seen.has(type))
+      };
+      return new Response(JSON.stringify(coverage), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // PATCH /events/:id/summary - Attach a summary to an existing event
+    if (url.pathname.match(/^\/events\/\d+\/summary$/) && req.method === 'PATCH') {
+      const id = parseInt(url.pathname.split('/')[2]!);
+      try {
+        const body = await req.json() as { summary?: unknown };
+        if (typeof body.summary !== 'string') {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'summary must be a string');
+        }
+        
+        if (!(await updateEventSummary(id, body.summary))) {
+          return respondError(headers, 404, 'EVENT_NOT_FOUND', 'Event not found');
+        }
+        
+        const updatedEvent = getEventById(id)!;
+        broadcast({ type: 'event_updated', data: toBroadcastEvent(updatedEvent) });
+        return new Response(JSON.stringify(updatedEvent), {
+          headers: { ...headers, 'Content-Type': 'application/json' }
+        });
+      } catch (error) {
+        logger.error('Error updating event summary:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // PATCH /events/:id/chat - Append messages to an event's chat transcript
+    if (url.pathname.match(/^\/events\/\d+\/chat$/) && req.method === 'PATCH') {
+      const id = parseInt(url.pathname.split('/')[2]!);
+      try {
+        const body = await req.json() as { messages?: unknown };
+        if (!Array.isArray(body.messages)) {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'messages must be an array');
+        }
+        
+        if (!(await appendEventChat(id, body.messages))) {
+          return respondError(headers, 404, 'EVENT_NOT_FOUND', 'Event not found');
+        }
+        
+        const updatedEvent = getEventById(id)!;
+        broadcast({ type: 'event_updated', data: toBroadcastEvent(updatedEvent) });
+        return new Response(JSON.stringify(updatedEvent), {
+          headers: { ...headers, 'Content-Type': 'application/json' }
+        });
+      } catch (error) {
+        logger.error('Error appending event chat:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // DELETE /events/:id - Soft delete an event (admin)
+    if (url.pathname.match(/^\/events\/\d+$/) && req.method === 'DELETE') {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
+      
+      const id = parseInt(url.pathname.split('/')[2]!);
+      if (!(await softDeleteEvent(id))) {
+        return respondError(headers, 404, 'EVENT_NOT_FOUND', 'Event not found');
+      }
+      
+      invalidateCache('events:');
+      return new Response(JSON.stringify({ success: true, id }), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
     // Theme API endpoints
     
     // POST /api/themes - Create a new theme
     if (url.pathname === '/api/themes' && req.method === 'POST') {
+      const auth = authenticateRequest(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
       try {
         const themeData = await req.json();
+        // The authenticated subject is the author, never the request body
+        if (auth) themeData.authorId = auth.subject;
         const result = await createTheme(themeData);
+        if (result.success) invalidateCache('themes:');
         
-        const status = result.success ? 201 : 400;
+        return respondApiResult(headers, result, 201);
+      } catch (error) {
+        logger.error('Error creating theme:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // POST /api/themes/bulk - Create many themes; per-theme results in input order
+    if (url.pathname === '/api/themes/bulk' && req.method === 'POST') {
+      const auth = authenticateRequest(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
+      try {
+        const themesData = await req.json();
+        if (!Array.isArray(themesData) || themesData.length === 0) {
+          return respondError(headers, 400, 'INVALID_BODY', 'Request body must be a non-empty array of themes');
+        }
+        if (themesData.length > config.MAX_PAGE_SIZE) {
+          return respondError(headers, 413, 'PAYLOAD_TOO_LARGE', `At most ${config.MAX_PAGE_SIZE} themes per request`);
+        }
+        
+        const result = await bulkCreateThemes(themesData, auth?.subject);
+        const created = result.data?.filter(item => item.success).length ?? 0;
+        if (created > 0) invalidateCache('themes:');
+        
+        // 207 when some entries failed; the body says which
+        if (!result.data) return respondApiResult(headers, result);
+        const status = result.success ? 201 : created > 0 ? 207 : 400;
         return new Response(JSON.stringify(result), {
           status,
           headers: { ...headers, 'Content-Type': 'application/json' }
         });
       } catch (error) {
-        console.error('Error creating theme:', error);
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Invalid request body' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        logger.error('Error bulk creating themes:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
       }
     }
     
-    // GET /api/themes - Search themes
+    // POST /api/themes/generate - Derive a full palette from a seed color; ?save=true stores it
+    if (url.pathname === '/api/themes/generate' && req.method === 'POST') {
+      const save = url.searchParams.get('save') === 'true';
+      const auth = save ? authenticateRequest(req) : null;
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
+      try {
+        const options = await req.json();
+        if (!options || typeof options !== 'object') {
+          return respondError(headers, 400, 'INVALID_BODY', 'Request body must be an object');
+        }
+        
+        const result = await generateTheme(options, save, auth?.subject);
+        if (save && result.success) invalidateCache('themes:');
+        
+        return respondApiResult(headers, result, save ? 201 : 200);
+      } catch (error) {
+        logger.error('Error generating theme:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // GET /api/themes - Search themes, or ?ids=a,b,c to fetch specific themes
     if (url.pathname === '/api/themes' && req.method === 'GET') {
+      const viewer = themeViewerOf(req);
+      
+      const idsParam = url.searchParams.get('ids');
+      if (idsParam !== null) {
+        const ids = idsParam.split(',').map(id => id.trim()).filter(Boolean);
+        if (ids.length > config.MAX_PAGE_SIZE) {
+          return respondError(headers, 400, 'INVALID_PARAMETER', `At most ${config.MAX_PAGE_SIZE} ids per request`);
+        }
+        return respondApiResult(headers, await getThemesByIdList(ids, viewer));
+      }
+      
+      const { limit, offset } = parsePagination(url.searchParams);
       const query = {
         query: url.searchParams.get('query') || undefined,
         isPublic: url.searchParams.get('isPublic') ? url.searchParams.get('isPublic') === 'true' : undefined,
         authorId: url.searchParams.get('authorId') || undefined,
         sortBy: url.searchParams.get('sortBy') as any || undefined,
         sortOrder: url.searchParams.get('sortOrder') as any || undefined,
-        limit: url.searchParams.get('limit') ? parseInt(url.searchParams.get('limit')!) : undefined,
-        offset: url.searchParams.get('offset') ? parseInt(url.searchParams.get('offset')!) : undefined,
+        limit,
+        offset,
       };
       
-      const result = await searchThemes(query);
+      const result = await searchThemes(query, viewer);
+      return respondApiResult(headers, result);
+    }
+    
+    // GET /api/themes/stats - Get theme statistics
+    if (url.pathname === '/api/themes/stats' && req.method === 'GET') {
+      const [result, hit] = await cached('themes:stats', getThemeStats);
       return new Response(JSON.stringify(result), {
-        headers: { ...headers, 'Content-Type': 'application/json' }
+        headers: { ...headers, 'Content-Type': 'application/json', 'X-Cache': hit ? 'HIT' : 'MISS' }
+      });
+    }
+    
+    // GET /api/themes/featured - Curated public themes, best rated first
+    if (url.pathname === '/api/themes/featured' && req.method === 'GET') {
+      const { limit, offset } = parsePagination(url.searchParams);
+      const [result, hit] = await cached(`themes:featured:${limit}:${offset}`, () => getFeaturedThemes(limit, offset));
+      if (!result.success) return respondApiResult(headers, result);
+      return new Response(JSON.stringify(result), {
+        headers: { ...headers, 'Content-Type': 'application/json', 'X-Cache': hit ? 'HIT' : 'MISS' }
+      });
+    }
+    
+    // GET /api/themes/tags - List tags in use, optionally with counts
+    if (url.pathname === '/api/themes/tags' && req.method === 'GET') {
+      const withCounts = url.searchParams.get('counts') === 'true';
+      const [result, hit] = await cached(`themes:tags:${withCounts}`, () => getAllThemeTags(withCounts));
+      if (!result.success) return respondApiResult(headers, result);
+      return new Response(JSON.stringify(result), {
+        headers: { ...headers, 'Content-Type': 'application/json', 'X-Cache': hit ? 'HIT' : 'MISS' }
       });
     }
     
     // GET /api/themes/:id - Get a specific theme
-    if (url.pathname.startsWith('/api/themes/') && req.method === 'GET') {
+    if (url.pathname.match(/^\/api\/themes\/[^\/]+$/) && req.method === 'GET') {
       const id = url.pathname.split('/')[3];
       if (!id) {
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Theme ID is required' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'Theme ID is required');
+      }
+      
+      // The ETag is checked before the download is counted, so a 304 does not
+      // count as one
+      const result = await getThemeById(id, themeViewerOf(req), req.headers.get('if-none-match'));
+      if (!result.success || !result.data) return respondApiResult(headers, result);
+      
+      const etag = themeETag(result.data);
+      if (result.notModified) {
+        return new Response(null, { status: 304, headers: { ...headers, 'ETag': etag } });
       }
       
-      const result = await getThemeById(id);
-      const status = result.success ? 200 : 404;
       return new Response(JSON.stringify(result), {
-        status,
-        headers: { ...headers, 'Content-Type': 'application/json' }
+        headers: { ...headers, 'Content-Type': 'application/json', 'ETag': etag }
       });
     }
     
     // PUT /api/themes/:id - Update a theme
-    if (url.pathname.startsWith('/api/themes/') && req.method === 'PUT') {
+    if (url.pathname.match(/^\/api\/themes\/[^\/]+$/) && req.method === 'PUT') {
       const id = url.pathname.split('/')[3];
       if (!id) {
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Theme ID is required' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'Theme ID is required');
       }
       
+      const auth = authenticateRequest(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
       try {
         const updates = await req.json();
-        const result = await updateThemeById(id, updates);
         
-        const status = result.success ? 200 : 400;
-        return new Response(JSON.stringify(result), {
-          status,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        // Clients must echo the updatedAt they last saw
+        if (typeof updates.updatedAt !== 'number') {
+          return respondError(headers, 428, 'THEME_VERSION_REQUIRED', 'updatedAt of the theme being edited is required', {
+            validationErrors: [{ field: 'updatedAt', message: 'updatedAt is required', code: 'REQUIRED' }]
+          });
+        }
+        
+        const result = await updateThemeById(id, updates, auth?.subject, updates.updatedAt);
+        if (result.success) invalidateCache('themes:');
+        
+        return respondApiResult(headers, result);
       } catch (error) {
-        console.error('Error updating theme:', error);
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Invalid request body' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        logger.error('Error updating theme:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
       }
     }
     
     // DELETE /api/themes/:id - Delete a theme
-    if (url.pathname.startsWith('/api/themes/') && req.method === 'DELETE') {
+    if (url.pathname.match(/^\/api\/themes\/[^\/]+$/) && req.method === 'DELETE') {
       const id = url.pathname.split('/')[3];
       if (!id) {
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Theme ID is required' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'Theme ID is required');
       }
       
-      const authorId = url.searchParams.get('authorId');
+      const auth = authenticateRequest(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
+      const authorId = auth ? auth.subject : url.searchParams.get('authorId');
       const result = await deleteThemeById(id, authorId || undefined);
+      if (result.success) invalidateCache('themes:');
       
-      const status = result.success ? 200 : (result.error?.includes('not found') ? 404 : 403);
-      return new Response(JSON.stringify(result), {
-        status,
-        headers: { ...headers, 'Content-Type': 'application/json' }
-      });
+      return respondApiResult(headers, result);
     }
     
     // GET /api/themes/:id/export - Export a theme
@@ -224,23 +1088,11 @@ const server = Bun.serve({
       const id = url.pathname.split('/')[3];
       
       if (!id) {
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Theme ID is required' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        return respondError(headers, 400, 'INVALID_PARAMETER', 'Theme ID is required');
       }
       
-      const result = await exportThemeById(id);
-      if (!result.success) {
-        const status = result.error?.includes('not found') ? 404 : 400;
-        return new Response(JSON.stringify(result), {
-          status,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
-      }
+      const result = await exportThemeById(id, themeViewerOf(req));
+      if (!result.success) return respondApiResult(headers, result);
       
       return new Response(JSON.stringify(result.data), {
         headers: { 
@@ -253,73 +1105,246 @@ const server = Bun.serve({
     
     // POST /api/themes/import - Import a theme
     if (url.pathname === '/api/themes/import' && req.method === 'POST') {
+      const auth = authenticateRequest(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
       try {
         const importData = await req.json();
-        const authorId = url.searchParams.get('authorId');
+        const authorId = auth ? auth.subject : url.searchParams.get('authorId');
+        const overwrite = url.searchParams.get('overwrite') === 'true';
         
-        const result = await importTheme(importData, authorId || undefined);
+        const result = await importTheme(importData, authorId || undefined, overwrite);
+        if (result.success) invalidateCache('themes:');
         
-        const status = result.success ? 201 : 400;
-        return new Response(JSON.stringify(result), {
-          status,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        return respondApiResult(headers, result, 201);
       } catch (error) {
-        console.error('Error importing theme:', error);
-        return new Response(JSON.stringify({ 
-          success: false, 
-          error: 'Invalid import data' 
-        }), {
-          status: 400,
-          headers: { ...headers, 'Content-Type': 'application/json' }
-        });
+        logger.error('Error importing theme:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid import data');
       }
     }
     
-    // GET /api/themes/stats - Get theme statistics
-    if (url.pathname === '/api/themes/stats' && req.method === 'GET') {
-      const result = await getThemeStats();
-      return new Response(JSON.stringify(result), {
+    // POST /api/themes/:id/clone - Create a new theme from an existing one
+    if (url.pathname.match(/^\/api\/themes\/[^\/]+\/clone$/) && req.method === 'POST') {
+      const auth = authenticateRequest(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
+      try {
+        const id = url.pathname.split('/')[3];
+        const body = await req.text();
+        const options = body.trim() ? JSON.parse(body) : {};
+        
+        const result = await cloneTheme(id, options, auth?.subject);
+        if (result.success) invalidateCache('themes:');
+        
+        return respondApiResult(headers, result, 201);
+      } catch (error) {
+        logger.error('Error cloning theme:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // GET /api/authors/:id/themes - An author's profile and themes
+    if (url.pathname.match(/^\/api\/authors\/[^\/]+\/themes$/) && req.method === 'GET') {
+      const authorId = decodeURIComponent(url.pathname.split('/')[3]!);
+      const result = await getAuthorThemes(authorId, themeViewerOf(req));
+      return respondApiResult(headers, result);
+    }
+    
+    // PUT /api/authors/:id - Rename an author: the author themselves (JWT) or
+    // an admin (API_KEY). Closed while neither is configured.
+    if (url.pathname.match(/^\/api\/authors\/[^\/]+$/) && req.method === 'PUT') {
+      const authorId = decodeURIComponent(url.pathname.split('/')[3]!);
+      const admin = authenticateAdmin(req);
+      if (!admin.ok) {
+        const auth = authenticateRequest(req);
+        if (!auth) return unauthorized(config.API_KEY ? admin.error : 'Author renames are disabled; set JWT_SECRET or API_KEY to enable them');
+        if (!auth.ok) return unauthorized(auth.error);
+        if (auth.subject !== authorId) {
+          return respondError(headers, 403, 'FORBIDDEN', 'You can only rename yourself');
+        }
+      }
+      
+      try {
+        const body = await req.json() as { name?: unknown };
+        const result = await renameAuthor(authorId, body.name);
+        if (result.success) invalidateCache('themes:');
+        return respondApiResult(headers, result);
+      } catch (error) {
+        logger.error('Error updating author:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // GET /stream/subscriptions/preview - Validate a subscription filter and count matches
+    if (url.pathname === '/stream/subscriptions/preview' && req.method === 'GET') {
+      const filterKeys = ['source_app', 'session_id', 'hook_event_type'];
+      const unknownKeys = [...url.searchParams.keys()].filter(key => !filterKeys.includes(key));
+      if (unknownKeys.length > 0) {
+        return respondError(headers, 400, 'INVALID_PARAMETER', `Unknown filter fields: ${unknownKeys.join(', ')}`, { valid: false, unknownFields: unknownKeys });
+      }
+      
+      const filter = eventFilterFromParams(url.searchParams);
+      
+      return new Response(JSON.stringify({ 
+        valid: true, 
+        matching_event_count: countEvents(filter) 
+      }), {
         headers: { ...headers, 'Content-Type': 'application/json' }
       });
     }
     
     // WebSocket upgrade
     if (url.pathname === '/stream') {
-      const success = server.upgrade(req);
+      // Reject before upgrading so unauthenticated clients never join the feed
+      const auth = authenticateStream(req);
+      if (auth && !auth.ok) return unauthorized(auth.error);
+      
+      const offersBearer = (req.headers.get('sec-websocket-protocol') || '').split(',').some(p => p.trim() === BEARER_SUBPROTOCOL);
+      const success = server.upgrade(req, {
+        data: newClientData(auth?.subject, clientIp(req, server.requestIP(req)?.address)),
+        headers: offersBearer ? { 'Sec-WebSocket-Protocol': BEARER_SUBPROTOCOL } : undefined
+      });
       if (success) {
         return undefined;
       }
+      return respondError(headers, 426, 'UPGRADE_REQUIRED', 'WebSocket upgrade required', undefined, { 'Upgrade': 'websocket' });
     }
     
-    // Default response
-    return new Response('Multi-Agent Observability Server', {
-      headers: { ...headers, 'Content-Type': 'text/plain' }
-    });
-  },
-  
-  websocket: {
-    open(ws) {
-      console.log('WebSocket client connected');
-      wsClients.add(ws);
+    // POST /admin/events/prune - Delete events by age ({before}) or count ({keepLast})
+    if (url.pathname === '/admin/events/prune' && req.method === 'POST') {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
+      
+      try {
+        const body = await req.json() as { before?: unknown; keepLast?: unknown };
+        const hasBefore = body.before !== undefined;
+        const hasKeepLast = body.keepLast !== undefined;
+        
+        if (hasBefore === hasKeepLast) {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'Provide exactly one of before or keepLast');
+        }
+        if (hasBefore && (typeof body.before !== 'number' || !Number.isFinite(body.before))) {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'before must be a timestamp in milliseconds');
+        }
+        if (hasKeepLast && (typeof body.keepLast !== 'number' || !Number.isInteger(body.keepLast) || body.keepLast < 0)) {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'keepLast must be a non-negative integer');
+        }
+        
+        const deleted = hasBefore ? await deleteEventsBefore(body.before as number) : await deleteEventsKeepLast(body.keepLast as number);
+        invalidateCache('events:');
+        logger.info(`Pruned ${deleted} events`);
+        
+        return new Response(JSON.stringify({ deleted }), {
+          headers: { ...headers, 'Content-Type': 'application/json' }
+        });
+      } catch (error) {
+        logger.error('Error pruning events:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
+    
+    // GET|PUT /admin/read-only - Inspect or toggle read-only maintenance mode
+    if (url.pathname === '/admin/read-only' && (req.method === 'GET' || req.method === 'PUT')) {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
+      
+      if (req.method === 'PUT') {
+        try {
+          const body = await req.json() as { enabled?: unknown };
+          if (typeof body.enabled !== 'boolean') {
+            return respondError(headers, 400, 'INVALID_PARAMETER', 'enabled must be a boolean');
+          }
+          readOnly = body.enabled;
+          logger.warn(`Read-only mode ${readOnly ? 'enabled' : 'disabled'}`);
+        } catch (error) {
+          return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+        }
+      }
+      
+      return new Response(JSON.stringify({ readOnly }), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
+    
+    // GET /admin/ws/clients - Connected WebSocket clients and their traffic
+    if (url.pathname === '/admin/ws/clients' && req.method === 'GET') {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
       
-      // Send recent events on connection
-      const events = getRecentEvents(50);
-      ws.send(JSON.stringify({ type: 'initial', data: events }));
-    },
+      const clients = describeClients();
+      return new Response(JSON.stringify({ count: clients.length, clients }), {
+        headers: { ...headers, 'Content-Type': 'application/json' }
+      });
+    }
     
-    message(ws, message) {
-      // Handle any client messages if needed
-      console.log('Received message:', message);
-    },
+    // PUT /admin/themes/:id/featured - Add or remove a theme from the featured set
+    if (url.pathname.match(/^\/admin\/themes\/[^\/]+\/featured$/) && req.method === 'PUT') {
+      const auth = authenticateAdmin(req);
+      if (!auth.ok) return unauthorized(auth.error);
+      
+      try {
+        const id = url.pathname.split('/')[3]!;
+        const body = await req.json() as { featured?: unknown };
+        if (typeof body.featured !== 'boolean') {
+          return respondError(headers, 400, 'INVALID_PARAMETER', 'featured must be a boolean');
+        }
+        
+        const result = await setFeatured(id, body.featured);
+        if (result.success) invalidateCache('themes:');
+        
+        return respondApiResult(headers, result);
+      } catch (error) {
+        logger.error('Error setting featured flag:', error);
+        return respondError(headers, 400, 'INVALID_BODY', 'Invalid request body');
+      }
+    }
     
-    close(ws) {
-      console.log('WebSocket client disconnected');
-      wsClients.delete(ws);
+    // GET /debug/pprof[/heap] - Runtime introspection, only when enabled
+    if (config.ENABLE_PPROF && url.pathname.startsWith('/debug/pprof') && req.method === 'GET') {
+      const debugResponse = handleDebugRequest(url, headers);
+      if (debugResponse) return debugResponse;
     }
-  }
+    
+    // Service banner
+    if (url.pathname === '/' && req.method === 'GET') {
+      return new Response('Multi-Agent Observability Server', {
+        headers: { ...headers, 'Content-Type': 'text/plain' }
+      });
+    }
+    
+    // Known path, wrong method
+    const allowed = allowedMethods(url.pathname);
+    if (allowed.length > 0) {
+      return respondError(headers, 405, 'METHOD_NOT_ALLOWED', 'Method not allowed', undefined, { 'Allow': [...allowed, 'OPTIONS'].join(', ') });
+    }
+    
+    return respondError(headers, 404, 'NOT_FOUND', 'Not found');
+  }),
+  
+  websocket: websocketHandlers
 });
 
 console.log(`🚀 Server running on http://localhost:${server.port}`);
 console.log(`📊 WebSocket endpoint: ws://localhost:${server.port}/stream`);
-console.log(`📮 POST events to: http://localhost:${server.port}/events`);
\ No newline at end of file
+console.log(`📮 POST events to: http://localhost:${server.port}/events`);
+
+// Close WebSocket clients cleanly before stopping the server. With
+// SHUTDOWN_DRAIN_MS set, keep serving that long first so load balancers
+// see /readyz fail and stop routing here.
+async function shutdown(signal: string): Promise<void> {
+  if (draining) return;
+  setDraining(true);
+  logger.info(`${signal} received, shutting down`);
+  if (config.SHUTDOWN_DRAIN_MS > 0) {
+    await Bun.sleep(config.SHUTDOWN_DRAIN_MS);
+  }
+  server.stop();
+  await stopIngestBuffer();
+  shutdownWebSockets();
+  shutdownSse();
+  closeDatabase();
+  process.exit(0);
+}
+
+process.on('SIGINT', () => shutdown('SIGINT'));
+process.on('SIGTERM', () => shutdown('SIGTERM'));
@@ -0,0 +1,94 @@
+import { insertEvents } from './db';
+import { config } from './config';
+import type { HookEvent } from './types';
+import { getRequestId, logger, runWithRequestId } from './logger';
+import { recordIngestDropped } from './metrics';
+
+// Buffered ingestion: events are queued and a background timer flushes them
+// in batches within a single transaction.
+const queue: HookEvent[] = [];
+let flushTimer: ReturnType<typeof setInterval> | undefined;
+let onSaved: (event: HookEvent) => void = () => {};
+let flushing: Promise<void> | undefined;
+// Consecutive failed flushes of the batch at the head of the queue
+let failedAttempts = 0;
+let droppedEvents = 0;
+
+export function isBufferedIngestion(): boolean {
+  return config.INGEST_BUFFER_ENABLED;
+}
+
+// Queue an event for the next flush. Returns false when the queue is full
+// so the caller can apply backpressure.
+export function enqueueEvent(event: HookEvent): boolean {
+  if (queue.length >= config.INGEST_QUEUE_MAX) {
+    return false;
+  }
+  
+  queue.push({ ...event, timestamp: event.timestamp || Date.now() });
+  if (queue.length >= config.INGEST_BATCH_SIZE) {
+    // The flush writes other requests' events too, so it must not inherit
+    // this request's deadline
+    void runWithRequestId(getRequestId() ?? 'ingest-flush', flushEvents);
+  }
+  return true;
+}
+
+// Write the queue out in batches. A batch that fails goes back to the head
+// of the queue for the next flush; after INGEST_FLUSH_RETRIES failed retries
+// it is dropped and counted. Concurrent calls share one flush.
+export function flushEvents(): Promise<void> {
+  flushing ??= drainQueue().finally(() => {
+    flushing = undefined;
+  });
+  return flushing;
+}
+
+async function drainQueue(): Promise<void> {
+  while (queue.length > 0) {
+    const batch = queue.splice(0, config.INGEST_BATCH_SIZE);
+    try {
+      const results = await insertEvents(batch);
+      failedAttempts = 0;
+      results
+        .filter(result => result.created)
+        .forEach(result => onSaved(result.event));
+    } catch (error) {
+      failedAttempts++;
+      if (failedAttempts > config.INGEST_FLUSH_RETRIES) {
+        failedAttempts = 0;
+        droppedEvents += batch.length;
+        recordIngestDropped(batch.length);
+        logger.error(`Dropped ${batch.length} buffered events after ${config.INGEST_FLUSH_RETRIES} retries:`, error);
+        continue;
+      }
+      queue.unshift(...batch);
+      logger.warn(`Failed to flush ${batch.length} buffered events, retrying on the next flush (attempt ${failedAttempts}/${config.INGEST_FLUSH_RETRIES}):`, error);
+      return;
+    }
+  }
+}
+
+export function startIngestBuffer(handler: (event: HookEvent) => void): void {
+  if (!isBufferedIngestion()) return;
+  
+  onSaved = handler;
+  flushTimer = setInterval(flushEvents, config.INGEST_FLUSH_INTERVAL_MS);
+}
+
+export async function stopIngestBuffer(): Promise<void> {
+  if (flushTimer) {
+    clearInterval(flushTimer);
+    flushTimer = undefined;
+  }
+  await flushEvents();
+}
+
+export function getQueueDepth(): number {
+  return queue.length;
+}
+
+// Buffered events lost to failed flushes since startup
+export function getDroppedEventCount(): number {
+  return droppedEvents;
+}
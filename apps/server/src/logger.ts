@@ -0,0 +1,111 @@
+import { AsyncLocalStorage } from 'node:async_hooks';
+import { appendFileSync, existsSync, mkdirSync, renameSync, statSync } from 'node:fs';
+import { dirname } from 'node:path';
+import { inspect } from 'node:util';
+import { config } from './config';
+
+type LogLevel = 'error' | 'warn' | 'info' | 'debug';
+
+const levelOrder: Record<LogLevel, number> = { error: 0, warn: 1, info: 2, debug: 3 };
+
+interface RequestContext {
+  requestId: string;
+  deadline?: number;
+  signal?: AbortSignal;
+}
+
+const requestContext = new AsyncLocalStorage<RequestContext>();
+
+// Run fn with the request id available to every log call made within it,
+// and the request's deadline (epoch ms) and abort signal to the database layer
+export function runWithRequestId<T>(requestId: string, fn: () => T, deadline?: number, signal?: AbortSignal): T {
+  return requestContext.run({ requestId, deadline, signal }, fn);
+}
+
+export function getRequestId(): string | undefined {
+  return requestContext.getStore()?.requestId;
+}
+
+export function getRequestDeadline(): number | undefined {
+  return requestContext.getStore()?.deadline;
+}
+
+// Aborted when the client disconnects before the response is sent
+export function getRequestSignal(): AbortSignal | undefined {
+  return requestContext.getStore()?.signal;
+}
+
+// Size-based rotating file sink: server.log -> server.log.1 -> ... -> .N
+let fileSize = -1;
+
+function rotateLogFile(path: string): void {
+  for (let i = config.LOG_FILE_MAX_FILES - 1; i >= 1; i--) {
+    if (existsSync(`${path}.${i}`)) {
+      renameSync(`${path}.${i}`, `${path}.${i + 1}`);
+    }
+  }
+  renameSync(path, `${path}.1`);
+  fileSize = 0;
+}
+
+function writeToFile(path: string, line: string): void {
+  if (fileSize < 0) {
+    mkdirSync(dirname(path), { recursive: true });
+    fileSize = existsSync(path) ? statSync(path).size : 0;
+  }
+  
+  const bytes = Buffer.byteLength(line);
+  if (fileSize > 0 && fileSize + bytes > config.LOG_FILE_MAX_BYTES) {
+    rotateLogFile(path);
+  }
+  appendFileSync(path, line);
+  fileSize += bytes;
+}
+
+function formatArg(arg: any): string {
+  if (typeof arg === 'string') return arg;
+  if (arg instanceof Error) return arg.stack || arg.message;
+  return inspect(arg, { depth: 4, breakLength: Infinity });
+}
+
+export function formatLogLine(level: LogLevel, args: any[], requestId?: string): string {
+  const message = args.map(formatArg).join(' ');
+  
+  if (config.LOG_FORMAT === 'json') {
+    return JSON.stringify({
+      time: new Date().toISOString(),
+      level,
+      ...(requestId ? { request_id: requestId } : {}),
+      msg: message
+    });
+  }
+  
+  const prefix = requestId ? `[${level}] [req ${requestId}]` : `[${level}]`;
+  return `${prefix} ${message}`;
+}
+
+function write(level: LogLevel, args: any[]): void {
+  if (levelOrder[level] > levelOrder[config.LOG_LEVEL]) return;
+  
+  const line = formatLogLine(level, args, getRequestId());
+  
+  if (config.LOG_FILE) {
+    try {
+      writeToFile(config.LOG_FILE, line + '\n');
+      return;
+    } catch (error) {
+      // Fall back to the console if the file sink is unusable
+      console.error(`Failed to write log file ${config.LOG_FILE}:`, error);
+    }
+  }
+  
+  const sink = level === 'error' ? console.error : level === 'warn' ? console.warn : console.log;
+  sink(line);
+}
+
+export const logger = {
+  error: (...args: any[]) => write('error', args),
+  warn: (...args: any[]) => write('warn', args),
+  info: (...args: any[]) => write('info', args),
+  debug: (...args: any[]) => write('debug', args)
+};
@@ -0,0 +1,59 @@
+import { config } from './config';
+
+// Bounded least-recently-used map for per-session in-memory state.
+// Map preserves insertion order, so re-inserting on access keeps the
+// least recently used entry at the front. maxSize may be a function, read
+// on every insert, so a limit taken from config follows config changes.
+export class LRUCache<K, V> {
+  private entries = new Map<K, V>();
+  
+  constructor(private readonly maxSize: number | (() => number)) {}
+  
+  get(key: K): V | undefined {
+    const value = this.entries.get(key);
+    if (value === undefined) return undefined;
+    
+    this.entries.delete(key);
+    this.entries.set(key, value);
+    return value;
+  }
+  
+  set(key: K, value: V): void {
+    this.entries.delete(key);
+    this.entries.set(key, value);
+    
+    const maxSize = typeof this.maxSize === 'function' ? this.maxSize() : this.maxSize;
+    while (this.entries.size > maxSize) {
+      const oldest = this.entries.keys().next().value as K;
+      this.entries.delete(oldest);
+    }
+  }
+  
+  has(key: K): boolean {
+    return this.entries.has(key);
+  }
+  
+  delete(key: K): boolean {
+    return this.entries.delete(key);
+  }
+  
+  // Visit entries oldest first without refreshing them; fn may delete the
+  // entry it is given
+  forEach(fn: (value: V, key: K) => void): void {
+    this.entries.forEach(fn);
+  }
+  
+  clear(): void {
+    this.entries.clear();
+  }
+  
+  get size(): number {
+    return this.entries.size;
+  }
+}
+
+// Session-keyed state is bounded by MAX_TRACKED_SESSIONS; callers must treat
+// a miss as "not tracked" and fall back to the database.
+export function createSessionCache<V>(): LRUCache<string, V> {
+  return new LRUCache<string, V>(() => config.MAX_TRACKED_SESSIONS);
+}
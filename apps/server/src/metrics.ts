@@ -0,0 +1,187 @@
+// Minimal Prometheus text-format metrics (exposition format 0.0.4)
+
+type Labels = Record<string, string>;
+
+function labelKey(labels: Labels): string {
+  const parts = Object.keys(labels)
+    .sort()
+    .map(name => `${name}="${String(labels[name]).replace(/\\/g, '\\\\').replace(/"/g, '\\"').replace(/\n/g, '\\n')}"`);
+  return parts.length > 0 ? `{${parts.join(',')}}` : '';
+}
+
+class Counter {
+  private values = new Map<string, number>();
+  
+  constructor(readonly name: string, readonly help: string) {}
+  
+  inc(labels: Labels = {}, value: number = 1): void {
+    const key = labelKey(labels);
+    this.values.set(key, (this.values.get(key) || 0) + value);
+  }
+  
+  render(): string {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} counter`];
+    if (this.values.size === 0) {
+      lines.push(`${this.name} 0`);
+    }
+    this.values.forEach((value, key) => lines.push(`${this.name}${key} ${value}`));
+    return lines.join('\n');
+  }
+}
+
+// Gauge whose value is read from a callback at scrape time
+class Gauge {
+  constructor(readonly name: string, readonly help: string, private read: () => number) {}
+  
+  render(): string {
+    return [
+      `# HELP ${this.name} ${this.help}`,
+      `# TYPE ${this.name} gauge`,
+      `${this.name} ${this.read()}`
+    ].join('\n');
+  }
+}
+
+// Gauge whose labelled values are set as they change
+class LabeledGauge {
+  private values = new Map<string, number>();
+  
+  constructor(readonly name: string, readonly help: string) {}
+  
+  set(labels: Labels, value: number): void {
+    this.values.set(labelKey(labels), value);
+  }
+  
+  render(): string {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} gauge`];
+    this.values.forEach((value, key) => lines.push(`${this.name}${key} ${value}`));
+    return lines.join('\n');
+  }
+}
+
+class Histogram {
+  private series = new Map<string, { labels: Labels; counts: number[]; sum: number; count: number }>();
+  
+  constructor(readonly name: string, readonly help: string, private buckets: number[]) {}
+  
+  observe(labels: Labels, value: number): void {
+    const key = labelKey(labels);
+    let entry = this.series.get(key);
+    if (!entry) {
+      entry = { labels, counts: this.buckets.map(() => 0), sum: 0, count: 0 };
+      this.series.set(key, entry);
+    }
+    this.buckets.forEach((bound, i) => {
+      if (value <= bound) entry!.counts[i]!++;
+    });
+    entry.sum += value;
+    entry.count++;
+  }
+  
+  render(): string {
+    const lines = [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} histogram`];
+    this.series.forEach(entry => {
+      this.buckets.forEach((bound, i) => {
+        lines.push(`${this.name}_bucket${labelKey({ ...entry.labels, le: String(bound) })} ${entry.counts[i]}`);
+      });
+      lines.push(`${this.name}_bucket${labelKey({ ...entry.labels, le: '+Inf' })} ${entry.count}`);
+      lines.push(`${this.name}_sum${labelKey(entry.labels)} ${entry.sum}`);
+      lines.push(`${this.name}_count${labelKey(entry.labels)} ${entry.count}`);
+    });
+    return lines.join('\n');
+  }
+}
+
+const eventsIngested = new Counter('events_ingested_total', 'Total number of events ingested');
+const eventsByType = new Counter('events_by_type_total', 'Events ingested by hook_event_type');
+const eventsSampledOut = new Counter('events_sampled_out_total', 'Events dropped by SAMPLE_RATE sampling');
+const ingestDropped = new Counter('ingest_dropped_events_total', 'Buffered events dropped after repeated flush failures');
+const dbErrors = new Counter('db_errors_total', 'Database query errors');
+const wsDropped = new Counter('ws_dropped_messages_total', 'WebSocket frames not delivered to slow clients');
+const wsSlowDisconnects = new Counter('ws_slow_client_disconnects_total', 'WebSocket clients disconnected for falling behind');
+const webhookDeliveries = new Counter('webhook_deliveries_total', 'Outbound event webhook deliveries by webhook index and result');
+const webhookBreakerState = new LabeledGauge('webhook_breaker_state', 'Outbound webhook circuit breaker state (0 closed, 1 half-open, 2 open)');
+const requestDuration = new Histogram(
+  'http_request_duration_seconds',
+  'HTTP request duration in seconds by route',
+  [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5]
+);
+const gauges: Gauge[] = [];
+
+export function recordEventIngested(hookEventType: string): void {
+  eventsIngested.inc();
+  eventsByType.inc({ hook_event_type: hookEventType });
+}
+
+export function recordEventSampledOut(sourceApp: string, hookEventType: string): void {
+  eventsSampledOut.inc({ source_app: sourceApp, hook_event_type: hookEventType });
+}
+
+export function recordIngestDropped(count: number): void {
+  ingestDropped.inc({}, count);
+}
+
+export function recordDbError(): void {
+  dbErrors.inc();
+}
+
+export function recordWsDropped(): void {
+  wsDropped.inc();
+}
+
+export function recordWsSlowDisconnect(): void {
+  wsSlowDisconnects.inc();
+}
+
+// result is "success", "failure" (after all retries) or "skipped" (breaker open)
+export function recordWebhookDelivery(webhook: number, result: 'success' | 'failure' | 'skipped'): void {
+  webhookDeliveries.inc({ webhook: String(webhook), result });
+}
+
+const BREAKER_STATE_VALUES = { closed: 0, half_open: 1, open: 2 };
+
+export function recordWebhookBreakerState(webhook: number, state: keyof typeof BREAKER_STATE_VALUES): void {
+  webhookBreakerState.set({ webhook: String(webhook) }, BREAKER_STATE_VALUES[state]);
+}
+
+export function isDatabaseError(error: unknown): boolean {
+  return error instanceof Error && error.name === 'SQLiteError';
+}
+
+export function recordRequest(method: string, route: string, status: number, seconds: number): void {
+  requestDuration.observe({ method, route, status: String(status) }, seconds);
+}
+
+export function registerGauge(name: string, help: string, read: () => number): void {
+  gauges.push(new Gauge(name, help, read));
+}
+
+// Collapse ids in the path so the route label has bounded cardinality.
+// Only pass paths of known routes; anything else should be labelled "other".
+export function routeLabel(pathname: string): string {
+  const segments = pathname.split('/');
+  return segments.map((segment, i) => {
+    const parent = segments[i - 1];
+    if (/^\d+$/.test(segment)) return ':id';
+    if (parent === 'apps') return ':sourceApp';
+    if (parent === 'sessions' || parent === 'authors') return ':id';
+    if (parent === 'themes' && i === 3 && !['import', 'bulk', 'generate', 'stats', 'tags', 'featured'].includes(segment)) return ':id';
+    return segment;
+  }).join('/');
+}
+
+export function renderMetrics(): string {
+  return [
+    eventsIngested.render(),
+    eventsByType.render(),
+    eventsSampledOut.render(),
+    ingestDropped.render(),
+    dbErrors.render(),
+    wsDropped.render(),
+    wsSlowDisconnects.render(),
+    webhookDeliveries.render(),
+    webhookBreakerState.render(),
+    ...gauges.map(gauge => gauge.render()),
+    requestDuration.render()
+  ].join('\n\n') + '\n';
+}
@@ -0,0 +1,274 @@
+import type { Database } from 'bun:sqlite';
+
+interface Migration {
+  version: number;
+  description: string;
+  up: (db: Database) => void;
+}
+
+function hasColumn(db: Database, table: string, column: string): boolean {
+  const columns = db.prepare(`PRAGMA table_info(${table})`).all() as any[];
+  return columns.some((col: any) => col.name === column);
+}
+
+function addColumnIfMissing(db: Database, table: string, column: string, definition: string): void {
+  if (!hasColumn(db, table, column)) {
+    db.exec(`ALTER TABLE ${table} ADD COLUMN ${column} ${definition}`);
+  }
+}
+
+// Ordered schema migrations. Append new entries; never edit applied ones.
+// Migrations must tolerate databases created before this runner existed,
+// so they use IF NOT EXISTS and column checks.
+const migrations: Migration[] = [
+  {
+    version: 1,
+    description: 'initial schema',
+    up: (db) => {
+      // Create events table
+      db.exec(`
+        CREATE TABLE IF NOT EXISTS events (
+          id INTEGER PRIMARY KEY AUTOINCREMENT,
+          source_app TEXT NOT NULL,
+          session_id TEXT NOT NULL,
+          hook_event_type TEXT NOT NULL,
+          payload TEXT NOT NULL,
+          chat TEXT,
+          summary TEXT,
+          timestamp INTEGER NOT NULL
+        )
+      `);
+    
+      // Databases created before the chat/summary columns existed
+      addColumnIfMissing(db, 'events', 'chat', 'TEXT');
+      addColumnIfMissing(db, 'events', 'summary', 'TEXT');
+    
+      // Create indexes for common queries
+      db.exec('CREATE INDEX IF NOT EXISTS idx_source_app ON events(source_app)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_session_id ON events(session_id)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_hook_event_type ON events(hook_event_type)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_timestamp ON events(timestamp)');
+    
+      // Create themes table
+      db.exec(`
+        CREATE TABLE IF NOT EXISTS themes (
+          id TEXT PRIMARY KEY,
+          name TEXT NOT NULL UNIQUE,
+          displayName TEXT NOT NULL,
+          description TEXT,
+          colors TEXT NOT NULL,
+          isPublic INTEGER NOT NULL DEFAULT 0,
+          authorId TEXT,
+          authorName TEXT,
+          createdAt INTEGER NOT NULL,
+          updatedAt INTEGER NOT NULL,
+          tags TEXT,
+          downloadCount INTEGER DEFAULT 0,
+          rating REAL DEFAULT 0,
+          ratingCount INTEGER DEFAULT 0
+        )
+      `);
+    
+      // Create theme shares table
+      db.exec(`
+        CREATE TABLE IF NOT EXISTS theme_shares (
+          id TEXT PRIMARY KEY,
+          themeId TEXT NOT NULL,
+          shareToken TEXT NOT NULL UNIQUE,
+          expiresAt INTEGER,
+          isPublic INTEGER NOT NULL DEFAULT 0,
+          allowedUsers TEXT,
+          createdAt INTEGER NOT NULL,
+          accessCount INTEGER DEFAULT 0,
+          FOREIGN KEY (themeId) REFERENCES themes (id) ON DELETE CASCADE
+        )
+      `);
+    
+      // Create theme ratings table
+      db.exec(`
+        CREATE TABLE IF NOT EXISTS theme_ratings (
+          id TEXT PRIMARY KEY,
+          themeId TEXT NOT NULL,
+          userId TEXT NOT NULL,
+          rating INTEGER NOT NULL,
+          comment TEXT,
+          createdAt INTEGER NOT NULL,
+          UNIQUE(themeId, userId),
+          FOREIGN KEY (themeId) REFERENCES themes (id) ON DELETE CASCADE
+        )
+      `);
+    
+      // Create indexes for theme tables
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_name ON themes(name)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_isPublic ON themes(isPublic)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_createdAt ON themes(createdAt)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_theme_shares_token ON theme_shares(shareToken)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_theme_ratings_theme ON theme_ratings(themeId)');
+    }
+  },
+  {
+    version: 2,
+    description: 'soft delete flag on events',
+    up: (db) => {
+      addColumnIfMissing(db, 'events', 'is_deleted', 'INTEGER NOT NULL DEFAULT 0');
+    }
+  },
+  {
+    version: 3,
+    description: 'composite indexes for filtered event queries',
+    up: (db) => {
+      // Cover WHERE source_app/session_id = ? ORDER BY timestamp
+      db.exec('CREATE INDEX IF NOT EXISTS idx_source_app_timestamp ON events(source_app, timestamp)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_session_id_timestamp ON events(session_id, timestamp)');
+    }
+  },
+  {
+    version: 4,
+    description: 'event_uuid idempotency key',
+    up: (db) => {
+      addColumnIfMissing(db, 'events', 'event_uuid', 'TEXT');
+      db.exec('CREATE UNIQUE INDEX IF NOT EXISTS idx_event_uuid ON events(event_uuid) WHERE event_uuid IS NOT NULL');
+    }
+  },
+  {
+    version: 5,
+    description: 'payload_compressed flag',
+    up: (db) => {
+      addColumnIfMissing(db, 'events', 'payload_compressed', 'INTEGER NOT NULL DEFAULT 0');
+    }
+  },
+  {
+    version: 6,
+    description: 'payload_hash for duplicate detection',
+    up: (db) => {
+      addColumnIfMissing(db, 'events', 'payload_hash', 'TEXT');
+      
+      // Backfill existing rows; payload bytes are hashed as stored, after
+      // undoing compression
+      const rows = db.prepare('SELECT id, payload, payload_compressed FROM events WHERE payload_hash IS NULL').all() as any[];
+      const update = db.prepare('UPDATE events SET payload_hash = ? WHERE id = ?');
+      const decoder = new TextDecoder();
+      for (const row of rows) {
+        const json = row.payload_compressed ? decoder.decode(Bun.gunzipSync(row.payload)) : row.payload;
+        update.run(new Bun.CryptoHasher('sha1').update(json).digest('hex'), row.id);
+      }
+      
+      db.exec('CREATE INDEX IF NOT EXISTS idx_duplicate_key ON events(source_app, hook_event_type, payload_hash, timestamp)');
+    }
+  },
+  {
+    version: 7,
+    description: 'themes isFeatured flag',
+    up: (db) => {
+      addColumnIfMissing(db, 'themes', 'isFeatured', 'INTEGER NOT NULL DEFAULT 0');
+    }
+  },
+  {
+    version: 8,
+    description: 'events sample_rate',
+    up: (db) => {
+      addColumnIfMissing(db, 'events', 'sample_rate', 'INTEGER NOT NULL DEFAULT 1');
+    }
+  },
+  {
+    version: 9,
+    description: 'authors table; theme author names move out of themes',
+    up: (db) => {
+      db.exec(`
+        CREATE TABLE IF NOT EXISTS authors (
+          id TEXT PRIMARY KEY,
+          name TEXT,
+          createdAt INTEGER NOT NULL,
+          updatedAt INTEGER NOT NULL
+        )
+      `);
+      // Each author keeps the name from their most recently updated theme
+      db.exec(`
+        INSERT OR IGNORE INTO authors (id, name, createdAt, updatedAt)
+        SELECT
+          t.authorId,
+          (SELECT n.authorName FROM themes n
+            WHERE n.authorId = t.authorId AND n.authorName IS NOT NULL
+            ORDER BY n.updatedAt DESC LIMIT 1),
+          MIN(t.createdAt),
+          MAX(t.updatedAt)
+        FROM themes t
+        WHERE t.authorId IS NOT NULL
+        GROUP BY t.authorId
+      `);
+      // themes.authorName is no longer read or written
+      db.exec('UPDATE themes SET authorName = NULL');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_authorId ON themes(authorId)');
+    }
+  },
+  {
+    version: 10,
+    description: 'theme names unique per author instead of globally',
+    up: (db) => {
+      // SQLite cannot drop a column's UNIQUE constraint, so rebuild the table.
+      // Foreign keys are not enforced on this connection, so dropping the old
+      // table leaves theme_shares and theme_ratings rows intact.
+      const columns = 'id, name, displayName, description, colors, isPublic, authorId, authorName, createdAt, updatedAt, tags, downloadCount, rating, ratingCount, isFeatured';
+      db.exec(`
+        CREATE TABLE themes_new (
+          id TEXT PRIMARY KEY,
+          name TEXT NOT NULL,
+          displayName TEXT NOT NULL,
+          description TEXT,
+          colors TEXT NOT NULL,
+          isPublic INTEGER NOT NULL DEFAULT 0,
+          authorId TEXT,
+          authorName TEXT,
+          createdAt INTEGER NOT NULL,
+          updatedAt INTEGER NOT NULL,
+          tags TEXT,
+          downloadCount INTEGER DEFAULT 0,
+          rating REAL DEFAULT 0,
+          ratingCount INTEGER DEFAULT 0,
+          isFeatured INTEGER NOT NULL DEFAULT 0
+        )
+      `);
+      db.exec(`INSERT INTO themes_new (${columns}) SELECT ${columns} FROM themes`);
+      db.exec('DROP TABLE themes');
+      db.exec('ALTER TABLE themes_new RENAME TO themes');
+      
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_name ON themes(name)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_isPublic ON themes(isPublic)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_createdAt ON themes(createdAt)');
+      db.exec('CREATE INDEX IF NOT EXISTS idx_themes_authorId ON themes(authorId)');
+      // Authorless themes share one namespace
+      db.exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_themes_author_name ON themes(COALESCE(authorId, ''), name)`);
+    }
+  }
+];
+
+// Apply every migration newer than the recorded schema version, each in
+// its own transaction. Returns the versions that were applied.
+export function runMigrations(db: Database): number[] {
+  db.exec(`
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+      version INTEGER PRIMARY KEY,
+      description TEXT NOT NULL,
+      applied_at INTEGER NOT NULL
+    )
+  `);
+  
+  const applied = new Set(
+    (db.prepare('SELECT version FROM schema_migrations').all() as { version: number }[]).map(row => row.version)
+  );
+  const record = db.prepare('INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)');
+  const newlyApplied: number[] = [];
+  
+  for (const migration of [...migrations].sort((a, b) => a.version - b.version)) {
+    if (applied.has(migration.version)) continue;
+    
+    db.transaction(() => {
+      migration.up(db);
+      record.run(migration.version, migration.description, Date.now());
+    })();
+    newlyApplied.push(migration.version);
+    console.log(`🗄️  Applied migration ${migration.version}: ${migration.description}`);
+  }
+  
+  return newlyApplied;
+}
@@ -0,0 +1,204 @@
+// Minimal MessagePack encoder for JSON-compatible values, used for
+// Accept: application/msgpack responses. Object keys whose value is
+// undefined are skipped, matching JSON.stringify.
+
+export const MSGPACK_CONTENT_TYPE = 'application/msgpack';
+
+class Writer {
+  private buffer = new Uint8Array(1024);
+  private view = new DataView(this.buffer.buffer);
+  length = 0;
+  
+  private reserve(bytes: number): void {
+    if (this.length + bytes <= this.buffer.length) return;
+    let size = this.buffer.length * 2;
+    while (size < this.length + bytes) size *= 2;
+    const next = new Uint8Array(size);
+    next.set(this.buffer.subarray(0, this.length));
+    this.buffer = next;
+    this.view = new DataView(next.buffer);
+  }
+  
+  u8(value: number): void {
+    this.reserve(1);
+    this.view.setUint8(this.length, value);
+    this.length += 1;
+  }
+  
+  u16(value: number): void {
+    this.reserve(2);
+    this.view.setUint16(this.length, value);
+    this.length += 2;
+  }
+  
+  u32(value: number): void {
+    this.reserve(4);
+    this.view.setUint32(this.length, value);
+    this.length += 4;
+  }
+  
+  i8(value: number): void {
+    this.reserve(1);
+    this.view.setInt8(this.length, value);
+    this.length += 1;
+  }
+  
+  i16(value: number): void {
+    this.reserve(2);
+    this.view.setInt16(this.length, value);
+    this.length += 2;
+  }
+  
+  i32(value: number): void {
+    this.reserve(4);
+    this.view.setInt32(this.length, value);
+    this.length += 4;
+  }
+  
+  u64(value: number): void {
+    this.reserve(8);
+    this.view.setBigUint64(this.length, BigInt(value));
+    this.length += 8;
+  }
+  
+  i64(value: number): void {
+    this.reserve(8);
+    this.view.setBigInt64(this.length, BigInt(value));
+    this.length += 8;
+  }
+  
+  f64(value: number): void {
+    this.reserve(8);
+    this.view.setFloat64(this.length, value);
+    this.length += 8;
+  }
+  
+  bytes(value: Uint8Array): void {
+    this.reserve(value.length);
+    this.buffer.set(value, this.length);
+    this.length += value.length;
+  }
+  
+  result(): Uint8Array {
+    return this.buffer.slice(0, this.length);
+  }
+}
+
+const encoder = new TextEncoder();
+
+function writeLength(w: Writer, length: number, fix: number, fixMax: number, op16: number, op32: number): void {
+  if (length <= fixMax) {
+    w.u8(fix | length);
+  } else if (length <= 0xffff) {
+    w.u8(op16);
+    w.u16(length);
+  } else {
+    w.u8(op32);
+    w.u32(length);
+  }
+}
+
+function writeNumber(w: Writer, value: number): void {
+  if (!Number.isSafeInteger(value)) {
+    w.u8(0xcb);
+    w.f64(value);
+  } else if (value >= 0) {
+    if (value < 0x80) {
+      w.u8(value);
+    } else if (value <= 0xff) {
+      w.u8(0xcc);
+      w.u8(value);
+    } else if (value <= 0xffff) {
+      w.u8(0xcd);
+      w.u16(value);
+    } else if (value <= 0xffffffff) {
+      w.u8(0xce);
+      w.u32(value);
+    } else {
+      w.u8(0xcf);
+      w.u64(value);
+    }
+  } else if (value >= -32) {
+    w.i8(value);
+  } else if (value >= -0x80) {
+    w.u8(0xd0);
+    w.i8(value);
+  } else if (value >= -0x8000) {
+    w.u8(0xd1);
+    w.i16(value);
+  } else if (value >= -0x80000000) {
+    w.u8(0xd2);
+    w.i32(value);
+  } else {
+    w.u8(0xd3);
+    w.i64(value);
+  }
+}
+
+function writeValue(w: Writer, value: any): void {
+  if (value === null || value === undefined) {
+    w.u8(0xc0);
+  } else if (value === false) {
+    w.u8(0xc2);
+  } else if (value === true) {
+    w.u8(0xc3);
+  } else if (typeof value === 'number') {
+    writeNumber(w, value);
+  } else if (typeof value === 'string') {
+    const bytes = encoder.encode(value);
+    if (bytes.length > 31 && bytes.length <= 0xff) {
+      w.u8(0xd9);
+      w.u8(bytes.length);
+    } else {
+      writeLength(w, bytes.length, 0xa0, 31, 0xda, 0xdb);
+    }
+    w.bytes(bytes);
+  } else if (value instanceof Uint8Array) {
+    if (value.length <= 0xff) {
+      w.u8(0xc4);
+      w.u8(value.length);
+    } else if (value.length <= 0xffff) {
+      w.u8(0xc5);
+      w.u16(value.length);
+    } else {
+      w.u8(0xc6);
+      w.u32(value.length);
+    }
+    w.bytes(value);
+  } else if (Array.isArray(value)) {
+    writeLength(w, value.length, 0x90, 15, 0xdc, 0xdd);
+    value.forEach(item => writeValue(w, item === undefined ? null : item));
+  } else if (typeof value.toJSON === 'function') {
+    writeValue(w, value.toJSON());
+  } else {
+    const entries = Object.entries(value).filter(([, item]) => item !== undefined);
+    writeLength(w, entries.length, 0x80, 15, 0xde, 0xdf);
+    entries.forEach(([key, item]) => {
+      writeValue(w, key);
+      writeValue(w, item);
+    });
+  }
+}
+
+export function encodeMsgpack(value: any): Uint8Array {
+  const w = new Writer();
+  writeValue(w, value);
+  return w.result();
+}
+
+export function acceptsMsgpack(req: Request): boolean {
+  const accept = req.headers.get('accept') || '';
+  return accept.includes(MSGPACK_CONTENT_TYPE) || accept.includes('application/x-msgpack');
+}
+
+// Re-encode a JSON response as MessagePack for clients that ask for it
+export async function toMsgpackResponse(response: Response): Promise<Response> {
+  const contentType = response.headers.get('Content-Type') || '';
+  if (!contentType.startsWith('application/json')) return response;
+  
+  const body = encodeMsgpack(await response.json());
+  const headers = new Headers(response.headers);
+  headers.set('Content-Type', MSGPACK_CONTENT_TYPE);
+  headers.set('Content-Length', String(body.byteLength));
+  return new Response(body, { status: response.status, statusText: response.statusText, headers });
+}
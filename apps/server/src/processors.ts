@@ -0,0 +1,72 @@
+import { resolve } from 'path';
+import { config } from './config';
+import { EventRejectedError } from './errors';
+import { logger } from './logger';
+import type { HookEvent } from './types';
+
+// Custom logic run on every incoming event before it is stored, such as
+// enrichment or tagging. A processor may mutate the event or return a
+// replacement; it rejects the event by throwing EventRejectedError.
+export interface EventProcessor {
+  name: string;
+  process(event: HookEvent): HookEvent | void | Promise<HookEvent | void>;
+}
+
+// Passes every event through unchanged
+export const noopProcessor: EventProcessor = {
+  name: 'noop',
+  process: () => {}
+};
+
+const processors: EventProcessor[] = [];
+
+// Processors run in registration order. Returns a function that removes the
+// processor again.
+export function registerEventProcessor(processor: EventProcessor): () => void {
+  processors.push(processor);
+  return () => {
+    const index = processors.indexOf(processor);
+    if (index !== -1) processors.splice(index, 1);
+  };
+}
+
+export function hasEventProcessors(): boolean {
+  return processors.length > 0;
+}
+
+// Import each EVENT_PROCESSORS module (paths relative to the working
+// directory) and register its default export: one processor or an array of
+// them. A module that fails to load stops startup.
+export async function loadEventProcessors(paths: string[] = config.EVENT_PROCESSORS): Promise<void> {
+  for (const path of paths) {
+    const module = await import(resolve(process.cwd(), path));
+    const loaded: EventProcessor[] = Array.isArray(module.default) ? module.default : [module.default];
+    
+    for (const processor of loaded) {
+      if (typeof processor?.name !== 'string' || typeof processor.process !== 'function') {
+        throw new Error(`Event processor module ${path} must export { name, process(event) }`);
+      }
+      registerEventProcessor(processor);
+    }
+    logger.info(`Loaded event processors from ${path}: ${loaded.map(processor => processor.name).join(', ')}`);
+  }
+}
+
+// Run the event through every processor in turn. Rejections are tagged with
+// the processor's name; other failures are logged and rethrown.
+export async function runEventProcessors(event: HookEvent): Promise<HookEvent> {
+  let current = event;
+  for (const processor of processors) {
+    try {
+      current = (await processor.process(current)) ?? current;
+    } catch (error) {
+      if (error instanceof EventRejectedError) {
+        error.processor ??= processor.name;
+        throw error;
+      }
+      logger.error(`Event processor ${processor.name} failed:`, error);
+      throw error;
+    }
+  }
+  return current;
+}
@@ -0,0 +1,50 @@
+import { config } from './config';
+
+// IPv4-mapped IPv6 addresses ("::ffff:10.0.0.1") compare as plain IPv4
+function normalizeIp(ip: string): string {
+  const trimmed = ip.trim();
+  return trimmed.toLowerCase().startsWith('::ffff:') && trimmed.includes('.') ? trimmed.slice(7) : trimmed;
+}
+
+function ipv4ToInt(ip: string): number | null {
+  const parts = ip.split('.');
+  if (parts.length !== 4 || parts.some(part => !/^\d{1,3}$/.test(part) || Number(part) > 255)) return null;
+  return parts.reduce((acc, part) => acc * 256 + Number(part), 0);
+}
+
+// Match an address against an exact IP or an IPv4 CIDR range ("10.0.0.0/8")
+function matchesProxy(ip: string, entry: string): boolean {
+  const [base, bits] = entry.split('/');
+  if (bits === undefined) return normalizeIp(base!) === ip;
+  
+  const address = ipv4ToInt(ip);
+  const network = ipv4ToInt(base!);
+  const prefix = Number(bits);
+  if (address === null || network === null || !Number.isInteger(prefix) || prefix < 0 || prefix > 32) return false;
+  
+  const blockSize = 2 ** (32 - prefix);
+  return Math.floor(address / blockSize) === Math.floor(network / blockSize);
+}
+
+export function isTrustedProxy(ip: string, trusted: string[] = config.TRUSTED_PROXIES): boolean {
+  const normalized = normalizeIp(ip);
+  return trusted.some(entry => matchesProxy(normalized, entry));
+}
+
+// Resolve the client address for a request arriving from remoteAddress.
+// X-Forwarded-For is only believed when the peer is a trusted proxy; it is
+// then read right to left, skipping trusted hops, so a client cannot spoof
+// its address by sending its own header. No trusted proxies means the peer
+// address is always used.
+export function clientIp(req: Request, remoteAddress: string | undefined, trusted: string[] = config.TRUSTED_PROXIES): string | undefined {
+  if (!remoteAddress || trusted.length === 0 || !isTrustedProxy(remoteAddress, trusted)) {
+    return remoteAddress && normalizeIp(remoteAddress);
+  }
+  
+  const hops = (req.headers.get('x-forwarded-for') || '').split(',').map(normalizeIp).filter(Boolean);
+  for (let i = hops.length - 1; i >= 0; i--) {
+    if (!isTrustedProxy(hops[i]!, trusted)) return hops[i];
+  }
+  // Every hop is a trusted proxy; the leftmost is the closest thing to a client
+  return hops[0] ?? normalizeIp(remoteAddress);
+}
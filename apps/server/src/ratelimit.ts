@@ -0,0 +1,48 @@
+import { config } from './config';
+import { LRUCache } from './lru';
+
+interface Window {
+  startedAt: number;
+  count: number;
+}
+
+// Fixed-window request counter keyed by an arbitrary string. The key space
+// is bounded so a flood of distinct keys cannot grow memory without limit.
+export class RateLimiter {
+  private windows: LRUCache<string, Window>;
+  
+  constructor(readonly limit: number, readonly windowMs: number, maxKeys: number = 10000) {
+    this.windows = new LRUCache<string, Window>(maxKeys);
+  }
+  
+  // Count a hit for key. Returns the milliseconds until the window resets if
+  // the key is over its limit, or 0 if the hit is allowed.
+  hit(key: string, now: number = Date.now()): number {
+    let window = this.windows.get(key);
+    if (!window || now - window.startedAt >= this.windowMs) {
+      window = { startedAt: now, count: 0 };
+      this.windows.set(key, window);
+    }
+    
+    if (window.count >= this.limit) {
+      return window.startedAt + this.windowMs - now;
+    }
+    window.count++;
+    return 0;
+  }
+}
+
+// Limits ingestion per source_app rather than per client IP, so agents
+// sharing a NAT address do not throttle each other. Rebuilt whenever the
+// configured limit or window changes.
+let sourceLimiter: RateLimiter | null = null;
+
+// Returns ms until sourceApp may send again, or 0 when allowed or disabled
+export function checkSourceRateLimit(sourceApp: string): number {
+  if (config.PER_SOURCE_RATE_LIMIT <= 0) return 0;
+  
+  if (!sourceLimiter || sourceLimiter.limit !== config.PER_SOURCE_RATE_LIMIT || sourceLimiter.windowMs !== config.RATE_LIMIT_WINDOW_MS) {
+    sourceLimiter = new RateLimiter(config.PER_SOURCE_RATE_LIMIT, config.RATE_LIMIT_WINDOW_MS);
+  }
+  return sourceLimiter.hit(sourceApp);
+}
@@ -0,0 +1,39 @@
+import { config } from './config';
+import { recordEventSampledOut } from './metrics';
+import type { HookEvent } from './types';
+
+// Events seen per "source_app:hook_event_type", for sampled keys only
+const seen = new Map<string, number>();
+
+// Keep-1-in-N rate for an event: an exact "source_app:hook_event_type" rule
+// wins over a "source_app" rule. 1 means no sampling.
+export function sampleRateFor(event: HookEvent): number {
+  return config.SAMPLE_RATE[`${event.source_app}:${event.hook_event_type}`]
+    ?? config.SAMPLE_RATE[event.source_app]
+    ?? 1;
+}
+
+// Apply SAMPLE_RATE at ingestion. Returns false for events to drop; kept
+// events from sampled sources are marked with the rate they stand for.
+// Sampling is deterministic: the first of every N events per source and type
+// is kept.
+export function sampleEvent(event: HookEvent): boolean {
+  // Never trust a client-supplied marker
+  delete event.sampled;
+  delete event.sample_rate;
+  
+  const rate = sampleRateFor(event);
+  if (rate <= 1) return true;
+  
+  const key = `${event.source_app}:${event.hook_event_type}`;
+  const count = seen.get(key) ?? 0;
+  seen.set(key, count + 1);
+  if (count % rate !== 0) {
+    recordEventSampledOut(event.source_app, event.hook_event_type);
+    return false;
+  }
+  
+  event.sampled = true;
+  event.sample_rate = rate;
+  return true;
+}
@@ -0,0 +1,117 @@
+import { getEventsAfter, getRecentEvents } from './db';
+import { config } from './config';
+import { toBroadcastEvent } from './event';
+import { logger } from './logger';
+
+// Server-sent events mirror of the WebSocket feed for clients behind proxies
+// that break upgrades. Event frames carry the event id so browsers resume via
+// Last-Event-ID.
+
+const encoder = new TextEncoder();
+const sseClients = new Set<ReadableStreamDefaultController<Uint8Array>>();
+
+// Upper bound on events replayed to a resuming client
+const MAX_REPLAY = 1000;
+
+function formatFrame(message: { type: string; data: any }): string {
+  const id = message.type === 'event' && message.data?.id !== undefined ? `id: ${message.data.id}\n` : '';
+  return `${id}event: ${message.type}\ndata: ${JSON.stringify(message.data)}\n\n`;
+}
+
+function send(controller: ReadableStreamDefaultController<Uint8Array>, chunk: string): boolean {
+  try {
+    controller.enqueue(encoder.encode(chunk));
+    return true;
+  } catch (err) {
+    // Stream already closed
+    return false;
+  }
+}
+
+// Push a message to every SSE client
+export function broadcastSse(message: { type: string; data: any }): void {
+  if (sseClients.size === 0) return;
+  
+  const frame = formatFrame(message);
+  const failed: ReadableStreamDefaultController<Uint8Array>[] = [];
+  sseClients.forEach(controller => {
+    if (!send(controller, frame)) failed.push(controller);
+  });
+  failed.forEach(controller => sseClients.delete(controller));
+}
+
+export function getSseClientCount(): number {
+  return sseClients.size;
+}
+
+// Open an event stream. With a numeric Last-Event-ID the client gets the
+// events it missed; otherwise it gets the usual initial batch. A client that
+// missed more than MAX_REPLAY events gets a resync frame, telling it to
+// reload its state, followed by the initial batch.
+export function openSseStream(req: Request, headers: Record<string, string>): Response {
+  const lastEventId = parseInt(req.headers.get('last-event-id') || '');
+  let keepAlive: ReturnType<typeof setInterval> | undefined;
+  let client: ReadableStreamDefaultController<Uint8Array> | undefined;
+  
+  const cleanup = () => {
+    if (keepAlive) clearInterval(keepAlive);
+    if (client) sseClients.delete(client);
+  };
+  
+  const stream = new ReadableStream<Uint8Array>({
+    start(controller) {
+      client = controller;
+      send(controller, `retry: ${config.WS_HEARTBEAT_INTERVAL}\n\n`);
+      
+      const missed = isNaN(lastEventId) ? undefined : getEventsAfter(lastEventId, MAX_REPLAY + 1);
+      if (missed && missed.length <= MAX_REPLAY) {
+        missed.forEach(event => {
+          send(controller, formatFrame({ type: 'event', data: toBroadcastEvent(event) }));
+        });
+      } else {
+        if (missed) {
+          send(controller, formatFrame({ type: 'resync', data: { last_event_id: lastEventId, max_replay: MAX_REPLAY } }));
+        }
+        send(controller, formatFrame({ type: 'initial', data: getRecentEvents(50).map(toBroadcastEvent) }));
+      }
+      
+      sseClients.add(controller);
+      logger.info('SSE client connected');
+      
+      // Comment frames keep idle proxies from closing the connection
+      keepAlive = setInterval(() => {
+        if (!send(controller, ': keep-alive\n\n')) cleanup();
+      }, config.WS_HEARTBEAT_INTERVAL);
+      
+      req.signal.addEventListener('abort', () => {
+        logger.info('SSE client disconnected');
+        cleanup();
+      });
+    },
+    cancel() {
+      cleanup();
+    }
+  });
+  
+  return new Response(stream, {
+    headers: {
+      ...headers,
+      'Content-Type': 'text/event-stream',
+      'Cache-Control': 'no-cache',
+      'Connection': 'keep-alive',
+      'X-Accel-Buffering': 'no'
+    }
+  });
+}
+
+// Close every open stream
+export function shutdownSse(): void {
+  sseClients.forEach(controller => {
+    try {
+      controller.close();
+    } catch (err) {
+      // Already closed
+    }
+  });
+  sseClients.clear();
+}
@@ -0,0 +1,55 @@
+import { config } from './config';
+import { getEventById, updateEventSummary } from './db';
+import { getRequestId, logger, runWithRequestId } from './logger';
+import { invalidateCache } from './cache';
+import { toBroadcastEvent } from './event';
+import { broadcast } from './websocket';
+import type { HookEvent } from './types';
+
+// POST the event to SUMMARY_WEBHOOK_URL and attach the returned summary.
+// The service may answer {"summary": "..."} or a plain-text body.
+async function fetchSummary(event: HookEvent): Promise<void> {
+  try {
+    const response = await fetch(config.SUMMARY_WEBHOOK_URL!, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(event),
+      signal: AbortSignal.timeout(config.SUMMARY_WEBHOOK_TIMEOUT_MS)
+    });
+    if (!response.ok) {
+      logger.warn(`Summary webhook returned ${response.status} for event ${event.id}`);
+      return;
+    }
+    
+    const body = await response.text();
+    let summary = body;
+    if ((response.headers.get('content-type') || '').includes('application/json')) {
+      const parsed = JSON.parse(body);
+      summary = typeof parsed?.summary === 'string' ? parsed.summary : '';
+    }
+    summary = summary.trim();
+    if (!summary) {
+      logger.warn(`Summary webhook returned no summary for event ${event.id}`);
+      return;
+    }
+    
+    // The event may have been summarized or deleted in the meantime
+    const current = getEventById(event.id!);
+    if (!current || current.summary) return;
+    
+    await updateEventSummary(event.id!, summary);
+    invalidateCache('events:');
+    broadcast({ type: 'event_updated', data: toBroadcastEvent(getEventById(event.id!)!) });
+  } catch (error) {
+    logger.error(`Summary webhook failed for event ${event.id}:`, error);
+  }
+}
+
+// Fire-and-forget: ask the summary webhook for a summary of a newly saved
+// event that has none. Failures are logged and never reach the caller.
+export function requestSummary(event: HookEvent): void {
+  if (!config.SUMMARY_WEBHOOK_URL || event.summary || event.id === undefined) return;
+  
+  // Outlives the request: keep its id for log correlation, drop its deadline
+  void runWithRequestId(getRequestId() ?? 'summary-webhook', () => fetchSummary(event));
+}
@@ -1,18 +1,71 @@
 import { 
   insertTheme, 
+  insertThemes,
   updateTheme, 
   getTheme, 
   getThemes, 
+  getThemesByIds,
+  getThemeByAuthorAndName,
+  getAuthor,
+  upsertAuthor,
   deleteTheme, 
-  incrementThemeDownloadCount 
+  incrementThemeDownloadCount,
+  getThemeTagCounts,
+  setThemeFeatured
 } from './db';
-import type { Theme, ThemeSearchQuery, ThemeValidationError, ApiResponse } from './types';
+import type { Author, Theme, ThemeColors, ThemeSearchQuery, ThemeValidationError, ApiResponse } from './types';
+import { logger } from './logger';
+import { config } from './config';
 
 // Utility functions
 function generateId(): string {
   return Math.random().toString(36).substr(2, 16);
 }
 
+// Lowercase kebab-case: "My Dark_Theme!" -> "my-dark-theme"
+export function slugify(value: string): string {
+  return value.toLowerCase().replace(/[^a-z0-9]+/g, '-').replace(/^-+|-+$/g, '');
+}
+
+// First of base, base-2, base-3, ... not used by a stored theme or in reserved
+function uniqueThemeId(base: string, reserved: Set<string> = new Set()): string {
+  let id = base;
+  for (let n = 2; reserved.has(id) || getTheme(id); n++) {
+    id = `${base}-${n}`;
+  }
+  return id;
+}
+
+// Id for a new theme that did not supply one, per THEME_ID_STRATEGY
+function newThemeId(name: unknown, reserved?: Set<string>): string {
+  const slug = config.THEME_ID_STRATEGY === 'slug' && typeof name === 'string' ? slugify(name) : '';
+  return slug ? uniqueThemeId(slug, reserved) : generateId();
+}
+
+// Strong validator for conditional GETs; changes whenever the theme is updated
+export function themeETag(theme: Theme): string {
+  const hash = new Bun.CryptoHasher('sha1').update(`${theme.id}:${theme.updatedAt}`).digest('hex');
+  return `"${hash}"`;
+}
+
+// Whether an If-None-Match header names etag; weak tags compare equal
+export function matchesETag(ifNoneMatch: string | null | undefined, etag: string): boolean {
+  if (!ifNoneMatch) return false;
+  return ifNoneMatch.trim() === '*' || ifNoneMatch.split(',').some(tag => tag.trim().replace(/^W\//, '') === etag);
+}
+
+// Who is reading themes: the authenticated subject, if any, and whether the
+// caller holds the admin key
+export interface ThemeViewer {
+  subject?: string;
+  admin?: boolean;
+}
+
+// Public themes are visible to everyone, private ones to their author and admins
+function canViewTheme(theme: Theme, viewer: ThemeViewer): boolean {
+  return theme.isPublic || Boolean(viewer.admin) || (viewer.subject !== undefined && theme.authorId === viewer.subject);
+}
+
 function validateTheme(theme: Partial<Theme>): ThemeValidationError[] {
   const errors: ThemeValidationError[] = [];
   
@@ -46,32 +99,7 @@ function validateTheme(theme: Partial<Theme>): ThemeValidationError[] {
       code: 'REQUIRED'
     });
   } else {
-    // Validate color format
-    const requiredColors = [
-      'primary', 'primaryHover', 'primaryLight', 'primaryDark',
-      'bgPrimary', 'bgSecondary', 'bgTertiary', 'bgQuaternary',
-      'textPrimary', 'textSecondary', 'textTertiary', 'textQuaternary',
-      'borderPrimary', 'borderSecondary', 'borderTertiary',
-      'accentSuccess', 'accentWarning', 'accentError', 'accentInfo',
-      'shadow', 'shadowLg', 'hoverBg', 'activeBg', 'focusRing'
-    ];
-    
-    for (const colorKey of requiredColors) {
-      const color = theme.colors[colorKey as keyof typeof theme.colors];
-      if (!color) {
-        errors.push({
-          field: `colors.${colorKey}`,
-          message: `Color ${colorKey} is required`,
-          code: 'REQUIRED'
-        });
-      } else if (!isValidColor(color)) {
-        errors.push({
-          field: `colors.${colorKey}`,
-          message: `Invalid color format for ${colorKey}`,
-          code: 'INVALID_COLOR'
-        });
-      }
-    }
+    errors.push(...validateColors(theme.colors));
   }
   
   // Tags validation
@@ -91,15 +119,49 @@ function validateTheme(theme: Partial<Theme>): ThemeValidationError[] {
   return errors;
 }
 
+const REQUIRED_COLORS = [
+  'primary', 'primaryHover', 'primaryLight', 'primaryDark',
+  'bgPrimary', 'bgSecondary', 'bgTertiary', 'bgQuaternary',
+  'textPrimary', 'textSecondary', 'textTertiary', 'textQuaternary',
+  'borderPrimary', 'borderSecondary', 'borderTertiary',
+  'accentSuccess', 'accentWarning', 'accentError', 'accentInfo',
+  'shadow', 'shadowLg', 'hoverBg', 'activeBg', 'focusRing'
+];
+
+// Check every color field, reporting one error per missing or malformed value
+export function validateColors(colors: Partial<ThemeColors>): ThemeValidationError[] {
+  const errors: ThemeValidationError[] = [];
+  
+  for (const colorKey of REQUIRED_COLORS) {
+    const color = colors[colorKey as keyof ThemeColors];
+    if (!color) {
+      errors.push({
+        field: `colors.${colorKey}`,
+        message: `Color ${colorKey} is required`,
+        code: 'REQUIRED'
+      });
+    } else if (typeof color !== 'string' || !isValidColor(color)) {
+      errors.push({
+        field: `colors.${colorKey}`,
+        message: `Invalid color format for ${colorKey}: expected #rgb, #rrggbb, #rrggbbaa or rgb()/rgba()`,
+        code: 'INVALID_COLOR'
+      });
+    }
+  }
+  
+  return errors;
+}
+
 function isValidColor(color: string): boolean {
-  // Check hex colors
-  if (/^#([A-Fa-f0-9]{6}|[A-Fa-f0-9]{3})$/.test(color)) {
+  // Check hex colors (#rgb, #rrggbb, #rrggbbaa)
+  if (/^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$/.test(color)) {
     return true;
   }
   
-  // Check rgba/rgb colors
-  if (/^rgba?\((\d+),\s*(\d+),\s*(\d+)(?:,\s*(\d?(?:\.\d+)?))?\)$/.test(color)) {
-    return true;
+  // Check rgba/rgb colors, used by the translucent shadow and hover fields
+  const rgb = /^rgba?\((\d{1,3}),\s*(\d{1,3}),\s*(\d{1,3})(?:,\s*(0|1|0?\.\d+|1\.0+))?\)$/.exec(color);
+  if (rgb) {
+    return [rgb[1], rgb[2], rgb[3]].every(channel => Number(channel) <= 255);
   }
   
   // Check named colors (basic validation)
@@ -111,6 +173,157 @@ function isValidColor(color: string): boolean {
   return namedColors.includes(color.toLowerCase());
 }
 
+const NAMED_RGB: Record<string, [number, number, number]> = {
+  black: [0, 0, 0], white: [255, 255, 255], red: [255, 0, 0], green: [0, 128, 0],
+  blue: [0, 0, 255], yellow: [255, 255, 0], cyan: [0, 255, 255], magenta: [255, 0, 255],
+  gray: [128, 128, 128], grey: [128, 128, 128]
+};
+
+// Opaque RGB channels of a valid color, or null for transparent values
+function parseRgb(color: string): [number, number, number] | null {
+  const hex = /^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$/.exec(color);
+  if (hex) {
+    const digits = hex[1]!.length === 3 ? hex[1]!.split('').map(d => d + d).join('') : hex[1]!.slice(0, 6);
+    return [0, 2, 4].map(i => parseInt(digits.slice(i, i + 2), 16)) as [number, number, number];
+  }
+  
+  const rgb = /^rgba?\((\d{1,3}),\s*(\d{1,3}),\s*(\d{1,3})/.exec(color);
+  if (rgb) {
+    return [Number(rgb[1]), Number(rgb[2]), Number(rgb[3])];
+  }
+  
+  return NAMED_RGB[color.toLowerCase()] ?? null;
+}
+
+// WCAG 2 relative luminance
+function luminance([r, g, b]: [number, number, number]): number {
+  const [lr, lg, lb] = [r, g, b].map(channel => {
+    const c = channel / 255;
+    return c <= 0.03928 ? c / 12.92 : ((c + 0.055) / 1.055) ** 2.4;
+  }) as [number, number, number];
+  return 0.2126 * lr + 0.7152 * lg + 0.0722 * lb;
+}
+
+export function contrastRatio(foreground: string, background: string): number | null {
+  const fg = parseRgb(foreground);
+  const bg = parseRgb(background);
+  if (!fg || !bg) return null;
+  
+  const [lighter, darker] = [luminance(fg), luminance(bg)].sort((a, b) => b - a) as [number, number];
+  return (lighter + 0.05) / (darker + 0.05);
+}
+
+// Text/background pairs checked for readability, with the WCAG AA minimum
+// (4.5 for body text, 3 for de-emphasised text)
+const CONTRAST_PAIRS: [keyof ThemeColors, keyof ThemeColors, number][] = [
+  ['textPrimary', 'bgPrimary', 4.5],
+  ['textPrimary', 'bgSecondary', 4.5],
+  ['textSecondary', 'bgPrimary', 4.5],
+  ['textTertiary', 'bgPrimary', 3]
+];
+
+// Non-blocking checks: a theme that fails these is still saved, but the
+// response carries warnings for the author
+export function checkContrast(colors: Partial<ThemeColors>): ThemeValidationError[] {
+  const warnings: ThemeValidationError[] = [];
+  
+  for (const [text, background, minimum] of CONTRAST_PAIRS) {
+    const ratio = colors[text] && colors[background] ? contrastRatio(colors[text]!, colors[background]!) : null;
+    if (ratio !== null && ratio < minimum) {
+      warnings.push({
+        field: `colors.${text}`,
+        message: `Contrast between ${text} and ${background} is ${ratio.toFixed(2)}:1; at least ${minimum}:1 is recommended`,
+        code: 'LOW_CONTRAST'
+      });
+    }
+  }
+  
+  return warnings;
+}
+
+type Hsl = [number, number, number];
+
+function rgbToHsl([r, g, b]: [number, number, number]): Hsl {
+  const [rn, gn, bn] = [r / 255, g / 255, b / 255];
+  const max = Math.max(rn, gn, bn);
+  const min = Math.min(rn, gn, bn);
+  const l = (max + min) / 2;
+  if (max === min) return [0, 0, l * 100];
+  
+  const d = max - min;
+  const s = l > 0.5 ? d / (2 - max - min) : d / (max + min);
+  const h = max === rn ? (gn - bn) / d + (gn < bn ? 6 : 0) : max === gn ? (bn - rn) / d + 2 : (rn - gn) / d + 4;
+  return [h * 60, s * 100, l * 100];
+}
+
+function hslToHex([h, s, l]: Hsl): string {
+  const sn = Math.min(Math.max(s, 0), 100) / 100;
+  const ln = Math.min(Math.max(l, 0), 100) / 100;
+  const a = sn * Math.min(ln, 1 - ln);
+  const channel = (n: number) => {
+    const k = (n + h / 30) % 12;
+    const value = ln - a * Math.max(-1, Math.min(k - 3, 9 - k, 1));
+    return Math.round(value * 255).toString(16).padStart(2, '0');
+  };
+  return `#${channel(0)}${channel(8)}${channel(4)}`;
+}
+
+// Move a text color's lightness away from the background until it reaches
+// the contrast minimum (or the end of the lightness range)
+function ensureContrast(text: Hsl, background: string, minimum: number, dark: boolean): string {
+  let [h, s, l] = text;
+  while ((contrastRatio(hslToHex([h, s, l]), background) ?? 0) < minimum && l > 0 && l < 100) {
+    l = dark ? Math.min(l + 2, 100) : Math.max(l - 2, 0);
+  }
+  return hslToHex([h, s, l]);
+}
+
+// Derive a complete palette from one seed color. Surfaces and text share the
+// seed's hue at low saturation, text tiers are pushed to the WCAG minimums
+// checked by checkContrast, and accents use fixed hues. Deterministic for a
+// given seed and mode.
+export function generatePalette(seed: string, mode: 'light' | 'dark' = 'light'): ThemeColors | null {
+  if (!/^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$/.test(seed)) return null;
+  
+  const [r, g, b] = parseRgb(seed)!;
+  const [h, s, l] = rgbToHsl([r, g, b]);
+  const dark = mode === 'dark';
+  const tint = Math.min(s, dark ? 15 : 10);
+  const shade = (lightness: number, saturation: number = tint) => hslToHex([h, saturation, lightness]);
+  
+  const bgPrimary = shade(dark ? 8 : 100);
+  const bgSecondary = shade(dark ? 12 : 97);
+  const textOn = (lightness: number, minimum: number) => ensureContrast([h, tint, lightness], bgPrimary, minimum, dark);
+  const accent = (hue: number) => hslToHex([hue, 70, dark ? 55 : 42]);
+  
+  return {
+    primary: hslToHex([h, s, l]),
+    primaryHover: hslToHex([h, s, dark ? l + 8 : l - 8]),
+    primaryLight: hslToHex([h, s, Math.min(l + 25, 95)]),
+    primaryDark: hslToHex([h, s, Math.max(l - 25, 5)]),
+    bgPrimary,
+    bgSecondary,
+    bgTertiary: shade(dark ? 16 : 94),
+    bgQuaternary: shade(dark ? 20 : 90),
+    textPrimary: ensureContrast([h, tint, dark ? 92 : 12], bgSecondary, 4.5, dark),
+    textSecondary: textOn(dark ? 75 : 32, 4.5),
+    textTertiary: textOn(dark ? 60 : 48, 3),
+    textQuaternary: textOn(dark ? 48 : 62, 1),
+    borderPrimary: shade(dark ? 25 : 85),
+    borderSecondary: shade(dark ? 32 : 78),
+    borderTertiary: shade(dark ? 40 : 70),
+    accentSuccess: accent(142),
+    accentWarning: accent(38),
+    accentError: accent(0),
+    accentInfo: accent(210),
+    shadow: dark ? 'rgba(0, 0, 0, 0.4)' : 'rgba(0, 0, 0, 0.1)',
+    shadowLg: dark ? 'rgba(0, 0, 0, 0.6)' : 'rgba(0, 0, 0, 0.2)',
+    hoverBg: `rgba(${r}, ${g}, ${b}, 0.08)`,
+    activeBg: `rgba(${r}, ${g}, ${b}, 0.16)`,
+    focusRing: hslToHex([h, s, l])
+  };
+}
+
 function sanitizeTheme(theme: any): Partial<Theme> {
   return {
     name: theme.name?.toString().toLowerCase().replace(/[^a-z0-9-_]/g, '') || '',
@@ -125,76 +338,186 @@ function sanitizeTheme(theme: any): Partial<Theme> {
 }
 
 // Theme management functions
-export async function createTheme(themeData: any): Promise<ApiResponse<Theme>> {
+// Validate theme data and assemble the Theme to insert, checking id and name
+// against the database. Generated ids also avoid reservedIds. A client id is
+// only kept when keepId is set (imports); otherwise one is always generated.
+// Does not write.
+function buildTheme(themeData: any, reservedIds?: Set<string>, keepId: boolean = false): ApiResponse<Theme> {
+  const sanitized = sanitizeTheme(themeData);
+  const errors = validateTheme(sanitized);
+  
+  if (errors.length > 0) {
+    return {
+      success: false,
+      code: 'THEME_INVALID',
+      error: 'Validation failed',
+      validationErrors: errors
+    };
+  }
+  
+  const id = keepId && typeof themeData.id === 'string' && themeData.id ? themeData.id : newThemeId(themeData.name, reservedIds);
+  if (!/^[A-Za-z0-9-_]+$/.test(id)) {
+    return {
+      success: false,
+      code: 'THEME_INVALID',
+      error: 'Validation failed',
+      validationErrors: [{
+        field: 'id',
+        message: 'Theme id must contain only letters, numbers, hyphens, and underscores',
+        code: 'INVALID_FORMAT'
+      }]
+    };
+  }
+  if (getTheme(id)) {
+    return {
+      success: false,
+      code: 'THEME_DUPLICATE',
+      error: 'Theme id already exists',
+      validationErrors: [{
+        field: 'id',
+        message: 'A theme with this id already exists',
+        code: 'DUPLICATE'
+      }]
+    };
+  }
+  
+  // Names only need to be unique among the author's own themes
+  if (getThemeByAuthorAndName(sanitized.authorId, sanitized.name!)) {
+    return {
+      success: false,
+      code: 'THEME_DUPLICATE',
+      error: 'Theme name already exists',
+      validationErrors: [{
+        field: 'name',
+        message: 'This author already has a theme with this name',
+        code: 'DUPLICATE'
+      }]
+    };
+  }
+  
+  const theme: Theme = {
+    id,
+    name: sanitized.name!,
+    displayName: sanitized.displayName!,
+    description: sanitized.description,
+    colors: sanitized.colors!,
+    isPublic: sanitized.isPublic!,
+    authorId: sanitized.authorId,
+    authorName: sanitized.authorName,
+    createdAt: Date.now(),
+    updatedAt: Date.now(),
+    tags: sanitized.tags || [],
+    downloadCount: 0,
+    rating: 0,
+    ratingCount: 0
+  };
+  
+  return {
+    success: true,
+    data: theme
+  };
+}
+
+export async function createTheme(themeData: any, keepId: boolean = false): Promise<ApiResponse<Theme>> {
   try {
-    const sanitized = sanitizeTheme(themeData);
-    const errors = validateTheme(sanitized);
+    const built = buildTheme(themeData, undefined, keepId);
+    if (!built.success) return built;
     
-    if (errors.length > 0) {
-      return {
-        success: false,
-        error: 'Validation failed',
-        validationErrors: errors
-      };
-    }
+    const savedTheme = await insertTheme(built.data!);
+    const warnings = checkContrast(savedTheme.colors);
     
-    // Check if theme name already exists
-    const existingThemes = getThemes({ query: sanitized.name });
-    if (existingThemes.some(t => t.name === sanitized.name)) {
-      return {
-        success: false,
-        error: 'Theme name already exists',
-        validationErrors: [{
-          field: 'name',
-          message: 'A theme with this name already exists',
-          code: 'DUPLICATE'
-        }]
-      };
-    }
-    
-    const theme: Theme = {
-      id: generateId(),
-      name: sanitized.name!,
-      displayName: sanitized.displayName!,
-      description: sanitized.description,
-      colors: sanitized.colors!,
-      isPublic: sanitized.isPublic!,
-      authorId: sanitized.authorId,
-      authorName: sanitized.authorName,
-      createdAt: Date.now(),
-      updatedAt: Date.now(),
-      tags: sanitized.tags || [],
-      downloadCount: 0,
-      rating: 0,
-      ratingCount: 0
+    return {
+      success: true,
+      data: savedTheme,
+      message: 'Theme created successfully',
+      ...(warnings.length > 0 && { validationWarnings: warnings })
+    };
+  } catch (error) {
+    logger.error('Error creating theme:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
     };
+  }
+}
+
+// Create many themes at once. Every entry is validated, including id and
+// name collisions within the batch; the valid ones are inserted in a single
+// transaction. Results line up with the input array.
+export async function bulkCreateThemes(themesData: any[], authorId?: string): Promise<ApiResponse<ApiResponse<Theme>[]>> {
+  try {
+    const seenIds = new Set<string>();
+    const seenNames = new Set<string>();
+    
+    const results = themesData.map((themeData): ApiResponse<Theme> => {
+      if (!themeData || typeof themeData !== 'object') {
+        return { success: false, code: 'THEME_INVALID', error: 'Theme must be an object' };
+      }
+      
+      const built = buildTheme(authorId ? { ...themeData, authorId } : themeData, seenIds);
+      if (!built.success) return built;
+      
+      const theme = built.data!;
+      const nameKey = `${theme.authorId ?? ''}:${theme.name}`;
+      if (seenIds.has(theme.id) || seenNames.has(nameKey)) {
+        const field = seenIds.has(theme.id) ? 'id' : 'name';
+        return {
+          success: false,
+          code: 'THEME_DUPLICATE',
+          error: `Duplicate ${field} within batch`,
+          validationErrors: [{
+            field,
+            message: `Another theme in this batch has the same ${field}`,
+            code: 'DUPLICATE'
+          }]
+        };
+      }
+      seenIds.add(theme.id);
+      seenNames.add(nameKey);
+      return built;
+    });
     
-    const savedTheme = insertTheme(theme);
+    await insertThemes(results.filter(result => result.success).map(result => result.data!));
     
+    const created = results.filter(result => result.success).length;
     return {
-      success: true,
-      data: savedTheme,
-      message: 'Theme created successfully'
+      success: created === results.length,
+      data: results.map(result => result.success ? { ...result, message: 'Theme created successfully' } : result),
+      message: `Created ${created} of ${results.length} themes`
     };
   } catch (error) {
-    console.error('Error creating theme:', error);
+    logger.error('Error bulk creating themes:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
 }
 
-export async function updateThemeById(id: string, updates: any): Promise<ApiResponse<Theme>> {
+// Update a theme. When expectedUpdatedAt is given the write only applies if
+// the stored updatedAt still matches, so concurrent edits are not clobbered.
+export async function updateThemeById(id: string, updates: any, authorId?: string, expectedUpdatedAt?: number): Promise<ApiResponse<Theme>> {
   try {
     const existingTheme = getTheme(id);
     if (!existingTheme) {
       return {
         success: false,
+        code: 'THEME_NOT_FOUND',
         error: 'Theme not found'
       };
     }
     
+    // Only allow updates by the theme author when the caller is authenticated
+    if (authorId && existingTheme.authorId !== authorId) {
+      return {
+        success: false,
+        code: 'FORBIDDEN',
+        error: 'Unauthorized - you can only update your own themes'
+      };
+    }
+    
     const sanitized = sanitizeTheme(updates);
     
     // Don't allow changing the name after creation
@@ -205,52 +528,90 @@ export async function updateThemeById(id: string, updates: any): Promise<ApiResp
     if (errors.length > 0) {
       return {
         success: false,
+        code: 'THEME_INVALID',
         error: 'Validation failed',
         validationErrors: errors
       };
     }
     
+    if (expectedUpdatedAt !== undefined && existingTheme.updatedAt !== expectedUpdatedAt) {
+      return {
+        success: false,
+        code: 'THEME_CONFLICT',
+        error: 'Conflict - theme was modified by someone else',
+        data: existingTheme
+      };
+    }
+    
+    // Strictly increase updatedAt so two writes in the same millisecond still
+    // produce distinct versions
     const updateData = {
       ...sanitized,
-      updatedAt: Date.now()
+      updatedAt: Math.max(Date.now(), existingTheme.updatedAt + 1)
     };
     
-    const success = updateTheme(id, updateData);
+    const success = await updateTheme(id, updateData, expectedUpdatedAt);
+    
+    if (!success && expectedUpdatedAt !== undefined) {
+      return {
+        success: false,
+        code: 'THEME_CONFLICT',
+        error: 'Conflict - theme was modified by someone else',
+        data: getTheme(id) ?? undefined
+      };
+    }
     
     if (!success) {
       return {
         success: false,
+        code: 'INTERNAL',
         error: 'Failed to update theme'
       };
     }
     
     const updatedTheme = getTheme(id);
+    const warnings = checkContrast(updatedTheme!.colors);
     
     return {
       success: true,
       data: updatedTheme!,
-      message: 'Theme updated successfully'
+      message: 'Theme updated successfully',
+      ...(warnings.length > 0 && { validationWarnings: warnings })
     };
   } catch (error) {
-    console.error('Error updating theme:', error);
+    logger.error('Error updating theme:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
 }
 
-export async function getThemeById(id: string): Promise<ApiResponse<Theme>> {
+// Fetch a theme. Private themes the viewer may not see are reported as
+// missing. When ifNoneMatch names its current ETag the result is notModified
+// and, as a conditional hit is not a download, the download count is left
+// alone.
+export async function getThemeById(id: string, viewer: ThemeViewer = {}, ifNoneMatch?: string | null): Promise<ApiResponse<Theme> & { notModified?: boolean }> {
   try {
     const theme = getTheme(id);
     
-    if (!theme) {
+    if (!theme || !canViewTheme(theme, viewer)) {
       return {
         success: false,
+        code: 'THEME_NOT_FOUND',
         error: 'Theme not found'
       };
     }
     
+    if (matchesETag(ifNoneMatch, themeETag(theme))) {
+      return {
+        success: true,
+        data: theme,
+        notModified: true
+      };
+    }
+    
     // Increment download count for public themes
     if (theme.isPublic) {
       incrementThemeDownloadCount(id);
@@ -261,20 +622,98 @@ export async function getThemeById(id: string): Promise<ApiResponse<Theme>> {
       data: theme
     };
   } catch (error) {
-    console.error('Error getting theme:', error);
+    logger.error('Error getting theme:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+// Fetch several themes at once. Missing ids, and private themes the viewer
+// may not see, are simply absent from the result; download counts are not
+// incremented.
+export async function getThemesByIdList(ids: string[], viewer: ThemeViewer = {}): Promise<ApiResponse<Theme[]>> {
+  try {
+    return {
+      success: true,
+      data: getThemesByIds([...new Set(ids)]).filter(theme => canViewTheme(theme, viewer))
+    };
+  } catch (error) {
+    logger.error('Error getting themes by id:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
 }
 
-export async function searchThemes(query: ThemeSearchQuery): Promise<ApiResponse<Theme[]>> {
+// An author's profile with their themes, newest first. Private themes are
+// only included for the author themselves and admins.
+export async function getAuthorThemes(authorId: string, viewer: ThemeViewer = {}): Promise<ApiResponse<{ author: Author; themes: Theme[] }>> {
+  try {
+    const author = getAuthor(authorId);
+    if (!author) {
+      return {
+        success: false,
+        code: 'AUTHOR_NOT_FOUND',
+        error: 'Author not found'
+      };
+    }
+    
+    const themes = getThemes({ authorId, visibleTo: viewer.admin ? undefined : viewer.subject ?? null, sortBy: 'created', sortOrder: 'desc' });
+    return {
+      success: true,
+      data: { author, themes }
+    };
+  } catch (error) {
+    logger.error('Error getting author themes:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+// Set an author's display name; one row changes however many themes they own
+export async function renameAuthor(authorId: string, name: unknown): Promise<ApiResponse<Author>> {
+  if (typeof name !== 'string' || !name.trim() || name.length > 100) {
+    return {
+      success: false,
+      code: 'THEME_INVALID',
+      error: 'Validation failed',
+      validationErrors: [{ field: 'name', message: 'name must be a non-empty string of at most 100 characters', code: 'INVALID_FORMAT' }]
+    };
+  }
+  
+  try {
+    return {
+      success: true,
+      data: await upsertAuthor(authorId, name.trim()),
+      message: 'Author updated successfully'
+    };
+  } catch (error) {
+    logger.error('Error updating author:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+// Search visible themes. Anonymous callers only see public themes, an
+// authenticated author also sees their own private ones, and an admin sees
+// everything. isPublic in the query narrows the results but never widens them.
+export async function searchThemes(query: ThemeSearchQuery, viewer: ThemeViewer = {}): Promise<ApiResponse<Theme[]>> {
   try {
-    // Default to only public themes unless specific author requested
     const searchQuery = {
       ...query,
-      isPublic: query.authorId ? undefined : true
+      visibleTo: viewer.admin ? undefined : viewer.subject ?? null,
+      limit: query.limit ?? config.DEFAULT_PAGE_SIZE
     };
     
     const themes = getThemes(searchQuery);
@@ -284,9 +723,10 @@ export async function searchThemes(query: ThemeSearchQuery): Promise<ApiResponse
       data: themes
     };
   } catch (error) {
-    console.error('Error searching themes:', error);
+    logger.error('Error searching themes:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
@@ -299,6 +739,7 @@ export async function deleteThemeById(id: string, authorId?: string): Promise<Ap
     if (!theme) {
       return {
         success: false,
+        code: 'THEME_NOT_FOUND',
         error: 'Theme not found'
       };
     }
@@ -307,6 +748,7 @@ export async function deleteThemeById(id: string, authorId?: string): Promise<Ap
     if (authorId && theme.authorId !== authorId) {
       return {
         success: false,
+        code: 'FORBIDDEN',
         error: 'Unauthorized - you can only delete your own themes'
       };
     }
@@ -316,6 +758,7 @@ export async function deleteThemeById(id: string, authorId?: string): Promise<Ap
     if (!success) {
       return {
         success: false,
+        code: 'INTERNAL',
         error: 'Failed to delete theme'
       };
     }
@@ -325,21 +768,24 @@ export async function deleteThemeById(id: string, authorId?: string): Promise<Ap
       message: 'Theme deleted successfully'
     };
   } catch (error) {
-    console.error('Error deleting theme:', error);
+    logger.error('Error deleting theme:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
 }
 
-export async function exportThemeById(id: string): Promise<ApiResponse<any>> {
+// Private themes the viewer may not see are reported as missing
+export async function exportThemeById(id: string, viewer: ThemeViewer = {}): Promise<ApiResponse<any>> {
   try {
     const theme = getTheme(id);
     
-    if (!theme) {
+    if (!theme || !canViewTheme(theme, viewer)) {
       return {
         success: false,
+        code: 'THEME_NOT_FOUND',
         error: 'Theme not found'
       };
     }
@@ -348,7 +794,7 @@ export async function exportThemeById(id: string): Promise<ApiResponse<any>> {
       version: '1.0.0',
       theme: {
         ...theme,
-        // Remove server-specific data for export
+        // Remove server-managed data for export; re-imports match by name
         id: undefined,
         authorId: undefined,
         downloadCount: undefined,
@@ -366,19 +812,21 @@ export async function exportThemeById(id: string): Promise<ApiResponse<any>> {
       data: exportData
     };
   } catch (error) {
-    console.error('Error exporting theme:', error);
+    logger.error('Error exporting theme:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
 }
 
-export async function importTheme(importData: any, authorId?: string): Promise<ApiResponse<Theme>> {
+export async function importTheme(importData: any, authorId?: string, overwrite: boolean = false): Promise<ApiResponse<Theme>> {
   try {
     if (!importData.theme) {
       return {
         success: false,
+        code: 'THEME_INVALID',
         error: 'Invalid import data - missing theme'
       };
     }
@@ -386,15 +834,197 @@ export async function importTheme(importData: any, authorId?: string): Promise<A
     const themeData = {
       ...importData.theme,
       authorId,
-      authorName: importData.theme.authorName || 'Imported',
+      // The exported name belongs to the original author, not the importer,
+      // and would rename the importer's author profile
+      authorName: undefined,
       isPublic: false // Imported themes are private by default
     };
     
-    return await createTheme(themeData);
+    // Detect collisions on id or name before inserting
+    const existing = (themeData.id ? getTheme(themeData.id) : null)
+      ?? (typeof themeData.name === 'string' ? getThemeByAuthorAndName(authorId, themeData.name) : null);
+    
+    if (existing) {
+      if (!overwrite) {
+        return {
+          success: false,
+          code: 'THEME_DUPLICATE',
+          error: 'Theme already exists',
+          validationErrors: [{
+            field: existing.id === themeData.id ? 'id' : 'name',
+            message: 'A theme with this id or name already exists; pass overwrite=true to replace it',
+            code: 'DUPLICATE'
+          }]
+        };
+      }
+      
+      // Only the owner may replace a theme; ownerless themes only by an
+      // anonymous importer
+      if ((existing.authorId ?? undefined) !== authorId) {
+        return {
+          success: false,
+          code: 'FORBIDDEN',
+          error: 'Unauthorized - you can only overwrite your own themes'
+        };
+      }
+      return await updateThemeById(existing.id, themeData, authorId);
+    }
+    
+    return await createTheme(themeData, true);
+  } catch (error) {
+    logger.error('Error importing theme:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+// Create a new theme from an existing one. Colors and tags are copied; the
+// clone gets its own id, timestamps, author and zeroed download/rating stats.
+// Only public themes and the caller's own can be cloned, and the clone always
+// belongs to the caller.
+export async function cloneTheme(sourceId: string, options: any = {}, authorId?: string): Promise<ApiResponse<Theme>> {
+  try {
+    const source = getTheme(sourceId);
+    // Report another author's private theme as missing rather than forbidden
+    if (!source || (!source.isPublic && (!authorId || source.authorId !== authorId))) {
+      return {
+        success: false,
+        code: 'THEME_NOT_FOUND',
+        error: 'Theme not found'
+      };
+    }
+    
+    // Default to the first "<name>-copy[-n]" name free for the clone's author
+    let name = options.name;
+    if (!name) {
+      name = `${source.name}-copy`;
+      for (let n = 2; getThemeByAuthorAndName(authorId, name); n++) {
+        name = `${source.name}-copy-${n}`;
+      }
+    }
+    
+    return await createTheme({
+      name,
+      displayName: options.displayName || `${source.displayName} (Copy)`,
+      description: options.description ?? source.description,
+      colors: { ...source.colors },
+      tags: [...source.tags],
+      isPublic: Boolean(options.isPublic),
+      authorId,
+      authorName: options.authorName
+    });
+  } catch (error) {
+    logger.error('Error cloning theme:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+// Build a theme around a generated palette. Only saved (via createTheme)
+// when save is set; otherwise the unsaved theme fields are returned.
+export async function generateTheme(options: any, save: boolean = false, authorId?: string): Promise<ApiResponse<Partial<Theme>>> {
+  const mode = options.mode ?? 'light';
+  if (mode !== 'light' && mode !== 'dark') {
+    return {
+      success: false,
+      code: 'THEME_INVALID',
+      error: 'Validation failed',
+      validationErrors: [{ field: 'mode', message: 'mode must be "light" or "dark"', code: 'INVALID_FORMAT' }]
+    };
+  }
+  
+  const colors = typeof options.seed === 'string' ? generatePalette(options.seed, mode) : null;
+  if (!colors) {
+    return {
+      success: false,
+      code: 'THEME_INVALID',
+      error: 'Validation failed',
+      validationErrors: [{ field: 'seed', message: 'seed must be a #rgb or #rrggbb color', code: 'INVALID_COLOR' }]
+    };
+  }
+  
+  const theme = {
+    name: options.name,
+    displayName: options.displayName,
+    description: options.description,
+    tags: Array.isArray(options.tags) ? options.tags : [mode, 'generated'],
+    isPublic: Boolean(options.isPublic),
+    colors
+  };
+  if (save) {
+    // The owner is the authenticated caller, never a field of the request
+    return await createTheme({ ...theme, authorId, authorName: options.authorName });
+  }
+  
+  return {
+    success: true,
+    data: theme
+  };
+}
+
+// Tags in use across all themes, alphabetically; with counts each entry is
+// { tag, count } where count is the number of themes carrying the tag
+export async function getAllThemeTags(withCounts: boolean = false): Promise<ApiResponse<string[] | { tag: string; count: number }[]>> {
+  try {
+    const tagCounts = getThemeTagCounts();
+    return {
+      success: true,
+      data: withCounts ? tagCounts : tagCounts.map(row => row.tag)
+    };
+  } catch (error) {
+    logger.error('Error getting theme tags:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+// Public featured themes, best rated first
+export async function getFeaturedThemes(limit?: number, offset?: number): Promise<ApiResponse<Theme[]>> {
+  try {
+    const themes = getThemes({ isPublic: true, isFeatured: true, sortBy: 'rating', sortOrder: 'desc', limit, offset });
+    return {
+      success: true,
+      data: themes
+    };
+  } catch (error) {
+    logger.error('Error getting featured themes:', error);
+    return {
+      success: false,
+      code: 'INTERNAL',
+      error: 'Internal server error'
+    };
+  }
+}
+
+export async function setFeatured(id: string, featured: boolean): Promise<ApiResponse<Theme>> {
+  try {
+    if (!(await setThemeFeatured(id, featured))) {
+      return {
+        success: false,
+        code: 'THEME_NOT_FOUND',
+        error: 'Theme not found'
+      };
+    }
+    
+    return {
+      success: true,
+      data: getTheme(id)!,
+      message: featured ? 'Theme featured' : 'Theme unfeatured'
+    };
   } catch (error) {
-    console.error('Error importing theme:', error);
+    logger.error('Error setting featured flag:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
@@ -421,9 +1051,10 @@ export async function getThemeStats(): Promise<ApiResponse<any>> {
       data: stats
     };
   } catch (error) {
-    console.error('Error getting theme stats:', error);
+    logger.error('Error getting theme stats:', error);
     return {
       success: false,
+      code: 'INTERNAL',
       error: 'Internal server error'
     };
   }
@@ -0,0 +1,61 @@
+import type { HookEvent, TraceNode, ToolCallSpan } from './types';
+
+// Build a tool-call tree for one session. Events must be in chronological order.
+// Each PreToolUse opens a span that is closed by the next PostToolUse for the
+// same tool; events seen while a span is open nest under the innermost one.
+// Spans with no PostToolUse are reported with end = null.
+export function buildSessionTrace(events: HookEvent[]): TraceNode[] {
+  const roots: TraceNode[] = [];
+  const open: ToolCallSpan[] = [];
+  
+  const append = (node: TraceNode) => {
+    const parent = open[open.length - 1];
+    if (parent) {
+      parent.children.push(node);
+    } else {
+      roots.push(node);
+    }
+  };
+  
+  for (const event of events) {
+    const toolName = event.payload?.tool_name;
+    
+    if (event.hook_event_type === 'PreToolUse') {
+      const span: ToolCallSpan = {
+        type: 'tool_call',
+        tool_name: toolName ?? null,
+        start: event.timestamp!,
+        end: null,
+        pre: event,
+        children: []
+      };
+      append(span);
+      open.push(span);
+      continue;
+    }
+    
+    if (event.hook_event_type === 'PostToolUse') {
+      // Close the innermost open span for this tool; anything opened inside it
+      // that never completed is closed along with it.
+      let index = -1;
+      for (let i = open.length - 1; i >= 0; i--) {
+        if (open[i]!.tool_name === (toolName ?? null)) {
+          index = i;
+          break;
+        }
+      }
+      
+      if (index >= 0) {
+        const span = open[index]!;
+        span.end = event.timestamp!;
+        span.post = event;
+        open.length = index;
+        continue;
+      }
+    }
+    
+    append({ type: 'event', event });
+  }
+  
+  return roots;
+}
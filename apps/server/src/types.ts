@@ -1,3 +1,24 @@
+import type { ErrorCode } from './errors';
+
+// Hook event types emitted by Claude Code hooks
+export const HOOK_EVENT_TYPES = [
+  'PreToolUse',
+  'PostToolUse',
+  'Notification',
+  'Stop',
+  'SubagentStop',
+  'PreCompact',
+  'UserPromptSubmit',
+  'SessionStart',
+  'SessionEnd'
+] as const;
+
+export type HookEventType = typeof HOOK_EVENT_TYPES[number];
+
+export function isKnownHookEventType(type: string): type is HookEventType {
+  return (HOOK_EVENT_TYPES as readonly string[]).includes(type);
+}
+
 export interface HookEvent {
   id?: number;
   source_app: string;
@@ -6,7 +27,27 @@ export interface HookEvent {
   payload: Record<string, any>;
   chat?: any[];
   summary?: string;
+  // Display-length summary, computed on read when SUMMARY_PREVIEW_CHARS is set
+  summary_preview?: string;
   timestamp?: number;
+  // Client-supplied idempotency key; re-posting the same uuid is a no-op
+  event_uuid?: string;
+  // Set by the server when sampling kept this event as 1 of sample_rate
+  sampled?: boolean;
+  sample_rate?: number;
+}
+
+export interface InsertEventResult {
+  event: HookEvent;
+  created: boolean;
+}
+
+// Envelope for frames sent to WebSocket clients
+export interface WebSocketMessage {
+  seq: number;
+  type: string;
+  data: any;
+  timestamp: number;
 }
 
 export interface FilterOptions {
@@ -15,6 +56,91 @@ export interface FilterOptions {
   hook_event_types: string[];
 }
 
+export interface FilterOptionsQuery {
+  sourceAppPrefix?: string;
+  sourceAppLimit?: number;
+  sessionPrefix?: string;
+  sessionLimit?: number;
+  hookEventTypePrefix?: string;
+  hookEventTypeLimit?: number;
+}
+
+export interface EventFilter {
+  // A list matches any of its values
+  source_app?: string | string[];
+  session_id?: string | string[];
+  hook_event_type?: string | string[];
+  start?: number;
+  end?: number;
+  includeDeleted?: boolean;
+  // Include events older than EVENT_TTL_HOURS
+  includeExpired?: boolean;
+  // Exact matches on payload fields, keyed by dotted path (e.g. "tool_input.command")
+  payload?: Record<string, string>;
+}
+
+export interface HookCoverage {
+  source_app: string;
+  emitted: { hook_event_type: string; count: number }[];
+  missing: string[];
+}
+
+export interface ToolCallSpan {
+  type: 'tool_call';
+  tool_name: string | null;
+  start: number;
+  end: number | null;
+  pre: HookEvent;
+  post?: HookEvent;
+  children: TraceNode[];
+}
+
+export type TraceNode = ToolCallSpan | { type: 'event'; event: HookEvent };
+
+export interface EventStats {
+  total: number;
+  // Events received, counting each sampled event as sample_rate events
+  estimated_total: number;
+  by_type: Record<string, number>;
+  by_source: Record<string, number>;
+  by_session: Record<string, number>;
+}
+
+export interface DuplicateGroup {
+  source_app: string;
+  hook_event_type: string;
+  payload_hash: string;
+  count: number;
+  first_seen: number;
+  last_seen: number;
+  sample_event_id: number;
+}
+
+export interface TimelineBucket {
+  bucketStart: number;
+  count: number;
+}
+
+export interface TopSession {
+  session_id: string;
+  source_app: string;
+  count: number;
+}
+
+export interface SessionSummary {
+  session_id: string;
+  source_app: string;
+  event_count: number;
+  first_timestamp: number;
+  last_timestamp: number;
+  latest_summary: string | null;
+}
+
+export interface EventPage {
+  data: HookEvent[];
+  nextCursor: number | null;
+}
+
 // Theme-related interfaces for server-side storage and API
 export interface ThemeColors {
   primary: string;
@@ -50,6 +176,8 @@ export interface Theme {
   description?: string;
   colors: ThemeColors;
   isPublic: boolean;
+  // Editor-curated; set through the admin API only
+  isFeatured?: boolean;
   authorId?: string;
   authorName?: string;
   createdAt: number;
@@ -60,11 +188,22 @@ export interface Theme {
   ratingCount?: number;
 }
 
+export interface Author {
+  id: string;
+  name?: string;
+  createdAt: number;
+  updatedAt: number;
+}
+
 export interface ThemeSearchQuery {
   query?: string;
   tags?: string[];
   authorId?: string;
   isPublic?: boolean;
+  isFeatured?: boolean;
+  // Restrict to public themes plus this author's private ones; null means
+  // public themes only
+  visibleTo?: string | null;
   sortBy?: 'name' | 'created' | 'updated' | 'downloads' | 'rating';
   sortOrder?: 'asc' | 'desc';
   limit?: number;
@@ -91,16 +230,22 @@ export interface ThemeRating {
   createdAt: number;
 }
 
-export interface ThemeValidationError {
+export interface ValidationError {
   field: string;
   message: string;
   code: string;
 }
 
+export type ThemeValidationError = ValidationError;
+
 export interface ApiResponse<T = any> {
   success: boolean;
   data?: T;
+  // Set on every failure; decides the HTTP status (see respondApiResult)
+  code?: ErrorCode;
   error?: string;
   message?: string;
   validationErrors?: ThemeValidationError[];
+  // Problems that did not block the request
+  validationWarnings?: ThemeValidationError[];
 }
\ No newline at end of file
@@ -0,0 +1,82 @@
+import { config } from './config';
+import { logger } from './logger';
+import { recordWebhookBreakerState, recordWebhookDelivery } from './metrics';
+import { CircuitBreaker } from './breaker';
+import type { HookEvent } from './types';
+
+let breakers: CircuitBreaker[] = [];
+let breakerSettings = '';
+
+// One breaker per EVENT_WEBHOOKS entry, so a dead endpoint is skipped
+// instead of retried on every event. Rebuilt when the webhook list or the
+// breaker settings change.
+function currentBreakers(): CircuitBreaker[] {
+  const settings = JSON.stringify([config.EVENT_WEBHOOKS, config.EVENT_WEBHOOK_BREAKER_THRESHOLD, config.EVENT_WEBHOOK_BREAKER_COOLDOWN_MS]);
+  if (settings === breakerSettings) return breakers;
+  
+  breakerSettings = settings;
+  breakers = config.EVENT_WEBHOOKS.map((url, index) => {
+    recordWebhookBreakerState(index, 'closed');
+    return new CircuitBreaker(config.EVENT_WEBHOOK_BREAKER_THRESHOLD, config.EVENT_WEBHOOK_BREAKER_COOLDOWN_MS, state => {
+      recordWebhookBreakerState(index, state);
+      logger.warn(`Event webhook ${new URL(url).host} circuit ${state.replace('_', '-')}`);
+    });
+  });
+  return breakers;
+}
+
+currentBreakers();
+
+// POST the event to one URL, retrying failed attempts with exponential
+// backoff. Resolves true once delivered, false when every attempt failed.
+async function deliver(url: string, event: HookEvent): Promise<boolean> {
+  const body = JSON.stringify(event);
+  
+  for (let attempt = 0; attempt <= config.EVENT_WEBHOOK_RETRIES; attempt++) {
+    if (attempt > 0) {
+      await Bun.sleep(config.EVENT_WEBHOOK_BACKOFF_MS * 2 ** (attempt - 1));
+    }
+    
+    try {
+      const response = await fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body,
+        signal: AbortSignal.timeout(config.EVENT_WEBHOOK_TIMEOUT_MS)
+      });
+      if (response.ok) return true;
+      logger.warn(`Event webhook ${new URL(url).host} returned ${response.status} (attempt ${attempt + 1})`);
+    } catch (error) {
+      logger.warn(`Event webhook ${new URL(url).host} failed (attempt ${attempt + 1}):`, error instanceof Error ? error.message : error);
+    }
+  }
+  
+  return false;
+}
+
+// Forward a newly saved event to every EVENT_WEBHOOKS URL whose breaker is
+// not open, restricted to EVENT_WEBHOOK_TYPES when set. Runs in the
+// background; delivery failures are logged and counted, never thrown.
+export function notifyWebhooks(event: HookEvent): void {
+  if (config.EVENT_WEBHOOKS.length === 0) return;
+  if (config.EVENT_WEBHOOK_TYPES.length > 0 && !config.EVENT_WEBHOOK_TYPES.includes(event.hook_event_type)) return;
+  
+  const breakers = currentBreakers();
+  config.EVENT_WEBHOOKS.forEach((url, index) => {
+    const breaker = breakers[index]!;
+    if (!breaker.allowRequest()) {
+      recordWebhookDelivery(index, 'skipped');
+      return;
+    }
+    
+    deliver(url, event).then(delivered => {
+      recordWebhookDelivery(index, delivered ? 'success' : 'failure');
+      if (delivered) {
+        breaker.recordSuccess();
+      } else {
+        breaker.recordFailure();
+        logger.error(`Event webhook ${new URL(url).host} gave up on event ${event.id} after ${config.EVENT_WEBHOOK_RETRIES + 1} attempts`);
+      }
+    });
+  });
+}
@@ -0,0 +1,411 @@
+import type { ServerWebSocket } from 'bun';
+import { getRecentEvents, countEvents, getEventsBySession } from './db';
+import { config } from './config';
+import { toBroadcastEvent } from './event';
+import type { WebSocketMessage } from './types';
+import { recordWsDropped, recordWsSlowDisconnect } from './metrics';
+import { logger } from './logger';
+import { broadcastSse } from './sse';
+import { createSessionCache } from './lru';
+
+// Per-connection state attached via server.upgrade(req, { data })
+export interface ClientData {
+  id: string;
+  remoteAddress?: string;
+  connectedAt: number;
+  framesSent: number;
+  lastPongAt: number;
+  // Authenticated token subject, when stream auth is enabled
+  subject?: string;
+  // Sequence number of the last frame addressed to this client
+  seq: number;
+  // Pending timer and session of an in-progress session replay
+  replayTimer?: ReturnType<typeof setTimeout>;
+  replaySessionId?: string;
+  // Most recent frames, oldest first, for resume after a reconnect
+  history: BufferedFrame[];
+}
+
+interface BufferedFrame {
+  seq: number;
+  payload: string;
+}
+
+// Frames of recently disconnected clients, by client id, until they resume
+// or WS_RESUME_RETENTION_MS passes. Broadcasts keep being numbered and
+// buffered for them meanwhile. At most MAX_TRACKED_SESSIONS are kept; the
+// longest detached are dropped first and get resync_required.
+interface DetachedClient {
+  subject?: string;
+  seq: number;
+  history: BufferedFrame[];
+  expiresAt: number;
+}
+const detachedClients = createSessionCache<DetachedClient>();
+
+// Store WebSocket clients
+export const wsClients = new Set<ServerWebSocket<ClientData>>();
+
+export function newClientData(subject?: string, remoteAddress?: string): ClientData {
+  const now = Date.now();
+  return {
+    id: crypto.randomUUID(),
+    remoteAddress,
+    connectedAt: now,
+    framesSent: 0,
+    lastPongAt: now,
+    subject,
+    seq: 0,
+    history: []
+  };
+}
+
+// Keep the frame for resume, dropping the oldest past WS_RESUME_BUFFER_SIZE
+function remember(history: BufferedFrame[], seq: number, payload: string): void {
+  if (config.WS_RESUME_BUFFER_SIZE <= 0) return;
+  history.push({ seq, payload });
+  if (history.length > config.WS_RESUME_BUFFER_SIZE) history.shift();
+}
+
+function pruneDetachedClients(now: number = Date.now()): void {
+  detachedClients.forEach((detached, id) => {
+    if (detached.expiresAt <= now) detachedClients.delete(id);
+  });
+}
+
+function detachClient(client: ServerWebSocket<ClientData>): void {
+  if (config.WS_RESUME_BUFFER_SIZE <= 0 || config.WS_RESUME_RETENTION_MS <= 0) return;
+  const { id, subject, seq, history } = client.data;
+  detachedClients.set(id, { subject, seq, history, expiresAt: Date.now() + config.WS_RESUME_RETENTION_MS });
+}
+
+// Snapshot of connected clients for the admin API
+export function describeClients(): Record<string, unknown>[] {
+  return [...wsClients].map(client => ({
+    id: client.data.id,
+    remoteAddress: client.data.remoteAddress ?? null,
+    subject: client.data.subject ?? null,
+    connectedAt: client.data.connectedAt,
+    lastPongAt: client.data.lastPongAt,
+    framesSent: client.data.framesSent,
+    // Live frames go to every client unfiltered, so the only session a
+    // connection is tied to is one it is replaying
+    subscribedSessions: client.data.replaySessionId ? [client.data.replaySessionId] : [],
+    lastSeq: client.data.seq,
+    bufferedBytes: client.getBufferedAmount()
+  }));
+}
+
+// Send a message to every connected client, WebSocket and SSE. A socket
+// whose unsent buffer exceeds WS_BACKPRESSURE_LIMIT_BYTES is either
+// disconnected or skipped, per WS_SLOW_CLIENT_POLICY, so one slow consumer
+// never stalls the rest. WebSocket frames carry the server send time and a
+// per-connection seq that increases by one per frame, so clients can detect
+// dropped frames and resync.
+export function broadcast(message: { type: string; data: any }): void {
+  // Serialize once; each client's seq is spliced onto the front
+  const body = JSON.stringify({ type: message.type, data: message.data, timestamp: Date.now() }).slice(1);
+  const failed: ServerWebSocket<ClientData>[] = [];
+  
+  wsClients.forEach(client => {
+    // Frames skipped below still consume a seq, so the client sees the gap
+    const seq = ++client.data.seq;
+    const payload = `{"seq":${seq},${body}`;
+    // Buffered even when skipped, so a resume fills the gap
+    remember(client.data.history, seq, payload);
+    
+    if (client.getBufferedAmount() > config.WS_BACKPRESSURE_LIMIT_BYTES) {
+      if (config.WS_SLOW_CLIENT_POLICY === 'disconnect') {
+        recordWsSlowDisconnect();
+        failed.push(client);
+        client.close(1013, 'Client too slow');
+      } else {
+        recordWsDropped();
+      }
+      return;
+    }
+    
+    try {
+      // 0 means the frame was dropped by the runtime
+      if (client.send(payload) === 0) {
+        recordWsDropped();
+      } else {
+        client.data.framesSent++;
+      }
+    } catch (err) {
+      // Client disconnected
+      failed.push(client);
+    }
+  });
+  
+  // Remove after iterating so the set is never mutated mid-broadcast
+  failed.forEach(client => wsClients.delete(client));
+  
+  // Disconnected clients miss this frame until they resume. Once more frames
+  // arrive than the buffer holds, the oldest missed ones are gone and the
+  // resume answers resync_required.
+  pruneDetachedClients();
+  detachedClients.forEach(detached => {
+    const seq = ++detached.seq;
+    remember(detached.history, seq, `{"seq":${seq},${body}`);
+  });
+  
+  broadcastSse(message);
+}
+
+// Send one seq-numbered frame to a single client
+function sendTo(ws: ServerWebSocket<ClientData>, type: string, data: any): void {
+  const message: WebSocketMessage = { seq: ++ws.data.seq, type, data, timestamp: Date.now() };
+  const payload = JSON.stringify(message);
+  remember(ws.data.history, message.seq, payload);
+  if (ws.send(payload) !== 0) ws.data.framesSent++;
+}
+
+// Continue a previous connection's stream on this one. When every frame
+// after lastSeq is still buffered they are re-sent verbatim, this socket
+// takes over the old client id and seq, and a resumed frame follows.
+// Otherwise the client gets resync_required and must reload its state.
+function resumeClient(ws: ServerWebSocket<ClientData>, clientId: string, lastSeq: number): void {
+  pruneDetachedClients();
+  const detached = detachedClients.get(clientId);
+  // Frames after lastSeq are all buffered if the oldest kept one is no later
+  // than lastSeq + 1, or nothing was sent after lastSeq at all
+  const oldest = detached?.history[0]?.seq ?? Infinity;
+  const available = detached !== undefined && detached.subject === ws.data.subject &&
+    lastSeq <= detached.seq && (oldest <= lastSeq + 1 || lastSeq === detached.seq);
+  
+  if (!available) {
+    sendTo(ws, 'resync_required', { client_id: ws.data.id });
+    return;
+  }
+  
+  detachedClients.delete(clientId);
+  const missed = detached.history.filter(frame => frame.seq > lastSeq);
+  missed.forEach(frame => {
+    if (ws.send(frame.payload) !== 0) ws.data.framesSent++;
+  });
+  
+  ws.data.id = clientId;
+  ws.data.seq = detached.seq;
+  ws.data.history = detached.history;
+  sendTo(ws, 'resumed', { client_id: clientId, replayed: missed.length });
+}
+
+// Longest pause between replayed events, before speed scaling, so a session
+// with an idle gap of hours still finishes
+const REPLAY_MAX_GAP_MS = 10000;
+
+function cancelReplay(ws: ServerWebSocket<ClientData>): boolean {
+  if (!ws.data.replayTimer) return false;
+  clearTimeout(ws.data.replayTimer);
+  ws.data.replayTimer = undefined;
+  ws.data.replaySessionId = undefined;
+  return true;
+}
+
+// Stream a session's stored events to one client as replay_event frames,
+// spaced by their original gaps divided by speed, then send replay_complete.
+// Events are loaded a page (MAX_PAGE_SIZE) at a time as the replay reaches
+// them, so sessions of any length play through. Starting a new replay
+// cancels the previous one.
+function startReplay(ws: ServerWebSocket<ClientData>, sessionId: string, speed: number): void {
+  cancelReplay(ws);
+  let page = getEventsBySession(sessionId, config.MAX_PAGE_SIZE);
+  if (page.length === 0) {
+    sendTo(ws, 'error', { code: 'SESSION_NOT_FOUND', message: `No events for session ${sessionId}` });
+    return;
+  }
+  
+  const total = countEvents({ session_id: sessionId });
+  sendTo(ws, 'replay_started', { session_id: sessionId, speed, count: total });
+  ws.data.replaySessionId = sessionId;
+  
+  let pageStart = 0;
+  let sent = 0;
+  
+  const step = (index: number) => {
+    ws.data.replayTimer = undefined;
+    if (ws.readyState !== WebSocket.OPEN) return;
+    
+    const event = page[index]!;
+    sendTo(ws, 'replay_event', toBroadcastEvent(event));
+    sent++;
+    
+    let nextIndex = index + 1;
+    // A full page may be followed by more
+    if (nextIndex === page.length && page.length === config.MAX_PAGE_SIZE) {
+      pageStart += page.length;
+      page = getEventsBySession(sessionId, config.MAX_PAGE_SIZE, pageStart);
+      nextIndex = 0;
+    }
+    const next = page[nextIndex];
+    if (!next) {
+      ws.data.replaySessionId = undefined;
+      sendTo(ws, 'replay_complete', { session_id: sessionId, count: sent });
+      return;
+    }
+    
+    const gap = Math.min(Math.max((next.timestamp ?? 0) - (event.timestamp ?? 0), 0), REPLAY_MAX_GAP_MS);
+    ws.data.replayTimer = setTimeout(() => step(nextIndex), gap / speed);
+  };
+  step(0);
+}
+
+// Client commands: {"type":"replay","session_id":"...","speed":2},
+// {"type":"replay_cancel"} and {"type":"resume","client_id":"...","last_seq":N}.
+// Bad commands get an error frame.
+function handleClientMessage(ws: ServerWebSocket<ClientData>, raw: string | Buffer): void {
+  let command: any;
+  try {
+    command = JSON.parse(raw.toString());
+  } catch {
+    sendTo(ws, 'error', { code: 'INVALID_BODY', message: 'Messages must be JSON' });
+    return;
+  }
+  
+  if (command?.type === 'replay') {
+    const speed = command.speed ?? 1;
+    if (typeof command.session_id !== 'string' || !command.session_id) {
+      sendTo(ws, 'error', { code: 'INVALID_PARAMETER', message: 'session_id is required' });
+    } else if (typeof speed !== 'number' || !(speed > 0) || speed > 1000) {
+      sendTo(ws, 'error', { code: 'INVALID_PARAMETER', message: 'speed must be a number between 0 and 1000' });
+    } else {
+      startReplay(ws, command.session_id, speed);
+    }
+  } else if (command?.type === 'replay_cancel') {
+    if (cancelReplay(ws)) sendTo(ws, 'replay_cancelled', {});
+  } else if (command?.type === 'resume') {
+    if (typeof command.client_id !== 'string' || !command.client_id) {
+      sendTo(ws, 'error', { code: 'INVALID_PARAMETER', message: 'client_id is required' });
+    } else if (!Number.isInteger(command.last_seq) || command.last_seq < 0) {
+      sendTo(ws, 'error', { code: 'INVALID_PARAMETER', message: 'last_seq must be a non-negative integer' });
+    } else {
+      resumeClient(ws, command.client_id, command.last_seq);
+    }
+  } else {
+    sendTo(ws, 'error', { code: 'INVALID_PARAMETER', message: `Unknown message type: ${command?.type}` });
+  }
+}
+
+export function getClientCount(): number {
+  return wsClients.size;
+}
+
+let statsTimer: ReturnType<typeof setInterval> | undefined;
+let sweepTimer: ReturnType<typeof setInterval> | undefined;
+let lastSweepAt = 0;
+
+// Periodically broadcast light stats so idle dashboards know the feed is alive
+export function startStatsBroadcast(): void {
+  if (config.WS_STATS_INTERVAL_MS <= 0) return;
+  
+  statsTimer = setInterval(() => {
+    if (wsClients.size === 0) return;
+    broadcast({
+      type: 'heartbeat',
+      data: {
+        count: countEvents(),
+        clients: wsClients.size,
+        time: Date.now()
+      }
+    });
+  }, config.WS_STATS_INTERVAL_MS);
+}
+
+export function stopStatsBroadcast(): void {
+  if (statsTimer) {
+    clearInterval(statsTimer);
+    statsTimer = undefined;
+  }
+}
+
+// Send a going-away close frame to every client and stop background timers
+export function shutdownWebSockets(): void {
+  stopStatsBroadcast();
+  if (sweepTimer) {
+    clearInterval(sweepTimer);
+    sweepTimer = undefined;
+  }
+  
+  wsClients.forEach(client => {
+    try {
+      client.close(1001, 'Server shutting down');
+    } catch (err) {
+      // Already closed
+    }
+  });
+  wsClients.clear();
+}
+
+// One cleanup pass over all clients: drop sockets that are no longer open,
+// terminate clients that missed a pong for longer than the grace period
+// (one extra heartbeat interval), and ping the rest.
+export function sweepClients(): void {
+  const now = Date.now();
+  lastSweepAt = now;
+  pruneDetachedClients(now);
+  const dead: ServerWebSocket<ClientData>[] = [];
+  
+  wsClients.forEach(client => {
+    if (client.readyState !== WebSocket.OPEN) {
+      dead.push(client);
+      return;
+    }
+    
+    if (now - client.data.lastPongAt > config.WS_HEARTBEAT_INTERVAL * 2) {
+      logger.warn('WebSocket client missed heartbeat, terminating');
+      // A dead peer never completes the close handshake, so tear the
+      // socket down without waiting
+      dead.push(client);
+      client.terminate();
+      return;
+    }
+    
+    client.ping();
+  });
+  
+  dead.forEach(client => wsClients.delete(client));
+}
+
+// Run the heartbeat sweep every WS_HEARTBEAT_INTERVAL
+export function startClientSweep(): void {
+  lastSweepAt = Date.now();
+  sweepTimer = setInterval(sweepClients, config.WS_HEARTBEAT_INTERVAL);
+}
+
+// True while the heartbeat sweep is scheduled and has run within the last
+// two intervals; a stalled event loop or stopped sweep fails this
+export function isSweepAlive(): boolean {
+  return sweepTimer !== undefined && Date.now() - lastSweepAt <= config.WS_HEARTBEAT_INTERVAL * 2;
+}
+
+export const websocketHandlers = {
+  // Bun closes the connection with 1009 (message too big) past this size
+  maxPayloadLength: config.WS_MAX_MESSAGE_BYTES,
+  
+  open(ws: ServerWebSocket<ClientData>) {
+    logger.info('WebSocket client connected');
+    wsClients.add(ws);
+    
+    // Tell the client its id so it can resume after a reconnect, then send
+    // recent events
+    sendTo(ws, 'connected', { client_id: ws.data.id });
+    sendTo(ws, 'initial', getRecentEvents(50).map(toBroadcastEvent));
+  },
+  
+  message(ws: ServerWebSocket<ClientData>, message: string | Buffer) {
+    logger.debug('Received message:', message);
+    handleClientMessage(ws, message);
+  },
+  
+  pong(ws: ServerWebSocket<ClientData>) {
+    ws.data.lastPongAt = Date.now();
+  },
+  
+  close(ws: ServerWebSocket<ClientData>) {
+    logger.info('WebSocket client disconnected');
+    cancelReplay(ws);
+    wsClients.delete(ws);
+    detachClient(ws);
+  }
+};
@@ -0,0 +1,134 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getAuthor, upsertAuthor } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeTheme, resetDatabase, setConfig, signJwt } from './helpers';
+import { request, requestJson } from './server';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(resetDatabase);
+
+async function createTheme(overrides: Record<string, unknown>): Promise<any> {
+  const response = await requestJson('/api/themes', 'POST', makeTheme(overrides));
+  expect(response.status).toBe(201);
+  return (await response.json() as any).data;
+}
+
+function bearer(sub: string): Record<string, string> {
+  return { Authorization: `Bearer ${signJwt({ sub, exp: Math.floor(Date.now() / 1000) + 60 }, SECRET)}` };
+}
+
+test('upsertAuthor creates an author and later renames it', async () => {
+  expect(getAuthor('carol')).toBeNull();
+  
+  const created = await upsertAuthor('carol', 'Carol');
+  expect(created).toMatchObject({ id: 'carol', name: 'Carol' });
+  
+  const renamed = await upsertAuthor('carol', 'Caroline');
+  expect(renamed.name).toBe('Caroline');
+  expect(renamed.createdAt).toBe(created.createdAt);
+});
+
+test('a theme with an author name creates the author', async () => {
+  const theme = await createTheme({ name: 'authors-first', authorId: 'alice', authorName: 'Alice' });
+  
+  expect(theme.authorName).toBe('Alice');
+  expect(getAuthor('alice')!.name).toBe('Alice');
+});
+
+test('a theme body never renames an existing author', async () => {
+  await upsertAuthor('alice', 'Alice');
+  
+  const theme = await createTheme({ name: 'authors-impostor', authorId: 'alice', authorName: 'Mallory' });
+  
+  expect(theme.authorName).toBe('Alice');
+  expect(getAuthor('alice')!.name).toBe('Alice');
+});
+
+test('a theme body names an author who has no name yet', async () => {
+  await createTheme({ name: 'authors-unnamed', authorId: 'dave' });
+  expect(getAuthor('dave')!.name).toBeUndefined();
+  
+  await createTheme({ name: 'authors-named', authorId: 'dave', authorName: 'Dave' });
+  
+  expect(getAuthor('dave')!.name).toBe('Dave');
+});
+
+test('renaming an author shows on every one of their themes', async () => {
+  const first = await createTheme({ name: 'authors-a', authorId: 'alice', authorName: 'Alice' });
+  const second = await createTheme({ name: 'authors-b', authorId: 'alice' });
+  
+  setConfig({ API_KEY: ADMIN_KEY });
+  const response = await requestJson('/api/authors/alice', 'PUT', { name: 'Alice Liddell' }, adminHeaders);
+  expect(response.status).toBe(200);
+  
+  for (const theme of [first, second]) {
+    const stored = await (await request(`/api/themes/${theme.id}`)).json() as any;
+    expect(stored.data.authorName).toBe('Alice Liddell');
+  }
+});
+
+test('an author listing has the profile and their public themes', async () => {
+  await createTheme({ name: 'authors-old', authorId: 'alice', authorName: 'Alice' });
+  await createTheme({ name: 'authors-new', authorId: 'alice' });
+  await createTheme({ name: 'authors-private', authorId: 'alice', isPublic: false });
+  await createTheme({ name: 'authors-other', authorId: 'bob', authorName: 'Bob' });
+  
+  const response = await request('/api/authors/alice/themes');
+  const { data } = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(data.author).toMatchObject({ id: 'alice', name: 'Alice' });
+  expect(data.themes.map((theme: any) => theme.name).sort()).toEqual(['authors-new', 'authors-old']);
+});
+
+test('the author sees their own private themes in the listing', async () => {
+  await createTheme({ name: 'authors-mine', authorId: 'alice', authorName: 'Alice', isPublic: false });
+  setConfig({ JWT_SECRET: SECRET });
+  
+  const own = await (await request('/api/authors/alice/themes', { headers: bearer('alice') })).json() as any;
+  const other = await (await request('/api/authors/alice/themes', { headers: bearer('bob') })).json() as any;
+  
+  expect(own.data.themes.map((theme: any) => theme.name)).toEqual(['authors-mine']);
+  expect(other.data.themes).toEqual([]);
+});
+
+test('an unknown author is a 404', async () => {
+  const response = await request('/api/authors/nobody/themes');
+  
+  expect(response.status).toBe(404);
+  expect((await response.json() as any).error.code).toBe('AUTHOR_NOT_FOUND');
+});
+
+test('renames need the author or the admin key', async () => {
+  await upsertAuthor('alice', 'Alice');
+  
+  // Closed while no auth is configured
+  expect((await requestJson('/api/authors/alice', 'PUT', { name: 'Mallory' })).status).toBe(401);
+  
+  setConfig({ API_KEY: ADMIN_KEY });
+  expect((await requestJson('/api/authors/alice', 'PUT', { name: 'Mallory' })).status).toBe(401);
+  expect((await requestJson('/api/authors/alice', 'PUT', { name: 'Mallory' }, { 'X-API-Key': 'wrong-key' })).status).toBe(401);
+  expect(getAuthor('alice')!.name).toBe('Alice');
+  
+  const admin = await requestJson('/api/authors/alice', 'PUT', { name: 'Alice A.' }, adminHeaders);
+  expect(admin.status).toBe(200);
+  expect(getAuthor('alice')!.name).toBe('Alice A.');
+});
+
+test('renames are validated and, with JWT auth, limited to the author', async () => {
+  await upsertAuthor('alice', 'Alice');
+  setConfig({ JWT_SECRET: SECRET });
+  
+  expect((await requestJson('/api/authors/alice', 'PUT', { name: 'Mallory' })).status).toBe(401);
+  
+  const blank = await requestJson('/api/authors/alice', 'PUT', { name: '  ' }, bearer('alice'));
+  expect(blank.status).toBe(400);
+  
+  const someoneElse = await requestJson('/api/authors/alice', 'PUT', { name: 'Mallory' }, bearer('mallory'));
+  expect(someoneElse.status).toBe(403);
+  expect(getAuthor('alice')!.name).toBe('Alice');
+  
+  const self = await requestJson('/api/authors/alice', 'PUT', { name: 'Alice L.' }, bearer('alice'));
+  expect(self.status).toBe(200);
+  expect(getAuthor('alice')!.name).toBe('Alice L.');
+});
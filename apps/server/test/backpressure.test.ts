@@ -0,0 +1,71 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { wsClients } from '../src/websocket';
+import { resetDatabase, setConfig } from './helpers';
+import { connectClient, postEvent, request, serverSocketOf, sleep } from './server';
+import type { TestClient } from './server';
+
+beforeEach(resetDatabase);
+
+async function metric(name: string): Promise<number> {
+  const text = await (await request('/metrics')).text();
+  const line = text.split('\n').find(line => line.startsWith(`${name} `));
+  return line ? Number(line.slice(name.length + 1)) : 0;
+}
+
+// Make the server see this client's send buffer as permanently full
+async function makeSlow(client: TestClient) {
+  const socket = await serverSocketOf(client);
+  const original = socket.getBufferedAmount.bind(socket);
+  socket.getBufferedAmount = () => Number.MAX_SAFE_INTEGER;
+  return { socket, restore: () => { socket.getBufferedAmount = original; } };
+}
+
+test('a slow client is disconnected without holding up the others', async () => {
+  setConfig({ WS_SLOW_CLIENT_POLICY: 'disconnect' });
+  const disconnects = await metric('ws_slow_client_disconnects_total');
+  const fast = await connectClient();
+  const slow = await connectClient();
+  try {
+    const { socket } = await makeSlow(slow);
+    const closed = new Promise<CloseEvent>(resolve => slow.ws.addEventListener('close', resolve));
+    
+    const event = await postEvent({ session_id: 'backpressure-disconnect' });
+    
+    expect((await fast.next('event')).data.id).toBe(event.id);
+    const close = await closed;
+    expect(close.code).toBe(1013);
+    expect(close.reason).toBe('Client too slow');
+    expect(wsClients.has(socket)).toBe(false);
+    expect(await metric('ws_slow_client_disconnects_total')).toBe(disconnects + 1);
+  } finally {
+    fast.close();
+    slow.close();
+  }
+});
+
+test('with the drop policy a slow client misses frames and sees the gap', async () => {
+  setConfig({ WS_SLOW_CLIENT_POLICY: 'drop' });
+  const dropped = await metric('ws_dropped_messages_total');
+  const client = await connectClient();
+  try {
+    // Seen once first, so the next event brings no filters_updated frame
+    await postEvent({ session_id: 'backpressure-drop' });
+    await client.next('event');
+    const { socket, restore } = await makeSlow(client);
+    await postEvent({ session_id: 'backpressure-drop' });
+    await sleep(50);
+    
+    expect(client.messages.filter(message => message.type === 'event')).toHaveLength(1);
+    expect(wsClients.has(socket)).toBe(true);
+    expect(await metric('ws_dropped_messages_total')).toBe(dropped + 1);
+    
+    // Once it catches up the next frame skips the dropped seq
+    const before = Math.max(...client.messages.map(message => message.seq));
+    restore();
+    await postEvent({ session_id: 'backpressure-drop' });
+    const frame = await client.next('event');
+    expect(frame.seq).toBeGreaterThan(before + 1);
+  } finally {
+    client.close();
+  }
+});
@@ -0,0 +1,42 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { request } from './server';
+
+const LIMIT = 2048;
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ MAX_BODY_BYTES: LIMIT });
+});
+
+// A valid event whose JSON encoding is exactly `size` bytes
+function eventBodyOfSize(size: number): string {
+  const empty = JSON.stringify(makeEvent({ payload: { padding: '' } }));
+  return JSON.stringify(makeEvent({ payload: { padding: 'x'.repeat(size - empty.length) } }));
+}
+
+function postBody(body: string): Promise<Response> {
+  return request('/events', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body });
+}
+
+test('a body at the limit is accepted', async () => {
+  const body = eventBodyOfSize(LIMIT);
+  expect(body.length).toBe(LIMIT);
+  
+  const response = await postBody(body);
+  
+  expect(response.status).toBe(200);
+  expect((await response.json()).payload.padding).toHaveLength(LIMIT - JSON.stringify(makeEvent({ payload: { padding: '' } })).length);
+});
+
+test('a body one byte over the limit is rejected with 413', async () => {
+  const response = await postBody(eventBodyOfSize(LIMIT + 1));
+  const body = await response.json();
+  
+  expect(response.status).toBe(413);
+  expect(body.error.code).toBe('PAYLOAD_TOO_LARGE');
+  expect(body.error.message).toContain(String(LIMIT));
+  
+  const count = await (await request('/events/count')).json();
+  expect(count.count).toBe(0);
+});
@@ -0,0 +1,87 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { toBroadcastEvent } from '../src/event';
+import { makeEvent, resetDatabase, seedEvents, setConfig } from './helpers';
+import { connectClient, connectSse, postEvent, request } from './server';
+
+beforeEach(resetDatabase);
+
+const bigPayload = { tool_name: 'Write', tool_input: { content: 'x'.repeat(500) } };
+
+test('large payloads are replaced by a preview in live frames only', async () => {
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 64 });
+  const client = await connectClient();
+  try {
+    const saved = await postEvent({ session_id: 'truncate-1', payload: bigPayload });
+    const frame = await client.next('event');
+    
+    expect(frame.data.payload._truncated).toBe(true);
+    expect(frame.data.payload.event_id).toBe(saved.id);
+    expect(Buffer.byteLength(frame.data.payload.preview)).toBeLessThanOrEqual(64);
+    expect(JSON.stringify(bigPayload).startsWith(frame.data.payload.preview)).toBe(true);
+    
+    // Stored and fetched events keep the full payload
+    const stored = await (await request(`/events/${saved.id}`)).json() as any;
+    expect(stored.payload).toEqual(bigPayload);
+  } finally {
+    client.close();
+  }
+});
+
+test('small payloads and a disabled limit pass through unchanged', () => {
+  const small = makeEvent();
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 64 });
+  expect(toBroadcastEvent(small)).toBe(small);
+  
+  const big = makeEvent({ payload: bigPayload });
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 0 });
+  expect(toBroadcastEvent(big)).toBe(big);
+});
+
+test('the preview is cut between characters, never inside one', () => {
+  const event = makeEvent({ payload: { text: '🚀🚀🚀🚀' } });
+  // {"text":" is 9 bytes and each rocket 4 more
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 12 });
+  expect(toBroadcastEvent(event).payload.preview).toBe('{"text":"');
+  
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 13 });
+  expect(toBroadcastEvent(event).payload.preview).toBe('{"text":"🚀');
+});
+
+test('the initial WebSocket batch is truncated too', async () => {
+  const [saved] = await seedEvents([makeEvent({ session_id: 'truncate-initial', payload: bigPayload })]);
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 64 });
+  const client = await connectClient();
+  try {
+    const initial = await client.next('initial');
+    
+    expect(initial.data[0].id).toBe(saved!.id);
+    expect(initial.data[0].payload._truncated).toBe(true);
+  } finally {
+    client.close();
+  }
+});
+
+test('SSE initial and resumed frames are truncated too', async () => {
+  const [first, second] = await seedEvents([
+    makeEvent({ session_id: 'truncate-sse', payload: bigPayload }),
+    makeEvent({ session_id: 'truncate-sse', payload: bigPayload })
+  ]);
+  setConfig({ WS_PAYLOAD_PREVIEW_BYTES: 64 });
+  
+  const fresh = await connectSse();
+  try {
+    const initial = await fresh.next('initial');
+    expect(initial.data.every((event: any) => event.payload._truncated === true)).toBe(true);
+  } finally {
+    fresh.close();
+  }
+  
+  const resumed = await connectSse({ 'Last-Event-ID': String(first!.id) });
+  try {
+    const frame = await resumed.next('event');
+    expect(frame.data.id).toBe(second!.id);
+    expect(frame.data.payload._truncated).toBe(true);
+  } finally {
+    resumed.close();
+  }
+});
@@ -0,0 +1,120 @@
+import { afterEach, beforeEach, expect, spyOn, test } from 'bun:test';
+import { Database } from 'bun:sqlite';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { closeDatabase, countEvents, initDatabase, insertEvent, withBusyRetry } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { requestJson } from './server';
+
+let dir: string;
+
+beforeEach(() => {
+  dir = mkdtempSync(join(tmpdir(), 'busy-retry-'));
+  // Fail fast inside SQLite so every wait is one of ours
+  setConfig({ DB_BUSY_TIMEOUT_MS: 0, DB_BUSY_RETRIES: 5, DB_BUSY_BACKOFF_MS: 10 });
+});
+
+afterEach(() => {
+  resetDatabase();
+  rmSync(dir, { recursive: true, force: true });
+});
+
+function busyError(): Error {
+  return Object.assign(new Error('database is locked'), { code: 'SQLITE_BUSY' });
+}
+
+test('an insert waits out a lock held by another connection', async () => {
+  const path = join(dir, 'events.db');
+  closeDatabase();
+  initDatabase(path);
+  
+  // A second connection holds the write lock for a while
+  const other = new Database(path);
+  other.exec('BEGIN IMMEDIATE');
+  setTimeout(() => other.exec('COMMIT'), 25);
+  
+  const { event, created } = await insertEvent(makeEvent({ session_id: 'busy-locked' }));
+  other.close();
+  
+  expect(created).toBe(true);
+  expect(event.session_id).toBe('busy-locked');
+  expect(countEvents()).toBe(1);
+});
+
+test('busy failures are retried until the write succeeds', async () => {
+  let attempts = 0;
+  
+  const result = await withBusyRetry(() => {
+    if (++attempts < 3) throw busyError();
+    return 'written';
+  });
+  
+  expect(result).toBe('written');
+  expect(attempts).toBe(3);
+});
+
+test('retries stop after DB_BUSY_RETRIES', async () => {
+  setConfig({ DB_BUSY_RETRIES: 2 });
+  let attempts = 0;
+  
+  const write = withBusyRetry(() => {
+    attempts++;
+    throw busyError();
+  });
+  
+  await expect(write).rejects.toThrow('database is locked');
+  expect(attempts).toBe(3);
+});
+
+test('other errors are not retried', async () => {
+  let attempts = 0;
+  
+  const write = withBusyRetry(() => {
+    attempts++;
+    throw new Error('UNIQUE constraint failed: themes.id');
+  });
+  
+  await expect(write).rejects.toThrow('UNIQUE constraint failed');
+  expect(attempts).toBe(1);
+});
+
+test('the backoff doubles between attempts', async () => {
+  setConfig({ DB_BUSY_RETRIES: 3, DB_BUSY_BACKOFF_MS: 20 });
+  const times: number[] = [];
+  
+  await withBusyRetry(() => {
+    times.push(performance.now());
+    if (times.length < 4) throw busyError();
+  });
+  
+  const gaps = times.slice(1).map((time, i) => time - times[i]!);
+  expect(gaps[0]).toBeGreaterThanOrEqual(18);
+  expect(gaps[1]).toBeGreaterThanOrEqual(38);
+  expect(gaps[2]).toBeGreaterThanOrEqual(78);
+});
+
+test('an event that cannot be stored gets 503 with Retry-After', async () => {
+  const path = join(dir, 'events.db');
+  closeDatabase();
+  initDatabase(path);
+  setConfig({ DB_BUSY_RETRIES: 1 });
+  const errorSpy = spyOn(console, 'error').mockImplementation(() => {});
+  
+  // Held past every retry
+  const other = new Database(path);
+  other.exec('BEGIN IMMEDIATE');
+  try {
+    const response = await requestJson('/events', 'POST', makeEvent({ session_id: 'busy-unavailable' }));
+    
+    expect(response.status).toBe(503);
+    expect(response.headers.get('retry-after')).toBe('1');
+    expect((await response.json() as any).error.code).toBe('DATABASE_UNAVAILABLE');
+  } finally {
+    other.exec('ROLLBACK');
+    other.close();
+    errorSpy.mockRestore();
+  }
+  
+  expect(countEvents()).toBe(0);
+});
@@ -0,0 +1,49 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents, setConfig } from './helpers';
+import { request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  setConfig({ COMPRESSION_MIN_BYTES: 1024 });
+  await seedEvents(Array.from({ length: 50 }, (_, n) => makeEvent({ payload: { n, text: 'compressible '.repeat(10) } })));
+});
+
+// Bun's fetch would otherwise decompress transparently
+function fetchRaw(path: string, acceptEncoding: string): Promise<Response> {
+  return request(path, { headers: { 'Accept-Encoding': acceptEncoding }, decompress: false } as RequestInit);
+}
+
+test('a large listing is gzipped when the client accepts it', async () => {
+  const response = await fetchRaw('/events/recent?limit=50', 'gzip, deflate');
+  const body = new Uint8Array(await response.arrayBuffer());
+  
+  expect(response.headers.get('content-encoding')).toBe('gzip');
+  expect(response.headers.get('vary')).toContain('Accept-Encoding');
+  const events = JSON.parse(new TextDecoder().decode(Bun.gunzipSync(body)));
+  expect(events).toHaveLength(50);
+  expect(Number(response.headers.get('content-length'))).toBe(body.byteLength);
+});
+
+test('the listing is plain without gzip in Accept-Encoding', async () => {
+  for (const acceptEncoding of ['identity', 'gzip;q=0']) {
+    const response = await fetchRaw('/events/recent?limit=50', acceptEncoding);
+    
+    expect(response.headers.get('content-encoding')).toBeNull();
+    expect(await response.json()).toHaveLength(50);
+  }
+});
+
+test('small responses are not compressed', async () => {
+  const response = await fetchRaw('/events/count', 'gzip');
+  
+  expect(response.headers.get('content-encoding')).toBeNull();
+  expect(await response.json()).toEqual({ count: 50 });
+});
+
+test('a zero threshold turns compression off', async () => {
+  setConfig({ COMPRESSION_MIN_BYTES: 0 });
+  
+  const response = await fetchRaw('/events/recent?limit=50', 'gzip');
+  
+  expect(response.headers.get('content-encoding')).toBeNull();
+});
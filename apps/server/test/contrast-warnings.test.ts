@@ -0,0 +1,63 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { checkContrast, contrastRatio, generatePalette } from '../src/theme';
+import { makeTheme, resetDatabase } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+const palette = generatePalette('#3366cc')!;
+
+test('contrast ratios follow WCAG', () => {
+  expect(contrastRatio('#000000', '#ffffff')).toBeCloseTo(21, 5);
+  expect(contrastRatio('#ffffff', '#ffffff')).toBeCloseTo(1, 5);
+  // Order does not matter
+  expect(contrastRatio('#777777', '#ffffff')).toBeCloseTo(contrastRatio('#ffffff', '#777777')!, 10);
+  expect(contrastRatio('nope', '#ffffff')).toBeNull();
+});
+
+test('a readable palette has no warnings', () => {
+  expect(checkContrast(palette)).toEqual([]);
+});
+
+test('each pair below its minimum gets a warning', () => {
+  const warnings = checkContrast({ ...palette, bgPrimary: '#ffffff', textPrimary: '#eeeeee', textTertiary: '#dddddd' });
+  
+  expect(warnings.map(warning => warning.field)).toContain('colors.textPrimary');
+  expect(warnings.map(warning => warning.field)).toContain('colors.textTertiary');
+  expect(warnings.every(warning => warning.code === 'LOW_CONTRAST')).toBe(true);
+  expect(warnings[0]!.message).toMatch(/is \d+\.\d{2}:1; at least 4\.5:1 is recommended/);
+});
+
+test('a low-contrast theme is still created, with warnings', async () => {
+  const response = await requestJson('/api/themes', 'POST', makeTheme({
+    name: 'washed-out',
+    colors: { ...palette, bgPrimary: '#ffffff', textPrimary: '#f0f0f0' }
+  }));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(201);
+  expect(body.success).toBe(true);
+  expect(body.validationWarnings).toContainEqual(expect.objectContaining({ field: 'colors.textPrimary', code: 'LOW_CONTRAST' }));
+  expect((await request(`/api/themes/${body.data.id}`)).status).toBe(200);
+});
+
+test('a readable theme is created without a warnings field', async () => {
+  const response = await requestJson('/api/themes', 'POST', makeTheme({ name: 'readable' }));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(201);
+  expect(body).not.toHaveProperty('validationWarnings');
+});
+
+test('updates report warnings for the saved colors', async () => {
+  const created = (await (await requestJson('/api/themes', 'POST', makeTheme({ name: 'fading' }))).json() as any).data;
+  
+  const response = await requestJson(`/api/themes/${created.id}`, 'PUT', {
+    colors: { ...palette, bgPrimary: '#000000', textSecondary: '#111111' },
+    updatedAt: created.updatedAt
+  });
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(body.validationWarnings.map((warning: any) => warning.field)).toContain('colors.textSecondary');
+});
@@ -0,0 +1,44 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase, setConfig } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+function preflight(path: string = '/events'): Promise<Response> {
+  return request(path, {
+    method: 'OPTIONS',
+    headers: { 'Origin': 'https://dashboard.example', 'Access-Control-Request-Method': 'POST' }
+  });
+}
+
+test('preflights use the default method and header lists without a max age', async () => {
+  const response = await preflight();
+  
+  expect(response.headers.get('access-control-allow-methods')).toBe('GET, POST, PUT, PATCH, DELETE, OPTIONS');
+  expect(response.headers.get('access-control-allow-headers')).toBe('Content-Type, Authorization, X-Request-ID, If-None-Match, If-Modified-Since');
+  expect(response.headers.get('access-control-max-age')).toBeNull();
+});
+
+test('configured methods, headers and max age appear in the preflight response', async () => {
+  setConfig({
+    CORS_ALLOW_METHODS: ['GET', 'POST'],
+    CORS_ALLOW_HEADERS: ['Content-Type', 'X-Tenant'],
+    CORS_MAX_AGE_SECONDS: 600
+  });
+  
+  const response = await preflight('/api/themes');
+  
+  expect(response.status).toBe(200);
+  expect(response.headers.get('access-control-allow-methods')).toBe('GET, POST');
+  expect(response.headers.get('access-control-allow-headers')).toBe('Content-Type, X-Tenant');
+  expect(response.headers.get('access-control-max-age')).toBe('600');
+});
+
+test('max age is only sent on preflights', async () => {
+  setConfig({ CORS_MAX_AGE_SECONDS: 600 });
+  
+  const response = await request('/events/recent', { headers: { 'Origin': 'https://dashboard.example' } });
+  
+  expect(response.status).toBe(200);
+  expect(response.headers.get('access-control-max-age')).toBeNull();
+});
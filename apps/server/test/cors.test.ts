@@ -0,0 +1,75 @@
+import { beforeEach, expect, spyOn, test } from 'bun:test';
+import { corsHeaders } from '../src/cors';
+import { closeDatabase } from '../src/db';
+import { resetDatabase, setConfig } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+function requestFrom(origin: string, method: string = 'GET'): Request {
+  return new Request('http://localhost/events/recent', { method, headers: { Origin: origin } });
+}
+
+test('a wildcard policy answers "*" and never allows credentials', () => {
+  setConfig({ CORS_ORIGINS: ['*'] });
+  
+  const headers = corsHeaders(requestFrom('https://dashboard.example'));
+  
+  expect(headers['Access-Control-Allow-Origin']).toBe('*');
+  expect(headers['Access-Control-Allow-Credentials']).toBeUndefined();
+});
+
+test('a listed origin is echoed back with credentials allowed', () => {
+  setConfig({ CORS_ORIGINS: ['https://dashboard.example', 'https://other.example'] });
+  
+  const headers = corsHeaders(requestFrom('https://dashboard.example'));
+  
+  expect(headers['Access-Control-Allow-Origin']).toBe('https://dashboard.example');
+  expect(headers['Access-Control-Allow-Credentials']).toBe('true');
+  expect(headers['Vary']).toBe('Origin');
+});
+
+test('an unlisted origin gets neither Allow-Origin nor credentials', () => {
+  setConfig({ CORS_ORIGINS: ['https://dashboard.example'] });
+  
+  const headers = corsHeaders(requestFrom('https://evil.example'));
+  
+  expect(headers['Access-Control-Allow-Origin']).toBeUndefined();
+  expect(headers['Access-Control-Allow-Credentials']).toBeUndefined();
+  expect(headers['Vary']).toBe('Origin');
+});
+
+test('preflight responses carry a spec-compliant combination in both modes', async () => {
+  for (const [origins, expectedOrigin, expectedCredentials] of [
+    [['*'], '*', null],
+    [['https://dashboard.example'], 'https://dashboard.example', 'true']
+  ] as const) {
+    setConfig({ CORS_ORIGINS: [...origins] });
+    
+    const response = await request('/events', { method: 'OPTIONS', headers: { Origin: 'https://dashboard.example' } });
+    const allowOrigin = response.headers.get('access-control-allow-origin');
+    const allowCredentials = response.headers.get('access-control-allow-credentials');
+    
+    expect(allowOrigin).toBe(expectedOrigin);
+    expect(allowCredentials).toBe(expectedCredentials);
+    // Never the invalid "*" with credentials pairing
+    expect(allowOrigin === '*' && allowCredentials === 'true').toBe(false);
+  }
+});
+
+test('unhandled errors still carry the CORS headers', async () => {
+  setConfig({ CORS_ORIGINS: ['https://dashboard.example'] });
+  const errorSpy = spyOn(console, 'error').mockImplementation(() => {});
+  // Every query now throws, and the route does not catch it
+  closeDatabase();
+  try {
+    const response = await request('/events/recent', { headers: { Origin: 'https://dashboard.example' } });
+    
+    expect(response.status).toBe(500);
+    expect(response.headers.get('access-control-allow-origin')).toBe('https://dashboard.example');
+    expect(response.headers.get('access-control-allow-credentials')).toBe('true');
+  } finally {
+    errorSpy.mockRestore();
+    resetDatabase();
+  }
+});
@@ -0,0 +1,35 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { HOOK_EVENT_TYPES } from '../src/types';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+test('emitted types are counted and the rest of the allow-list is missing', async () => {
+  await seedEvents([
+    makeEvent({ source_app: 'partial-app', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'partial-app', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'partial-app', hook_event_type: 'PostToolUse' }),
+    // Another app's types do not count towards this one
+    makeEvent({ source_app: 'other-app', hook_event_type: 'Stop' })
+  ]);
+  
+  const response = await request('/apps/partial-app/coverage');
+  const coverage = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(coverage.source_app).toBe('partial-app');
+  expect(coverage.emitted).toContainEqual({ hook_event_type: 'PreToolUse', count: 2 });
+  expect(coverage.emitted).toContainEqual({ hook_event_type: 'PostToolUse', count: 1 });
+  expect(coverage.emitted).toHaveLength(2);
+  expect(coverage.missing).toEqual(HOOK_EVENT_TYPES.filter(type => type !== 'PreToolUse' && type !== 'PostToolUse'));
+  expect(coverage.missing).toContain('Stop');
+});
+
+test('an app with no events is missing every known type', async () => {
+  const coverage = await (await request('/apps/silent%20app/coverage')).json() as any;
+  
+  expect(coverage.source_app).toBe('silent app');
+  expect(coverage.emitted).toEqual([]);
+  expect(coverage.missing).toEqual([...HOOK_EVENT_TYPES]);
+});
@@ -0,0 +1,49 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+async function exportCsv(query: string = ''): Promise<string[]> {
+  const response = await request(`/events/export.csv${query}`);
+  expect(response.status).toBe(200);
+  expect(response.headers.get('content-type')).toBe('text/csv; charset=utf-8');
+  expect(response.headers.get('content-disposition')).toBe('attachment; filename="events.csv"');
+  const text = await response.text();
+  expect(text.endsWith('\r\n')).toBe(true);
+  return text.slice(0, -2).split('\r\n');
+}
+
+test('the header row names the columns', async () => {
+  const [header, ...rows] = await exportCsv();
+  
+  expect(header).toBe('id,source_app,session_id,hook_event_type,summary,timestamp,payload');
+  expect(rows).toEqual([]);
+});
+
+test('fields with commas and quotes are quoted and the payload is a JSON column', async () => {
+  const [event] = await seedEvents([makeEvent({
+    source_app: 'plain-app',
+    session_id: 'csv-1',
+    summary: 'Ran "ls", then stopped',
+    timestamp: 1700000000000,
+    payload: { command: 'echo "hi"' }
+  })]);
+  
+  const [, row] = await exportCsv();
+  
+  expect(row).toBe(`${event!.id},plain-app,csv-1,PreToolUse,"Ran ""ls"", then stopped",1700000000000,"{""command"":""echo \\""hi\\""""}"`);
+});
+
+test('filter params narrow the export', async () => {
+  await seedEvents([
+    makeEvent({ source_app: 'keep-app' }),
+    makeEvent({ source_app: 'drop-app' }),
+    makeEvent({ source_app: 'keep-app', hook_event_type: 'Stop' })
+  ]);
+  
+  const [, ...rows] = await exportCsv('?source_app=keep-app&hook_event_type=Stop');
+  
+  expect(rows).toHaveLength(1);
+  expect(rows[0]).toContain(',keep-app,session-1,Stop,');
+});
@@ -0,0 +1,54 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { postEvent, request } from './server';
+
+beforeEach(resetDatabase);
+
+async function seed(count: number): Promise<number[]> {
+  const results = await insertEvents(Array.from({ length: count }, (_, i) => makeEvent({ session_id: `cursor-${i}` })));
+  return results.map(result => result.event.id!);
+}
+
+async function page(cursor: number | null | undefined, limit: number): Promise<{ data: { id: number }[]; nextCursor: number | null }> {
+  const response = await request(`/events/recent?before_id=${cursor ?? ''}&limit=${limit}`);
+  expect(response.status).toBe(200);
+  return response.json() as any;
+}
+
+test('pages walk every event newest first without gaps or repeats', async () => {
+  const ids = await seed(7);
+  
+  const seen: number[] = [];
+  let cursor: number | null | undefined;
+  do {
+    const result = await page(cursor, 3);
+    seen.push(...result.data.map(event => event.id));
+    cursor = result.nextCursor;
+  } while (cursor !== null);
+  
+  expect(seen).toEqual([...ids].reverse());
+});
+
+test('events inserted between pages do not shift the next page', async () => {
+  const ids = await seed(6);
+  const first = await page(undefined, 3);
+  
+  await postEvent({ session_id: 'cursor-late' });
+  const second = await page(first.nextCursor, 3);
+  
+  expect(second.data.map(event => event.id)).toEqual(ids.slice(0, 3).reverse());
+});
+
+test('the last page has no next cursor', async () => {
+  await seed(2);
+  
+  expect((await page(undefined, 5)).nextCursor).toBeNull();
+});
+
+test('a non-numeric cursor is rejected', async () => {
+  const response = await request('/events/recent?before_id=abc');
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+});
@@ -0,0 +1,34 @@
+import { afterEach, expect, test } from 'bun:test';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { closeDatabase, initDatabase, readPragma } from '../src/db';
+import { resetDatabase, setConfig } from './helpers';
+
+let dir: string | undefined;
+
+afterEach(() => {
+  resetDatabase();
+  if (dir) rmSync(dir, { recursive: true, force: true });
+  dir = undefined;
+});
+
+test('a file database uses WAL, NORMAL sync and the configured busy timeout', () => {
+  setConfig({ DB_BUSY_TIMEOUT_MS: 1234 });
+  dir = mkdtempSync(join(tmpdir(), 'observability-db-'));
+  closeDatabase();
+  initDatabase(join(dir, 'events.db'));
+  
+  expect(readPragma('journal_mode')).toBe('wal');
+  // 1 = NORMAL
+  expect(readPragma('synchronous')).toBe(1);
+  expect(readPragma('busy_timeout')).toBe(1234);
+});
+
+test('an in-memory database keeps its own journal but still gets the busy timeout', () => {
+  setConfig({ DB_BUSY_TIMEOUT_MS: 250 });
+  resetDatabase();
+  
+  expect(readPragma('journal_mode')).toBe('memory');
+  expect(readPragma('busy_timeout')).toBe(250);
+});
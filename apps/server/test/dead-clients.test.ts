@@ -0,0 +1,37 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { broadcast, sweepClients, wsClients } from '../src/websocket';
+import { resetDatabase } from './helpers';
+import { connectClient, serverSocketOf } from './server';
+
+beforeEach(resetDatabase);
+
+test('a client whose send throws is removed and the broadcast reaches the rest', async () => {
+  const broken = await connectClient();
+  const healthy = await connectClient();
+  try {
+    const socket = await serverSocketOf(broken);
+    socket.send = () => {
+      throw new Error('connection reset');
+    };
+    
+    expect(() => broadcast({ type: 'dead-client-check', data: { n: 1 } })).not.toThrow();
+    
+    expect(wsClients.has(socket)).toBe(false);
+    expect((await healthy.next('dead-client-check')).data).toEqual({ n: 1 });
+  } finally {
+    broken.close();
+    healthy.close();
+  }
+});
+
+test('the sweep drops sockets that are no longer open', async () => {
+  const client = await connectClient();
+  const socket = await serverSocketOf(client);
+  // A half-closed socket the close handler has not seen yet
+  Object.defineProperty(socket, 'readyState', { value: WebSocket.CLOSING });
+  
+  sweepClients();
+  
+  expect(wsClients.has(socket)).toBe(false);
+  client.close();
+});
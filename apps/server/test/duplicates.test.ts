@@ -0,0 +1,68 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+const now = Date.now();
+const loop = { tool_name: 'Bash', tool_input: { command: 'npm test' } };
+
+beforeEach(async () => {
+  resetDatabase();
+  await seedEvents([
+    // A runaway loop: the same call four times
+    ...[4, 3, 2, 1].map(minutes => makeEvent({ source_app: 'looper', payload: loop, timestamp: now - minutes * 60_000 })),
+    // The same payload from another app is its own group
+    ...[2, 1].map(minutes => makeEvent({ source_app: 'other', payload: loop, timestamp: now - minutes * 60_000 })),
+    // Same app and payload, different type
+    makeEvent({ source_app: 'looper', hook_event_type: 'PostToolUse', payload: loop, timestamp: now - 30_000 }),
+    // Distinct payloads
+    makeEvent({ source_app: 'looper', payload: { tool_name: 'Read' }, timestamp: now - 20_000 }),
+    makeEvent({ source_app: 'looper', payload: { tool_name: 'Write' }, timestamp: now - 10_000 }),
+    // Outside the default one-hour window
+    makeEvent({ source_app: 'old', payload: loop, timestamp: now - 2 * 3600_000 }),
+    makeEvent({ source_app: 'old', payload: loop, timestamp: now - 2 * 3600_000 + 1 })
+  ]);
+});
+
+async function duplicates(query: string = ''): Promise<any[]> {
+  const response = await request(`/events/duplicates${query}`);
+  expect(response.status).toBe(200);
+  return response.json() as Promise<any[]>;
+}
+
+test('identical events are grouped with counts, most repeated first', async () => {
+  const groups = await duplicates();
+  
+  expect(groups.map(group => [group.source_app, group.hook_event_type, group.count])).toEqual([
+    ['looper', 'PreToolUse', 4],
+    ['other', 'PreToolUse', 2]
+  ]);
+  expect(groups[0].first_seen).toBe(now - 4 * 60_000);
+  expect(groups[0].last_seen).toBe(now - 60_000);
+  // Both groups share the payload, so they share its hash
+  expect(groups[0].payload_hash).toBe(groups[1].payload_hash);
+});
+
+test('the sample event is the first of its group', async () => {
+  const [group] = await duplicates();
+  const sample = await (await request(`/events/${group.sample_event_id}`)).json() as any;
+  
+  expect(sample.source_app).toBe('looper');
+  expect(sample.timestamp).toBe(now - 4 * 60_000);
+  expect(sample.payload).toEqual(loop);
+});
+
+test('min_count raises the bar for a group', async () => {
+  expect((await duplicates('?min_count=3')).map(group => group.source_app)).toEqual(['looper']);
+});
+
+test('since widens the window to older events', async () => {
+  const groups = await duplicates(`?since=${now - 3 * 3600_000}`);
+  
+  expect(groups.map(group => group.source_app)).toContain('old');
+});
+
+test('invalid parameters are rejected', async () => {
+  for (const query of ['?min_count=1', '?since=soon', '?until=later']) {
+    expect((await request(`/events/duplicates${query}`)).status).toBe(400);
+  }
+});
@@ -0,0 +1,82 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { respondApiResult, respondError } from '../src/errors';
+import { makeEvent, makeTheme, resetDatabase } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+// Every error body is exactly {error: {code, message[, details]}}
+async function expectEnvelope(response: Response, status: number, code: string): Promise<any> {
+  expect(response.status).toBe(status);
+  expect(response.headers.get('content-type')).toStartWith('application/json');
+  const body = await response.json() as any;
+  expect(Object.keys(body)).toEqual(['error']);
+  expect(body.error.code).toBe(code);
+  expect(typeof body.error.message).toBe('string');
+  expect(body.error.message.length).toBeGreaterThan(0);
+  for (const key of Object.keys(body.error)) {
+    expect(['code', 'message', 'details']).toContain(key);
+  }
+  return body.error;
+}
+
+test('an invalid event is EVENT_INVALID with the validation errors', async () => {
+  const error = await expectEnvelope(await requestJson('/events', 'POST', { source_app: 'x' }), 422, 'EVENT_INVALID');
+  
+  expect(error.details.validationErrors.map((e: any) => e.field)).toContain('session_id');
+});
+
+test('a malformed body is INVALID_BODY', async () => {
+  const response = await request('/events', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: '{not json' });
+  
+  await expectEnvelope(response, 400, 'INVALID_BODY');
+});
+
+test('missing resources have their own codes', async () => {
+  await expectEnvelope(await request('/events/999999'), 404, 'EVENT_NOT_FOUND');
+  await expectEnvelope(await request('/api/themes/no-such-theme'), 404, 'THEME_NOT_FOUND');
+  await expectEnvelope(await request('/no/such/route'), 404, 'NOT_FOUND');
+});
+
+test('theme failures map to codes', async () => {
+  await expectEnvelope(await requestJson('/api/themes', 'POST', makeTheme({ colors: {} })), 400, 'THEME_INVALID');
+  
+  await requestJson('/api/themes', 'POST', makeTheme({ name: 'taken' }));
+  await expectEnvelope(await requestJson('/api/themes', 'POST', makeTheme({ name: 'taken' })), 409, 'THEME_DUPLICATE');
+});
+
+test('bad query parameters are INVALID_PARAMETER', async () => {
+  await expectEnvelope(await request('/events/since?id=abc'), 400, 'INVALID_PARAMETER');
+  await expectEnvelope(await request('/events/stats?since=later'), 400, 'INVALID_PARAMETER');
+});
+
+test('admin routes without a key are UNAUTHORIZED', async () => {
+  const error = await expectEnvelope(await request('/admin/ws/clients'), 401, 'UNAUTHORIZED');
+  
+  expect(error.message).toContain('API_KEY');
+});
+
+test('details are omitted when there are none', async () => {
+  const body = await respondError({}, 404, 'NOT_FOUND', 'Not found').json() as any;
+  
+  expect(body).toEqual({ error: { code: 'NOT_FOUND', message: 'Not found' } });
+});
+
+test('service results map to status by their code, whatever the message', async () => {
+  const cases = [
+    [{ success: false, code: 'THEME_NOT_FOUND', error: 'No such theme' }, 404, 'THEME_NOT_FOUND'],
+    [{ success: false, code: 'AUTHOR_NOT_FOUND', error: 'Author not found' }, 404, 'AUTHOR_NOT_FOUND'],
+    [{ success: false, code: 'FORBIDDEN', error: 'Unauthorized - you can only update your own themes' }, 403, 'FORBIDDEN'],
+    [{ success: false, code: 'THEME_CONFLICT', error: 'Theme changed underneath you' }, 409, 'THEME_CONFLICT'],
+    [{ success: false, code: 'THEME_INVALID', error: 'Conflict in the palette' }, 400, 'THEME_INVALID'],
+    [{ success: false, code: 'INTERNAL', error: 'Internal server error' }, 500, 'INTERNAL'],
+    [{ success: false, code: 'THEME_DUPLICATE', error: 'Validation failed', validationErrors: [{ field: 'name', message: 'taken', code: 'DUPLICATE' }] }, 409, 'THEME_DUPLICATE'],
+    // A failure without a code is a bug, not the client's fault
+    [{ success: false, error: 'Theme not found' }, 500, 'INTERNAL']
+  ] as const;
+  
+  for (const [result, status, code] of cases) {
+    await expectEnvelope(respondApiResult({}, result as any), status, code);
+  }
+  expect(respondApiResult({}, { success: true, data: makeEvent() }, 201).status).toBe(201);
+});
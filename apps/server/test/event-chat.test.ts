@@ -0,0 +1,45 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase } from './helpers';
+import { postEvent, request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+function appendChat(id: number, messages: unknown): Promise<Response> {
+  return requestJson(`/events/${id}/chat`, 'PATCH', { messages });
+}
+
+test('messages appended to an event without chat become its transcript', async () => {
+  const event = await postEvent({ session_id: 'chat-1' });
+  expect(event.chat).toBeFalsy();
+  
+  const response = await appendChat(event.id!, [{ role: 'user', content: 'hi' }]);
+  
+  expect(response.status).toBe(200);
+  expect((await response.json() as any).chat).toEqual([{ role: 'user', content: 'hi' }]);
+});
+
+test('messages are appended after an existing transcript', async () => {
+  const event = await postEvent({ session_id: 'chat-2', chat: [{ role: 'user', content: 'first' }] });
+  
+  await appendChat(event.id!, [{ role: 'assistant', content: 'second' }]);
+  await appendChat(event.id!, [{ role: 'user', content: 'third' }, { role: 'assistant', content: 'fourth' }]);
+  
+  const stored = await (await request(`/events/${event.id}`)).json() as any;
+  expect(stored.chat.map((message: any) => message.content)).toEqual(['first', 'second', 'third', 'fourth']);
+});
+
+test('concurrent appends are not lost', async () => {
+  const event = await postEvent({ session_id: 'chat-3' });
+  
+  await Promise.all(Array.from({ length: 10 }, (_, i) => appendChat(event.id!, [{ n: i }])));
+  
+  const stored = await (await request(`/events/${event.id}`)).json() as any;
+  expect(stored.chat.map((message: any) => message.n).sort((a: number, b: number) => a - b)).toEqual([0, 1, 2, 3, 4, 5, 6, 7, 8, 9]);
+});
+
+test('unknown ids and non-array bodies are rejected', async () => {
+  expect((await appendChat(999999, [{ n: 1 }])).status).toBe(404);
+  
+  const event = await postEvent({ session_id: 'chat-4' });
+  expect((await appendChat(event.id!, { n: 1 })).status).toBe(400);
+});
@@ -0,0 +1,45 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase } from './helpers';
+import { connectClient, postEvent, request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+test('a summary can be attached after creation', async () => {
+  const event = await postEvent({ session_id: 'summary-1' });
+  
+  const response = await requestJson(`/events/${event.id}/summary`, 'PATCH', { summary: 'Listed the directory' });
+  
+  expect(response.status).toBe(200);
+  expect((await response.json() as any).summary).toBe('Listed the directory');
+  const stored = await (await request(`/events/${event.id}`)).json() as any;
+  expect(stored.summary).toBe('Listed the directory');
+});
+
+test('connected dashboards get an event_updated frame', async () => {
+  const event = await postEvent({ session_id: 'summary-2' });
+  const client = await connectClient();
+  try {
+    await requestJson(`/events/${event.id}/summary`, 'PATCH', { summary: 'Updated live' });
+    const update = await client.next('event_updated');
+    
+    expect(update.data.id).toBe(event.id);
+    expect(update.data.summary).toBe('Updated live');
+  } finally {
+    client.close();
+  }
+});
+
+test('an unknown id is a 404', async () => {
+  const response = await requestJson('/events/999999/summary', 'PATCH', { summary: 'nobody' });
+  
+  expect(response.status).toBe(404);
+  expect((await response.json() as any).error.code).toBe('EVENT_NOT_FOUND');
+});
+
+test('a summary that is not a string is rejected', async () => {
+  const event = await postEvent({ session_id: 'summary-3' });
+  
+  const response = await requestJson(`/events/${event.id}/summary`, 'PATCH', { summary: 42 });
+  
+  expect(response.status).toBe(400);
+});
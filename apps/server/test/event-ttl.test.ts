@@ -0,0 +1,76 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { request, requestJson } from './server';
+
+const HOUR = 3600 * 1000;
+let expiredId: number;
+let freshId: number;
+
+beforeEach(async () => {
+  resetDatabase();
+  const now = Date.now();
+  const [expired, fresh] = await insertEvents([
+    makeEvent({ session_id: 'ttl-1', timestamp: now - 3 * HOUR, payload: { age: 'old' } }),
+    makeEvent({ session_id: 'ttl-1', timestamp: now - HOUR / 2, payload: { age: 'new' } })
+  ]);
+  expiredId = expired!.event.id!;
+  freshId = fresh!.event.id!;
+});
+
+async function getJson(path: string): Promise<any> {
+  const response = await request(path);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('without a TTL every event is visible', async () => {
+  const events = await getJson('/events/recent');
+  
+  expect(events.map((event: any) => event.id)).toEqual([expiredId, freshId]);
+});
+
+test('events older than the TTL are hidden from listings and counts', async () => {
+  setConfig({ EVENT_TTL_HOURS: 2 });
+  
+  expect((await getJson('/events/recent')).map((event: any) => event.id)).toEqual([freshId]);
+  expect((await getJson('/events?session_id=ttl-1')).map((event: any) => event.id)).toEqual([freshId]);
+  expect((await getJson('/events/count')).count).toBe(1);
+  expect((await request(`/events/${expiredId}`)).status).toBe(404);
+  expect((await getJson('/events/search?q=age')).map((event: any) => event.id)).toEqual([freshId]);
+});
+
+test('includeExpired=true shows expired events again', async () => {
+  setConfig({ EVENT_TTL_HOURS: 2 });
+  
+  expect((await getJson('/events/recent?includeExpired=true')).map((event: any) => event.id)).toEqual([expiredId, freshId]);
+  expect((await getJson('/events?session_id=ttl-1&includeExpired=true'))).toHaveLength(2);
+  expect((await getJson('/events/count?includeExpired=true')).count).toBe(2);
+  expect((await getJson(`/events/${expiredId}?includeExpired=true`)).payload.age).toBe('old');
+  expect((await getJson('/events/search?q=old&includeExpired=true')).map((event: any) => event.id)).toEqual([expiredId]);
+});
+
+test('expired events cannot be summarized or chatted on', async () => {
+  setConfig({ EVENT_TTL_HOURS: 2 });
+  
+  const summary = await requestJson(`/events/${expiredId}/summary`, 'PATCH', { summary: 'too late' });
+  const chat = await requestJson(`/events/${expiredId}/chat`, 'PATCH', { messages: [{ role: 'user', content: 'too late' }] });
+  
+  expect(summary.status).toBe(404);
+  expect((await summary.json() as any).error.code).toBe('EVENT_NOT_FOUND');
+  expect(chat.status).toBe(404);
+  expect((await chat.json() as any).error.code).toBe('EVENT_NOT_FOUND');
+  const stored = await getJson(`/events/${expiredId}?includeExpired=true`);
+  expect(stored.summary).toBeFalsy();
+  expect(stored.chat).toBeFalsy();
+  
+  expect((await requestJson(`/events/${freshId}/summary`, 'PATCH', { summary: 'in time' })).status).toBe(200);
+});
+
+test('hidden events are not deleted', async () => {
+  setConfig({ EVENT_TTL_HOURS: 2 });
+  expect((await getJson('/events/count')).count).toBe(1);
+  
+  setConfig({ EVENT_TTL_HOURS: 0 });
+  expect((await getJson('/events/count')).count).toBe(2);
+});
@@ -0,0 +1,46 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { validateEvent } from '../src/event';
+import { makeEvent, resetDatabase } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+async function postInvalid(body: Record<string, unknown>): Promise<any[]> {
+  const response = await requestJson('/events', 'POST', body);
+  const result = await response.json() as any;
+  expect(response.status).toBe(422);
+  expect(result.error.code).toBe('EVENT_INVALID');
+  return result.error.details.validationErrors;
+}
+
+test('each missing field is reported by name', async () => {
+  for (const field of ['source_app', 'session_id', 'hook_event_type', 'payload'] as const) {
+    const { [field]: _, ...event } = makeEvent();
+    
+    expect(await postInvalid(event)).toEqual([{ field, message: `${field} is required`, code: 'REQUIRED' }]);
+  }
+});
+
+test('empty strings and empty payloads are rejected with their own codes', async () => {
+  const errors = await postInvalid({ ...makeEvent(), source_app: '', session_id: '   ', payload: {} });
+  
+  expect(errors).toEqual([
+    { field: 'source_app', message: 'source_app must be a non-empty string', code: 'INVALID_FORMAT' },
+    { field: 'session_id', message: 'session_id must be a non-empty string', code: 'INVALID_FORMAT' },
+    { field: 'payload', message: 'payload must not be empty', code: 'EMPTY' }
+  ]);
+});
+
+test('a payload that is not an object is rejected', () => {
+  for (const payload of ['text', [1, 2], 42]) {
+    expect(validateEvent(makeEvent({ payload: payload as any }))).toEqual([
+      { field: 'payload', message: 'payload must be a JSON object', code: 'INVALID_FORMAT' }
+    ]);
+  }
+});
+
+test('a rejected event is not stored', async () => {
+  await postInvalid({ ...makeEvent(), payload: {} });
+  
+  expect(await (await request('/events/count')).json()).toEqual({ count: 0 });
+});
@@ -0,0 +1,118 @@
+import { afterAll, beforeEach, expect, test } from 'bun:test';
+import { resetDatabase, setConfig } from './helpers';
+import { postEvent, request, sleep } from './server';
+
+// Stand-in receiver; each test decides how it answers. Every test uses its
+// own paths, so its webhook URLs (and their breakers) are fresh.
+let answer: (attempt: number) => Response | Promise<Response> = () => new Response('ok');
+const received = new Map<string, any[]>();
+const stub = Bun.serve({
+  port: 0,
+  async fetch(req) {
+    const path = new URL(req.url).pathname;
+    const hits = received.get(path) ?? [];
+    received.set(path, hits);
+    hits.push(await req.json());
+    return answer(hits.length);
+  }
+});
+
+afterAll(() => stub.stop(true));
+
+beforeEach(() => {
+  resetDatabase();
+  answer = () => new Response('ok');
+  setConfig({ EVENT_WEBHOOK_BACKOFF_MS: 1 });
+});
+
+function hook(path: string): string {
+  return `http://localhost:${stub.port}${path}`;
+}
+
+function hits(path: string): any[] {
+  return received.get(path) ?? [];
+}
+
+async function waitFor(condition: () => boolean, timeoutMs: number = 2000): Promise<void> {
+  const deadline = Date.now() + timeoutMs;
+  while (!condition()) {
+    if (Date.now() > deadline) throw new Error(`Condition not met within ${timeoutMs}ms`);
+    await sleep(10);
+  }
+}
+
+async function deliveries(webhook: number, result: string): Promise<number> {
+  const series = `webhook_deliveries_total{result="${result}",webhook="${webhook}"}`;
+  const line = (await (await request('/metrics')).text()).split('\n').find(line => line.startsWith(`${series} `));
+  return line ? Number(line.slice(series.length + 1)) : 0;
+}
+
+test('every new event is posted to every webhook', async () => {
+  setConfig({ EVENT_WEBHOOKS: [hook('/all-a'), hook('/all-b')] });
+  const before = await deliveries(1, 'success');
+  
+  const event = await postEvent({ session_id: 'webhooks-all' });
+  await waitFor(() => hits('/all-a').length === 1 && hits('/all-b').length === 1);
+  
+  expect(hits('/all-a')[0].id).toBe(event.id);
+  expect(hits('/all-b')[0].session_id).toBe('webhooks-all');
+  await sleep(50);
+  expect(await deliveries(1, 'success')).toBe(before + 1);
+});
+
+test('EVENT_WEBHOOK_TYPES restricts which events are forwarded', async () => {
+  setConfig({ EVENT_WEBHOOKS: [hook('/types')], EVENT_WEBHOOK_TYPES: ['Stop'] });
+  
+  await postEvent({ session_id: 'webhooks-types', hook_event_type: 'PreToolUse' });
+  const stop = await postEvent({ session_id: 'webhooks-types', hook_event_type: 'Stop' });
+  await waitFor(() => hits('/types').length === 1);
+  await sleep(50);
+  
+  expect(hits('/types').map(event => event.id)).toEqual([stop.id]);
+});
+
+test('failed attempts are retried until one succeeds', async () => {
+  setConfig({ EVENT_WEBHOOKS: [hook('/retry')], EVENT_WEBHOOK_RETRIES: 3 });
+  answer = attempt => new Response(attempt < 3 ? 'try again' : 'ok', { status: attempt < 3 ? 502 : 200 });
+  const before = await deliveries(0, 'success');
+  
+  const event = await postEvent({ session_id: 'webhooks-retry' });
+  await waitFor(() => hits('/retry').length === 3);
+  await sleep(50);
+  
+  expect(hits('/retry').every(hit => hit.id === event.id)).toBe(true);
+  expect(hits('/retry')).toHaveLength(3);
+  expect(await deliveries(0, 'success')).toBe(before + 1);
+});
+
+test('a webhook that keeps failing is counted once it gives up', async () => {
+  setConfig({ EVENT_WEBHOOKS: [hook('/down')], EVENT_WEBHOOK_RETRIES: 2 });
+  answer = () => new Response('down', { status: 500 });
+  const before = await deliveries(0, 'failure');
+  
+  const event = await postEvent({ session_id: 'webhooks-down' });
+  expect(event.id).toBeDefined();
+  await waitFor(() => hits('/down').length === 3);
+  await sleep(50);
+  
+  // One initial attempt plus EVENT_WEBHOOK_RETRIES
+  expect(hits('/down')).toHaveLength(3);
+  expect(await deliveries(0, 'failure')).toBe(before + 1);
+});
+
+test('slow webhooks time out without holding up ingestion', async () => {
+  setConfig({ EVENT_WEBHOOKS: [hook('/slow')], EVENT_WEBHOOK_RETRIES: 0, EVENT_WEBHOOK_TIMEOUT_MS: 50 });
+  answer = async () => {
+    await sleep(500);
+    return new Response('ok');
+  };
+  const before = await deliveries(0, 'failure');
+  
+  const started = performance.now();
+  await postEvent({ session_id: 'webhooks-slow' });
+  expect(performance.now() - started).toBeLessThan(400);
+  
+  await waitFor(() => hits('/slow').length === 1);
+  await sleep(150);
+  expect(await deliveries(0, 'failure')).toBe(before + 1);
+});
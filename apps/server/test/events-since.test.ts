@@ -0,0 +1,56 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+let ids: number[];
+
+beforeEach(async () => {
+  resetDatabase();
+  const inserted = await insertEvents(Array.from({ length: 6 }, (_, i) => makeEvent({ session_id: `since-${i % 2}` })));
+  ids = inserted.map(result => result.event.id!);
+});
+
+async function since(query: string): Promise<any> {
+  const response = await request(`/events/since?${query}`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('returns every event after the given id, oldest first', async () => {
+  const body = await since(`id=${ids[2]}`);
+  
+  expect(body.data.map((event: any) => event.id)).toEqual(ids.slice(3));
+  expect(body.truncated).toBe(false);
+  expect(body.lastId).toBe(ids[5]);
+});
+
+test('nothing newer gives an empty page that keeps the given id', async () => {
+  const body = await since(`id=${ids[5]}`);
+  
+  expect(body).toEqual({ data: [], truncated: false, lastId: ids[5] });
+});
+
+test('a capped result is flagged and lastId continues the catch-up', async () => {
+  const first = await since('id=0&limit=4');
+  expect(first.data.map((event: any) => event.id)).toEqual(ids.slice(0, 4));
+  expect(first.truncated).toBe(true);
+  
+  const rest = await since(`id=${first.lastId}&limit=4`);
+  expect(rest.data.map((event: any) => event.id)).toEqual(ids.slice(4));
+  expect(rest.truncated).toBe(false);
+});
+
+test('event filters narrow the catch-up', async () => {
+  const body = await since(`id=0&session_id=since-1`);
+  
+  expect(body.data.map((event: any) => event.id)).toEqual([ids[1], ids[3], ids[5]]);
+});
+
+test('a missing or negative id is rejected', async () => {
+  for (const query of ['', 'id=abc', 'id=-1']) {
+    const response = await request(`/events/since?${query}`);
+    expect(response.status).toBe(400);
+    expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+  }
+});
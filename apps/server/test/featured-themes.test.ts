@@ -0,0 +1,79 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { invalidateCache } from '../src/cache';
+import { insertTheme } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeTheme, resetDatabase, setConfig } from './helpers';
+import { request, requestJson } from './server';
+import type { Theme } from '../src/types';
+
+beforeEach(() => {
+  resetDatabase();
+  invalidateCache('');
+  setConfig({ API_KEY: ADMIN_KEY });
+});
+
+async function seedTheme(name: string, rating: number, isPublic: boolean = true): Promise<Theme> {
+  const now = Date.now();
+  return insertTheme({ ...makeTheme({ name, isPublic }), id: `theme-${name}`, createdAt: now, updatedAt: now, rating, ratingCount: 1 } as Theme);
+}
+
+function feature(id: string, featured: unknown, headers: Record<string, string> = adminHeaders): Promise<Response> {
+  return requestJson(`/admin/themes/${id}/featured`, 'PUT', { featured }, headers);
+}
+
+async function featuredNames(): Promise<string[]> {
+  const response = await request('/api/themes/featured');
+  expect(response.status).toBe(200);
+  return (await response.json() as any).data.map((theme: Theme) => theme.name);
+}
+
+test('featured public themes are listed best rated first', async () => {
+  for (const [name, rating] of [['okay', 3], ['great', 5], ['good', 4], ['unfeatured', 4.5]] as const) {
+    await seedTheme(name, rating);
+  }
+  for (const name of ['okay', 'great', 'good']) {
+    expect((await feature(`theme-${name}`, true)).status).toBe(200);
+  }
+  
+  expect(await featuredNames()).toEqual(['great', 'good', 'okay']);
+});
+
+test('toggling the flag off removes a theme from the list', async () => {
+  await seedTheme('toggled', 4);
+  
+  const on = await feature('theme-toggled', true);
+  expect((await on.json() as any).data.isFeatured).toBe(true);
+  expect(await featuredNames()).toEqual(['toggled']);
+  
+  const off = await feature('theme-toggled', false);
+  expect((await off.json() as any).data.isFeatured).toBe(false);
+  expect(await featuredNames()).toEqual([]);
+});
+
+test('private themes stay out of the featured list', async () => {
+  await seedTheme('hidden', 5, false);
+  await feature('theme-hidden', true);
+  
+  expect(await featuredNames()).toEqual([]);
+});
+
+test('featuring needs the admin key', async () => {
+  await seedTheme('guarded', 4);
+  
+  expect((await feature('theme-guarded', true, {})).status).toBe(401);
+  expect(await featuredNames()).toEqual([]);
+});
+
+test('bad requests are rejected', async () => {
+  expect((await feature('theme-missing', true)).status).toBe(404);
+  
+  await seedTheme('strict', 4);
+  expect((await feature('theme-strict', 'yes')).status).toBe(400);
+});
+
+test('the featured flag cannot be set through the public update', async () => {
+  const theme = await seedTheme('sneaky', 4);
+  
+  await requestJson(`/api/themes/${theme.id}`, 'PUT', { isFeatured: true, updatedAt: theme.updatedAt });
+  
+  expect(await featuredNames()).toEqual([]);
+});
@@ -0,0 +1,64 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  await seedEvents([
+    makeEvent({ source_app: 'alpha', session_id: 'abc-1', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'alpine', session_id: 'abc-2', hook_event_type: 'PostToolUse' }),
+    makeEvent({ source_app: 'beta', session_id: 'abd-1', hook_event_type: 'Stop' }),
+    makeEvent({ source_app: 'gamma', session_id: 'xyz-1', hook_event_type: 'Notification' }),
+    makeEvent({ source_app: 'alpha', session_id: 'abc-3', hook_event_type: 'PreToolUse' })
+  ]);
+});
+
+async function filterOptions(query: string = ''): Promise<any> {
+  const response = await request(`/events/filter-options${query}`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('without parameters every category lists all distinct values', async () => {
+  const options = await filterOptions();
+  
+  expect(options.source_apps).toEqual(['alpha', 'alpine', 'beta', 'gamma']);
+  expect(options.session_ids).toEqual(['xyz-1', 'abd-1', 'abc-3', 'abc-2', 'abc-1']);
+  expect(options.hook_event_types).toEqual(['Notification', 'PostToolUse', 'PreToolUse', 'Stop']);
+});
+
+test('a prefix narrows only its own category', async () => {
+  const options = await filterOptions('?sessionPrefix=abc');
+  
+  expect(options.session_ids).toEqual(['abc-3', 'abc-2', 'abc-1']);
+  expect(options.source_apps).toHaveLength(4);
+  expect(options.hook_event_types).toHaveLength(4);
+});
+
+test('prefixes in different categories apply independently', async () => {
+  const options = await filterOptions('?sourceAppPrefix=alp&hookEventTypePrefix=Pre');
+  
+  expect(options.source_apps).toEqual(['alpha', 'alpine']);
+  expect(options.hook_event_types).toEqual(['PreToolUse']);
+  expect(options.session_ids).toHaveLength(5);
+});
+
+test('a per-category limit keeps the stable order and leaves the others whole', async () => {
+  const options = await filterOptions('?sessionLimit=2&sourceAppLimit=3');
+  
+  expect(options.session_ids).toEqual(['xyz-1', 'abd-1']);
+  expect(options.source_apps).toEqual(['alpha', 'alpine', 'beta']);
+  expect(options.hook_event_types).toHaveLength(4);
+});
+
+test('prefix and limit combine within a category', async () => {
+  const options = await filterOptions('?sessionPrefix=ab&sessionLimit=2');
+  
+  expect(options.session_ids).toEqual(['abd-1', 'abc-3']);
+});
+
+test('LIKE wildcards in a prefix match literally', async () => {
+  const options = await filterOptions('?sessionPrefix=%25');
+  
+  expect(options.session_ids).toEqual([]);
+});
@@ -0,0 +1,61 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvent, insertEvents } from '../src/db';
+import { forgetSession, introducesNewFilterValue } from '../src/filters';
+import { makeEvent, resetDatabase } from './helpers';
+import { connectClient, postEvent, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+// Known values live for the whole process, so every test uses its own names
+test('the first event with an unseen source app, type or session is new', async () => {
+  const { event } = await insertEvent(makeEvent({ source_app: 'filters-new-app', session_id: 'filters-new-1', hook_event_type: 'FiltersNewType' }));
+  expect(introducesNewFilterValue(event)).toBe(true);
+});
+
+test('repeated values are not new', async () => {
+  const values = { source_app: 'filters-repeat-app', session_id: 'filters-repeat-1', hook_event_type: 'FiltersRepeatType' };
+  const first = (await insertEvent(makeEvent(values))).event;
+  const second = (await insertEvent(makeEvent(values))).event;
+  
+  expect(introducesNewFilterValue(first)).toBe(true);
+  expect(introducesNewFilterValue(second)).toBe(false);
+});
+
+test('a session missing from memory but already stored is not new', async () => {
+  const values = { source_app: 'filters-evicted-app', session_id: 'filters-evicted-1', hook_event_type: 'FiltersEvictedType' };
+  expect(introducesNewFilterValue((await insertEvent(makeEvent(values))).event)).toBe(true);
+  
+  // Same effect as the session falling out of the bounded cache
+  forgetSession(values.session_id);
+  expect(introducesNewFilterValue((await insertEvent(makeEvent(values))).event)).toBe(false);
+});
+
+test('a new session saved in one batch is new for its first event only', async () => {
+  const known = { source_app: 'filters-batch-app', hook_event_type: 'FiltersBatchType' };
+  expect(introducesNewFilterValue((await insertEvent(makeEvent({ ...known, session_id: 'filters-batch-0' }))).event)).toBe(true);
+  
+  const [first, second] = await insertEvents([
+    makeEvent({ ...known, session_id: 'filters-batch-1' }),
+    makeEvent({ ...known, session_id: 'filters-batch-1' })
+  ]);
+  expect(introducesNewFilterValue(first!.event)).toBe(true);
+  expect(introducesNewFilterValue(second!.event)).toBe(false);
+});
+
+test('filters_updated is broadcast only for genuinely new values', async () => {
+  const client = await connectClient();
+  try {
+    const values = { source_app: 'filters-ws-app', session_id: 'filters-ws-1', hook_event_type: 'PreToolUse' };
+    await postEvent(values);
+    const update = await client.next('filters_updated');
+    expect(update.data.source_apps).toContain('filters-ws-app');
+    
+    await postEvent(values);
+    await client.next('event');
+    await client.next('event');
+    await sleep(50);
+    expect(client.messages.filter(message => message.type === 'filters_updated')).toHaveLength(1);
+  } finally {
+    client.close();
+  }
+});
@@ -0,0 +1,29 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { closeDatabase } from '../src/db';
+import { getClientCount } from '../src/websocket';
+import { resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+test('a reachable database reports healthy', async () => {
+  const response = await request('/health');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(body.status).toBe('healthy');
+  expect(typeof body.timestamp).toBe('number');
+  expect(body.checks.database).toBe('ok');
+  expect(body.checks.websocket_clients).toBe(getClientCount());
+});
+
+test('a closed database connection reports unhealthy with 503', async () => {
+  closeDatabase();
+  
+  const response = await request('/health');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(503);
+  expect(body.status).toBe('unhealthy');
+  expect(body.checks.database).toBe('fail');
+});
@@ -0,0 +1,33 @@
+import { expect, test } from 'bun:test';
+import { config } from '../src/config';
+import { sweepClients, wsClients } from '../src/websocket';
+import { connectClient, serverSocketOf, sleep } from './server';
+
+test('a sweep pings clients and their pongs are recorded', async () => {
+  const client = await connectClient();
+  try {
+    const socket = await serverSocketOf(client);
+    const before = Date.now() - 1000;
+    socket.data.lastPongAt = before;
+    
+    sweepClients();
+    await sleep(100);
+    
+    expect(socket.data.lastPongAt).toBeGreaterThan(before);
+    expect(wsClients.has(socket)).toBe(true);
+  } finally {
+    client.close();
+  }
+});
+
+test('a client silent for more than two intervals is terminated', async () => {
+  const client = await connectClient();
+  const closed = new Promise(resolve => client.ws.addEventListener('close', resolve));
+  const socket = await serverSocketOf(client);
+  socket.data.lastPongAt = Date.now() - config.WS_HEARTBEAT_INTERVAL * 3;
+  
+  sweepClients();
+  
+  expect(wsClients.has(socket)).toBe(false);
+  await closed;
+});
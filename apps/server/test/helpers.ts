@@ -0,0 +1,70 @@
+import { createHmac } from 'node:crypto';
+import { config } from '../src/config';
+import { closeDatabase, initInMemoryDatabase, insertEvents } from '../src/db';
+import { generatePalette } from '../src/theme';
+import type { HookEvent } from '../src/types';
+
+type Config = typeof config;
+
+const originals = new Map<string, unknown>();
+
+// Override config values for the current test; undone after each test
+export function setConfig(overrides: Partial<Config>): void {
+  for (const [key, value] of Object.entries(overrides)) {
+    if (!originals.has(key)) originals.set(key, (config as any)[key]);
+    (config as any)[key] = value;
+  }
+}
+
+export function restoreConfig(): void {
+  originals.forEach((value, key) => {
+    (config as any)[key] = value;
+  });
+  originals.clear();
+}
+
+// Replace the database with a fresh, migrated in-memory one
+export function resetDatabase(): void {
+  closeDatabase();
+  initInMemoryDatabase();
+}
+
+// Store events directly, bypassing the HTTP layer, and return the saved rows
+export async function seedEvents(events: HookEvent[]): Promise<HookEvent[]> {
+  return (await insertEvents(events)).map(result => result.event);
+}
+
+export function makeEvent(overrides: Partial<HookEvent> = {}): HookEvent {
+  return {
+    source_app: 'test-app',
+    session_id: 'session-1',
+    hook_event_type: 'PreToolUse',
+    payload: { tool_name: 'Bash', tool_input: { command: 'ls' } },
+    ...overrides
+  };
+}
+
+// A valid theme body; the palette passes the contrast checks
+export function makeTheme(overrides: Record<string, unknown> = {}): Record<string, any> {
+  return {
+    name: 'test-theme',
+    displayName: 'Test Theme',
+    colors: generatePalette('#3366cc'),
+    isPublic: true,
+    tags: [],
+    ...overrides
+  };
+}
+
+const base64Url = (value: string | Buffer) => Buffer.from(value).toString('base64url');
+
+// Sign an HS256 JWT the way an identity provider would
+export function signJwt(claims: Record<string, unknown>, secret: string): string {
+  const unsigned = `${base64Url(JSON.stringify({ alg: 'HS256', typ: 'JWT' }))}.${base64Url(JSON.stringify(claims))}`;
+  return `${unsigned}.${base64Url(createHmac('sha256', secret).update(unsigned).digest())}`;
+}
+
+// Admin routes stay closed until a test enables them with
+// setConfig({ API_KEY: ADMIN_KEY }) and sends adminHeaders
+export const ADMIN_KEY = 'test-admin-key';
+export const adminHeaders = { 'X-API-Key': ADMIN_KEY };
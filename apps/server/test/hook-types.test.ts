@@ -0,0 +1,37 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { validateEvent } from '../src/event';
+import { HOOK_EVENT_TYPES } from '../src/types';
+import { makeEvent, resetDatabase } from './helpers';
+import { postEvent, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+test.each([...HOOK_EVENT_TYPES])('%s is accepted', async type => {
+  const saved = await postEvent({ session_id: 'hook-types', hook_event_type: type });
+  
+  expect(saved.hook_event_type).toBe(type);
+});
+
+test('an unknown type is rejected with the allowed list', async () => {
+  const response = await requestJson('/events', 'POST', makeEvent({ hook_event_type: 'PreToolUes' }));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(422);
+  expect(body.error.details.validationErrors).toEqual([{
+    field: 'hook_event_type',
+    message: `Unknown hook_event_type "PreToolUes"; expected one of ${HOOK_EVENT_TYPES.join(', ')}`,
+    code: 'INVALID_ENUM'
+  }]);
+});
+
+test('allowUnknown=true lets an unknown type through', async () => {
+  const response = await requestJson('/events?allowUnknown=true', 'POST', makeEvent({ hook_event_type: 'CustomHook' }));
+  
+  expect(response.status).toBe(200);
+  expect((await response.json() as any).hook_event_type).toBe('CustomHook');
+  expect(validateEvent(makeEvent({ hook_event_type: 'CustomHook' }), { allowUnknownTypes: true })).toEqual([]);
+});
+
+test('type names are case-sensitive', () => {
+  expect(validateEvent(makeEvent({ hook_event_type: 'pretooluse' })).map(error => error.code)).toEqual(['INVALID_ENUM']);
+});
@@ -0,0 +1,71 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { countEvents, insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { connectClient, requestJson, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+function post(overrides = {}): Promise<Response> {
+  return requestJson('/events', 'POST', makeEvent({ session_id: 'idempotency', ...overrides }));
+}
+
+test('posting the same uuid twice stores one event and returns it both times', async () => {
+  const first = await post({ event_uuid: 'uuid-1' });
+  const second = await post({ event_uuid: 'uuid-1', payload: { retried: true } });
+  const firstBody = await first.json() as any;
+  const secondBody = await second.json() as any;
+  
+  expect(first.status).toBe(200);
+  expect(first.headers.get('idempotent-replayed')).toBe('false');
+  expect(second.status).toBe(200);
+  expect(second.headers.get('idempotent-replayed')).toBe('true');
+  expect(secondBody.id).toBe(firstBody.id);
+  // The stored event wins over the retry's body
+  expect(secondBody.payload).toEqual(firstBody.payload);
+  expect(countEvents()).toBe(1);
+});
+
+test('a replayed event is not broadcast again', async () => {
+  const client = await connectClient();
+  try {
+    await post({ event_uuid: 'uuid-broadcast' });
+    await post({ event_uuid: 'uuid-broadcast' });
+    await sleep(100);
+    
+    expect(client.messages.filter(message => message.type === 'event')).toHaveLength(1);
+  } finally {
+    client.close();
+  }
+});
+
+test('concurrent posts of one uuid still store a single row', async () => {
+  const responses = await Promise.all(Array.from({ length: 5 }, () => post({ event_uuid: 'uuid-race' })));
+  const ids = await Promise.all(responses.map(async response => (await response.json() as any).id));
+  
+  expect(new Set(ids).size).toBe(1);
+  expect(countEvents()).toBe(1);
+});
+
+test('duplicates within one batch are stored once', async () => {
+  const results = await insertEvents([
+    makeEvent({ event_uuid: 'uuid-batch' }),
+    makeEvent({ event_uuid: 'uuid-batch' })
+  ]);
+  
+  expect(results.map(result => result.created)).toEqual([true, false]);
+  expect(results[1]!.event.id).toBe(results[0]!.event.id);
+  expect(countEvents()).toBe(1);
+});
+
+test('events without a uuid are never deduplicated', async () => {
+  await post();
+  await post();
+  
+  expect(countEvents()).toBe(2);
+});
+
+test('an empty uuid is rejected', async () => {
+  const response = await post({ event_uuid: '  ' });
+  
+  expect(response.status).toBe(422);
+});
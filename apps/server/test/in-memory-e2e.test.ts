@@ -0,0 +1,64 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { config } from '../src/config';
+import { countEvents, IN_MEMORY_DATABASE, insertEvent, readPragma } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeEvent, makeTheme, resetDatabase, setConfig } from './helpers';
+import { connectClient, postEvent, request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+test('the test run is backed by an in-memory database', () => {
+  expect(config.DATABASE_PATH).toBe(IN_MEMORY_DATABASE);
+  expect(readPragma('journal_mode')).toBe('memory');
+});
+
+test('a fresh in-memory database is migrated and empty', async () => {
+  await insertEvent(makeEvent());
+  expect(countEvents()).toBe(1);
+  
+  resetDatabase();
+  
+  expect(countEvents()).toBe(0);
+  expect((await request('/api/themes')).status).toBe(200);
+});
+
+test('events flow through the full handler stack', async () => {
+  setConfig({ API_KEY: ADMIN_KEY });
+  const client = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  try {
+    // Ingest, and the live feed sees it
+    const event = await postEvent({ source_app: 'e2e-app', session_id: 'e2e-1', payload: { tool_name: 'Read' } });
+    expect((await client.next('event')).data.id).toBe(event.id);
+    
+    // Read it back several ways
+    expect((await (await request('/events/recent')).json() as any[]).map(e => e.id)).toEqual([event.id]);
+    expect((await (await request(`/events/${event.id}`)).json() as any).payload).toEqual({ tool_name: 'Read' });
+    expect((await (await request('/events/filter-options')).json() as any).source_apps).toEqual(['e2e-app']);
+    
+    // Update it
+    const summarized = await requestJson(`/events/${event.id}/summary`, 'PATCH', { summary: 'Read a file' });
+    expect((await summarized.json() as any).summary).toBe('Read a file');
+    expect((await client.next('event_updated')).data.summary).toBe('Read a file');
+    
+    // Delete it
+    expect((await request(`/events/${event.id}`, { method: 'DELETE', headers: adminHeaders })).status).toBe(200);
+    expect((await request(`/events/${event.id}`)).status).toBe(404);
+    expect(await (await request('/events/count')).json()).toEqual({ count: 0 });
+  } finally {
+    client.close();
+  }
+});
+
+test('themes flow through the full handler stack', async () => {
+  const created = await requestJson('/api/themes', 'POST', makeTheme({ name: 'e2e-theme' }));
+  const theme = (await created.json() as any).data;
+  expect(created.status).toBe(201);
+  
+  const updated = await requestJson(`/api/themes/${theme.id}`, 'PUT', { displayName: 'E2E', updatedAt: theme.updatedAt });
+  expect((await updated.json() as any).data.displayName).toBe('E2E');
+  
+  const listed = await (await request('/api/themes?query=e2e')).json() as any;
+  expect(listed.data.map((t: any) => t.id)).toEqual([theme.id]);
+  
+  expect((await request(`/api/themes/${theme.id}`, { method: 'DELETE' })).status).toBe(200);
+  expect((await request(`/api/themes/${theme.id}`)).status).toBe(404);
+});
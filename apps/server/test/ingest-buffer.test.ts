@@ -0,0 +1,90 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { closeDatabase, countEvents } from '../src/db';
+import { enqueueEvent, flushEvents, getDroppedEventCount, getQueueDepth, startIngestBuffer, stopIngestBuffer } from '../src/ingest';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { requestJson, sleep } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ INGEST_BUFFER_ENABLED: true, INGEST_BATCH_SIZE: 3, INGEST_QUEUE_MAX: 100 });
+});
+
+afterEach(async () => {
+  // Nothing may stay queued for the next test
+  await stopIngestBuffer();
+});
+
+function postBuffered(overrides = {}): Promise<Response> {
+  return requestJson('/events', 'POST', makeEvent({ session_id: 'ingest-buffer', ...overrides }));
+}
+
+test('events are accepted with 202 and written once a batch is full', async () => {
+  for (let i = 0; i < 2; i++) {
+    const response = await postBuffered();
+    expect(response.status).toBe(202);
+    expect(await response.json()).toEqual({ status: 'accepted' });
+  }
+  expect(getQueueDepth()).toBe(2);
+  expect(countEvents()).toBe(0);
+  
+  // The third event fills the batch and starts a flush
+  await postBuffered();
+  await flushEvents();
+  
+  expect(getQueueDepth()).toBe(0);
+  expect(countEvents()).toBe(3);
+});
+
+test('a flush writes the whole queue in batches', async () => {
+  setConfig({ INGEST_BATCH_SIZE: 1000 });
+  for (let i = 0; i < 7; i++) {
+    expect(enqueueEvent(makeEvent({ session_id: 'ingest-batches' }))).toBe(true);
+  }
+  setConfig({ INGEST_BATCH_SIZE: 3 });
+  
+  await flushEvents();
+  
+  expect(getQueueDepth()).toBe(0);
+  expect(countEvents({ session_id: 'ingest-batches' })).toBe(7);
+});
+
+test('a full queue answers 503 with Retry-After', async () => {
+  setConfig({ INGEST_BATCH_SIZE: 100, INGEST_QUEUE_MAX: 2 });
+  await postBuffered();
+  await postBuffered();
+  
+  const response = await postBuffered();
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(503);
+  expect(response.headers.get('retry-after')).toBe('1');
+  expect(body.error.code).toBe('INGEST_QUEUE_FULL');
+  expect(getQueueDepth()).toBe(2);
+  
+  await flushEvents();
+  expect(countEvents()).toBe(2);
+});
+
+test('the background timer flushes partial batches', async () => {
+  setConfig({ INGEST_BATCH_SIZE: 100, INGEST_FLUSH_INTERVAL_MS: 50 });
+  startIngestBuffer(() => {});
+  
+  await postBuffered();
+  await sleep(200);
+  
+  expect(countEvents()).toBe(1);
+});
+
+test('a failing batch is retried, then dropped and counted', async () => {
+  setConfig({ INGEST_BATCH_SIZE: 100, INGEST_FLUSH_RETRIES: 1 });
+  const dropped = getDroppedEventCount();
+  enqueueEvent(makeEvent());
+  closeDatabase();
+  
+  await flushEvents();
+  expect(getQueueDepth()).toBe(1);
+  
+  await flushEvents();
+  expect(getQueueDepth()).toBe(0);
+  expect(getDroppedEventCount()).toBe(dropped + 1);
+});
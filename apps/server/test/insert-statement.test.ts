@@ -0,0 +1,23 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { countEvents, getEventById, insertEvent } from '../src/db';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+
+beforeEach(resetDatabase);
+
+test('10k inserts through the shared statement are all stored', async () => {
+  const saved = await seedEvents(Array.from({ length: 10_000 }, (_, i) => makeEvent({ session_id: `bulk-${i % 50}`, timestamp: 1700000000000 + i })));
+  
+  expect(countEvents({ includeExpired: true })).toBe(10_000);
+  expect(new Set(saved.map(event => event.id)).size).toBe(10_000);
+  expect(getEventById(saved[9_999]!.id!, false, true)?.session_id).toBe('bulk-49');
+});
+
+test('the statement is prepared again against a reopened database', async () => {
+  await insertEvent(makeEvent());
+  
+  resetDatabase();
+  const { event } = await insertEvent(makeEvent({ session_id: 'after-reopen' }));
+  
+  expect(countEvents()).toBe(1);
+  expect(getEventById(event.id!)?.session_id).toBe('after-reopen');
+});
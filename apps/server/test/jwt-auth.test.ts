@@ -0,0 +1,60 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { verifyJwt } from '../src/auth';
+import { makeTheme, resetDatabase, setConfig, signJwt } from './helpers';
+import { requestJson } from './server';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ JWT_SECRET: SECRET });
+});
+
+const inOneHour = () => Math.floor(Date.now() / 1000) + 3600;
+
+function createThemeAs(token: string | null, body: Record<string, unknown> = makeTheme()) {
+  return requestJson('/api/themes', 'POST', body, token ? { 'Authorization': `Bearer ${token}` } : {});
+}
+
+test('a valid token makes its subject the author, whatever the body says', async () => {
+  const token = signJwt({ sub: 'alice', exp: inOneHour() }, SECRET);
+  const response = await createThemeAs(token, makeTheme({ authorId: 'mallory' }));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(201);
+  expect(body.data.authorId).toBe('alice');
+});
+
+test('an expired token is rejected with 401', async () => {
+  const token = signJwt({ sub: 'alice', exp: Math.floor(Date.now() / 1000) - 60 }, SECRET);
+  const response = await createThemeAs(token);
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(401);
+  expect(response.headers.get('www-authenticate')).toBe('Bearer');
+  expect(body.error).toEqual({ code: 'UNAUTHORIZED', message: 'Token expired' });
+});
+
+test('a token signed with another secret is rejected with 401', async () => {
+  const token = signJwt({ sub: 'alice', exp: inOneHour() }, 'some-other-secret');
+  const response = await createThemeAs(token);
+  
+  expect(response.status).toBe(401);
+  expect((await response.json() as any).error.message).toBe('Invalid token signature');
+});
+
+test('a missing token is rejected with 401 on protected routes', async () => {
+  const response = await createThemeAs(null);
+  
+  expect(response.status).toBe(401);
+  expect((await response.json() as any).error.message).toBe('Missing bearer token');
+});
+
+test('tokens without a subject or with another algorithm are refused', () => {
+  expect(verifyJwt(signJwt({ exp: inOneHour() }, SECRET), SECRET)).toEqual({ ok: false, error: 'Token missing subject' });
+  
+  const [, claims, signature] = signJwt({ sub: 'alice' }, SECRET).split('.');
+  const none = `${Buffer.from(JSON.stringify({ alg: 'none' })).toString('base64url')}.${claims}.${signature}`;
+  expect(verifyJwt(none, SECRET)).toEqual({ ok: false, error: 'Unsupported token algorithm' });
+  expect(verifyJwt('not-a-token', SECRET)).toEqual({ ok: false, error: 'Malformed token' });
+});
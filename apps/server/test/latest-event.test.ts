@@ -0,0 +1,40 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getLatestEvent, insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+test('an empty table answers 204 with no body', async () => {
+  const response = await request('/events/latest');
+  
+  expect(response.status).toBe(204);
+  expect(await response.text()).toBe('');
+  expect(getLatestEvent()).toBeNull();
+});
+
+test('the newest event by timestamp is returned', async () => {
+  const now = Date.now();
+  // Inserted out of order, so the newest is not the highest id
+  await insertEvents([
+    makeEvent({ session_id: 'middle', timestamp: now - 2000 }),
+    makeEvent({ session_id: 'newest', timestamp: now - 1000 }),
+    makeEvent({ session_id: 'oldest', timestamp: now - 3000 })
+  ]);
+  
+  const response = await request('/events/latest');
+  
+  expect(response.status).toBe(200);
+  expect((await response.json() as any).session_id).toBe('newest');
+});
+
+test('filters narrow the candidates', async () => {
+  const now = Date.now();
+  await insertEvents([
+    makeEvent({ source_app: 'widget-a', session_id: 'a-latest', timestamp: now - 2000 }),
+    makeEvent({ source_app: 'widget-b', session_id: 'b-latest', timestamp: now - 1000 })
+  ]);
+  
+  expect((await (await request('/events/latest?source_app=widget-a')).json() as any).session_id).toBe('a-latest');
+  expect((await request('/events/latest?source_app=widget-c')).status).toBe(204);
+});
@@ -0,0 +1,51 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, makeTheme, resetDatabase, seedEvents, setConfig } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  setConfig({ DEFAULT_PAGE_SIZE: 3, MAX_PAGE_SIZE: 4 });
+  const now = Date.now();
+  await seedEvents(Array.from({ length: 6 }, (_, i) => makeEvent({ timestamp: now - 6000 + i, payload: { n: i } })));
+});
+
+async function recent(query: string): Promise<any> {
+  const response = await request(`/events/recent?envelope=true&${query}`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('an oversized limit is clamped to MAX_PAGE_SIZE', async () => {
+  const page = await recent('limit=10000000');
+  
+  expect(page.limit).toBe(4);
+  expect(page.data).toHaveLength(4);
+});
+
+test('zero, negative and non-numeric limits fall back to the default', async () => {
+  for (const limit of ['0', '-5', 'lots']) {
+    const page = await recent(`limit=${limit}`);
+    expect(page.limit).toBe(3);
+    expect(page.data).toHaveLength(3);
+  }
+});
+
+test('a negative offset is treated as zero', async () => {
+  const page = await recent('limit=2&offset=-10');
+  
+  expect(page.offset).toBe(0);
+  expect(page.data.map((event: any) => event.payload.n)).toEqual([4, 5]);
+});
+
+test('theme search is clamped the same way', async () => {
+  for (let i = 0; i < 5; i++) {
+    const response = await requestJson('/api/themes', 'POST', makeTheme({ name: `clamped-${i}` }));
+    expect(response.status).toBe(201);
+  }
+  
+  const oversized = await (await request('/api/themes?limit=10000000')).json() as any;
+  const negative = await (await request('/api/themes?limit=-1')).json() as any;
+  
+  expect(oversized.data).toHaveLength(4);
+  expect(negative.data).toHaveLength(3);
+});
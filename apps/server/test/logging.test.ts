@@ -0,0 +1,62 @@
+import { afterEach, expect, test } from 'bun:test';
+import { existsSync, mkdtempSync, readFileSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { formatLogLine, logger } from '../src/logger';
+import { setConfig } from './helpers';
+
+let dir: string | undefined;
+
+afterEach(() => {
+  if (dir) rmSync(dir, { recursive: true, force: true });
+  dir = undefined;
+});
+
+test('text format is a level prefix and the message', () => {
+  setConfig({ LOG_FORMAT: 'text' });
+  
+  expect(formatLogLine('info', ['Server started on port', 4000])).toBe('[info] Server started on port 4000');
+  expect(formatLogLine('warn', ['slow'], 'req-1')).toBe('[warn] [req req-1] slow');
+});
+
+test('json format is one object per line', () => {
+  setConfig({ LOG_FORMAT: 'json' });
+  
+  const line = formatLogLine('error', ['Failed:', new Error('boom')]);
+  const parsed = JSON.parse(line);
+  
+  expect(line).not.toContain('\n');
+  expect(parsed.level).toBe('error');
+  expect(parsed.msg).toStartWith('Failed: Error: boom');
+  expect(Date.parse(parsed.time)).not.toBeNaN();
+  expect(parsed).not.toHaveProperty('request_id');
+});
+
+test('LOG_FILE receives the lines and rotates by size', () => {
+  dir = mkdtempSync(join(tmpdir(), 'observability-log-'));
+  const path = join(dir, 'server.log');
+  setConfig({ LOG_FORMAT: 'text', LOG_LEVEL: 'info', LOG_FILE: path, LOG_FILE_MAX_BYTES: 100, LOG_FILE_MAX_FILES: 2 });
+  
+  // 40 bytes per line, so two lines fit in a file
+  for (let i = 1; i <= 7; i++) {
+    logger.info(`line ${i} `.padEnd(32, '.'));
+  }
+  
+  const lines = (file: string) => readFileSync(file, 'utf8').trim().split('\n').map(line => line.slice('[info] line '.length, '[info] line '.length + 1));
+  expect(lines(path)).toEqual(['7']);
+  expect(lines(`${path}.1`)).toEqual(['5', '6']);
+  expect(lines(`${path}.2`)).toEqual(['3', '4']);
+  // Older files past LOG_FILE_MAX_FILES are discarded
+  expect(existsSync(`${path}.3`)).toBe(false);
+});
+
+test('lines below LOG_LEVEL are not written', () => {
+  dir = mkdtempSync(join(tmpdir(), 'observability-log-'));
+  const path = join(dir, 'server.log');
+  setConfig({ LOG_LEVEL: 'warn', LOG_FILE: path });
+  
+  logger.info('hidden');
+  logger.debug('hidden');
+  
+  expect(existsSync(path)).toBe(false);
+});
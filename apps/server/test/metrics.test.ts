@@ -0,0 +1,56 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { routeLabel } from '../src/metrics';
+import { getClientCount } from '../src/websocket';
+import { resetDatabase } from './helpers';
+import { connectClient, postEvent, request } from './server';
+
+beforeEach(resetDatabase);
+
+async function scrape(): Promise<string> {
+  const response = await request('/metrics');
+  expect(response.status).toBe(200);
+  expect(response.headers.get('content-type')).toStartWith('text/plain');
+  return response.text();
+}
+
+// Value of one sample line, e.g. sample(text, 'events_by_type_total{hook_event_type="Stop"}')
+function sample(text: string, series: string): number {
+  const line = text.split('\n').find(line => line.startsWith(`${series} `));
+  return line ? Number(line.slice(series.length + 1)) : 0;
+}
+
+test('ingesting an event increments the total and per-type counters', async () => {
+  const before = await scrape();
+  await postEvent({ session_id: 'metrics-1', hook_event_type: 'SubagentStop' });
+  const after = await scrape();
+  
+  expect(sample(after, 'events_ingested_total')).toBe(sample(before, 'events_ingested_total') + 1);
+  const byType = 'events_by_type_total{hook_event_type="SubagentStop"}';
+  expect(sample(after, byType)).toBe(sample(before, byType) + 1);
+});
+
+test('the client gauge follows connected WebSockets', async () => {
+  const client = await connectClient();
+  try {
+    const text = await scrape();
+    expect(sample(text, 'websocket_clients')).toBe(getClientCount());
+    expect(sample(text, 'websocket_clients')).toBeGreaterThanOrEqual(1);
+  } finally {
+    client.close();
+  }
+});
+
+test('request durations are recorded by route', async () => {
+  await request('/events/42');
+  const text = await scrape();
+  
+  expect(text).toContain('# TYPE http_request_duration_seconds histogram');
+  expect(sample(text, 'http_request_duration_seconds_count{method="GET",route="/events/:id",status="404"}')).toBeGreaterThanOrEqual(1);
+});
+
+test('route labels collapse ids', () => {
+  expect(routeLabel('/events/123')).toBe('/events/:id');
+  expect(routeLabel('/apps/my-app/coverage')).toBe('/apps/:sourceApp/coverage');
+  expect(routeLabel('/api/themes/abc')).toBe('/api/themes/:id');
+  expect(routeLabel('/api/themes/featured')).toBe('/api/themes/featured');
+});
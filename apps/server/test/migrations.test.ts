@@ -0,0 +1,106 @@
+import { expect, test } from 'bun:test';
+import { Database } from 'bun:sqlite';
+import { runMigrations } from '../src/migrations';
+
+function recordedVersions(db: Database): number[] {
+  return (db.prepare('SELECT version FROM schema_migrations ORDER BY version').all() as { version: number }[]).map(row => row.version);
+}
+
+function columnsOf(db: Database, table: string): string[] {
+  return (db.prepare(`PRAGMA table_info(${table})`).all() as { name: string }[]).map(column => column.name);
+}
+
+// The events table as created before any migration existed
+function createLegacyEvents(db: Database): void {
+  db.exec(`
+    CREATE TABLE events (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      source_app TEXT NOT NULL,
+      session_id TEXT NOT NULL,
+      hook_event_type TEXT NOT NULL,
+      payload TEXT NOT NULL,
+      timestamp INTEGER NOT NULL
+    )
+  `);
+  db.prepare('INSERT INTO events (source_app, session_id, hook_event_type, payload, timestamp) VALUES (?, ?, ?, ?, ?)')
+    .run('legacy-app', 'legacy-session', 'Stop', '{"reason":"done"}', 1700000000000);
+}
+
+test('a fresh database gets every migration in order, once', () => {
+  const db = new Database(':memory:');
+  
+  const applied = runMigrations(db);
+  
+  expect(applied[0]).toBe(1);
+  expect(applied).toEqual(applied.map((_, i) => i + 1));
+  expect(recordedVersions(db)).toEqual(applied);
+  expect(columnsOf(db, 'events')).toEqual(expect.arrayContaining(['is_deleted', 'event_uuid', 'payload_compressed', 'payload_hash', 'sample_rate']));
+  
+  // Idempotent: a second run applies nothing and changes nothing
+  expect(runMigrations(db)).toEqual([]);
+  expect(recordedVersions(db)).toEqual(applied);
+  db.close();
+});
+
+// The parts of the version 1 schema later migrations touch
+function createVersion1Schema(db: Database): void {
+  createLegacyEvents(db);
+  db.exec('ALTER TABLE events ADD COLUMN chat TEXT');
+  db.exec('ALTER TABLE events ADD COLUMN summary TEXT');
+  db.exec(`
+    CREATE TABLE themes (
+      id TEXT PRIMARY KEY,
+      name TEXT NOT NULL UNIQUE,
+      displayName TEXT NOT NULL,
+      description TEXT,
+      colors TEXT NOT NULL,
+      isPublic INTEGER NOT NULL DEFAULT 0,
+      authorId TEXT,
+      authorName TEXT,
+      createdAt INTEGER NOT NULL,
+      updatedAt INTEGER NOT NULL,
+      tags TEXT,
+      downloadCount INTEGER DEFAULT 0,
+      rating REAL DEFAULT 0,
+      ratingCount INTEGER DEFAULT 0
+    )
+  `);
+  db.prepare('INSERT INTO themes (id, name, displayName, colors, authorId, authorName, createdAt, updatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)')
+    .run('legacy-theme', 'legacy', 'Legacy', '{}', 'alice', 'Alice', 1, 2);
+  db.exec('CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, description TEXT NOT NULL, applied_at INTEGER NOT NULL)');
+  db.prepare('INSERT INTO schema_migrations VALUES (1, ?, ?)').run('initial schema', 0);
+}
+
+test('a database at an older version only gets the newer migrations', () => {
+  const all = runMigrations(new Database(':memory:'));
+  const db = new Database(':memory:');
+  createVersion1Schema(db);
+  
+  expect(runMigrations(db)).toEqual(all.filter(version => version > 1));
+  expect(runMigrations(db)).toEqual([]);
+  
+  // Existing rows survive and pick up the new columns' defaults
+  const event = db.prepare('SELECT * FROM events').get() as any;
+  expect(event.source_app).toBe('legacy-app');
+  expect(event.is_deleted).toBe(0);
+  expect(event.sample_rate).toBe(1);
+  expect(event.payload_hash).toBe(new Bun.CryptoHasher('sha1').update('{"reason":"done"}').digest('hex'));
+  
+  const theme = db.prepare('SELECT * FROM themes').get() as any;
+  expect(theme.id).toBe('legacy-theme');
+  expect(theme.isFeatured).toBe(0);
+  expect(db.prepare('SELECT id, name FROM authors').all()).toEqual([{ id: 'alice', name: 'Alice' }]);
+  db.close();
+});
+
+test('a database created before the runner existed is upgraded in place', () => {
+  const db = new Database(':memory:');
+  createLegacyEvents(db);
+  
+  const applied = runMigrations(db);
+  
+  expect(applied[0]).toBe(1);
+  expect(columnsOf(db, 'events')).toEqual(expect.arrayContaining(['chat', 'summary', 'is_deleted']));
+  expect((db.prepare('SELECT COUNT(*) as count FROM events').get() as any).count).toBe(1);
+  db.close();
+});
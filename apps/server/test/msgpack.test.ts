@@ -0,0 +1,116 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { encodeMsgpack, MSGPACK_CONTENT_TYPE } from '../src/msgpack';
+import { resetDatabase } from './helpers';
+import { postEvent, request } from './server';
+
+beforeEach(resetDatabase);
+
+// Just enough of a MessagePack decoder for what encodeMsgpack emits
+function decodeMsgpack(bytes: Uint8Array): any {
+  const view = new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength);
+  const text = new TextDecoder();
+  let pos = 0;
+  
+  const take = (length: number) => bytes.subarray(pos, (pos += length));
+  const str = (length: number) => text.decode(take(length));
+  const array = (length: number) => Array.from({ length }, () => read());
+  const map = (length: number) => {
+    const result: Record<string, any> = {};
+    for (let i = 0; i < length; i++) {
+      const key = read();
+      result[key] = read();
+    }
+    return result;
+  };
+  
+  function read(): any {
+    const op = view.getUint8(pos++);
+    if (op < 0x80) return op;
+    if (op >= 0xe0) return op - 0x100;
+    if ((op & 0xf0) === 0x80) return map(op & 0x0f);
+    if ((op & 0xf0) === 0x90) return array(op & 0x0f);
+    if ((op & 0xe0) === 0xa0) return str(op & 0x1f);
+    
+    let value: any;
+    switch (op) {
+      case 0xc0: return null;
+      case 0xc2: return false;
+      case 0xc3: return true;
+      case 0xc4: return take(view.getUint8(pos++)).slice();
+      case 0xcb: value = view.getFloat64(pos); pos += 8; return value;
+      case 0xcc: return view.getUint8(pos++);
+      case 0xcd: value = view.getUint16(pos); pos += 2; return value;
+      case 0xce: value = view.getUint32(pos); pos += 4; return value;
+      case 0xcf: value = Number(view.getBigUint64(pos)); pos += 8; return value;
+      case 0xd0: return view.getInt8(pos++);
+      case 0xd1: value = view.getInt16(pos); pos += 2; return value;
+      case 0xd2: value = view.getInt32(pos); pos += 4; return value;
+      case 0xd3: value = Number(view.getBigInt64(pos)); pos += 8; return value;
+      case 0xd9: return str(view.getUint8(pos++));
+      case 0xda: value = view.getUint16(pos); pos += 2; return str(value);
+      case 0xdb: value = view.getUint32(pos); pos += 4; return str(value);
+      case 0xdc: value = view.getUint16(pos); pos += 2; return array(value);
+      case 0xde: value = view.getUint16(pos); pos += 2; return map(value);
+      default: throw new Error(`Unexpected MessagePack byte 0x${op.toString(16)}`);
+    }
+  }
+  
+  const result = read();
+  expect(pos).toBe(bytes.length);
+  return result;
+}
+
+test('values decode back to their JSON equivalent', () => {
+  const value = {
+    small: 7,
+    negatives: [-1, -32, -33, -200, -40000, -3000000000],
+    unsigned: [200, 60000, 70000, 5000000000],
+    float: 1.5,
+    unsafe: 2 ** 60,
+    flags: [true, false, null],
+    skipped: undefined,
+    holes: [undefined, 1],
+    short: 'é',
+    medium: 'x'.repeat(40),
+    long: 'y'.repeat(300),
+    longer: 'z'.repeat(70000),
+    many: Array.from({ length: 20 }, (_, i) => i),
+    wide: Object.fromEntries(Array.from({ length: 20 }, (_, i) => [`k${i}`, i])),
+    date: new Date(0)
+  };
+  
+  expect(decodeMsgpack(encodeMsgpack(value))).toEqual(JSON.parse(JSON.stringify(value)));
+});
+
+test('event endpoints answer in MessagePack with the same content as JSON', async () => {
+  await postEvent({ session_id: 'msgpack-1', payload: { tool_name: 'Bash', tool_input: { command: 'ls -la' } } });
+  await postEvent({ session_id: 'msgpack-1', hook_event_type: 'PostToolUse' });
+  
+  const json = await request('/events/recent');
+  const packed = await request('/events/recent', { headers: { 'Accept': MSGPACK_CONTENT_TYPE } });
+  
+  expect(packed.headers.get('content-type')).toBe(MSGPACK_CONTENT_TYPE);
+  expect(decodeMsgpack(new Uint8Array(await packed.arrayBuffer()))).toEqual(await json.json());
+});
+
+function varyHeaders(response: Response): string[] {
+  return (response.headers.get('vary') ?? '').split(',').map(name => name.trim());
+}
+
+test('negotiated responses vary by Accept in both formats', async () => {
+  await postEvent({ session_id: 'msgpack-vary' });
+  
+  for (const path of ['/events/recent', '/events/count', '/events/1', '/events/sessions']) {
+    const json = await request(path);
+    const packed = await request(path, { headers: { 'Accept': MSGPACK_CONTENT_TYPE } });
+    expect(varyHeaders(json)).toContain('Accept');
+    expect(varyHeaders(packed)).toContain('Accept');
+  }
+});
+
+test('routes outside /events are never MessagePack', async () => {
+  const response = await request('/config', { headers: { 'Accept': MSGPACK_CONTENT_TYPE } });
+  
+  expect(response.headers.get('content-type')).toStartWith('application/json');
+  expect(varyHeaders(response)).not.toContain('Accept');
+});
@@ -0,0 +1,47 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  await insertEvents([
+    makeEvent({ source_app: 'a', session_id: 'a-1', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'a', session_id: 'a-2', hook_event_type: 'Stop' }),
+    makeEvent({ source_app: 'b', session_id: 'b-1', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'b', session_id: 'b-2', hook_event_type: 'PostToolUse' }),
+    makeEvent({ source_app: 'c', session_id: 'c-1', hook_event_type: 'PreToolUse' })
+  ]);
+});
+
+async function sessions(query: string): Promise<string[]> {
+  const response = await request(`/events?${query}`);
+  expect(response.status).toBe(200);
+  return (await response.json() as any[]).map(event => event.session_id).sort();
+}
+
+test('a single value filters as before', async () => {
+  expect(await sessions('source_app=a')).toEqual(['a-1', 'a-2']);
+});
+
+test('comma-separated values match any of them', async () => {
+  expect(await sessions('source_app=a,b')).toEqual(['a-1', 'a-2', 'b-1', 'b-2']);
+  expect(await sessions('session_id=a-1,c-1')).toEqual(['a-1', 'c-1']);
+});
+
+test('repeated parameters match any of them', async () => {
+  expect(await sessions('hook_event_type=Stop&hook_event_type=PostToolUse')).toEqual(['a-2', 'b-2']);
+});
+
+test('repeated and comma-separated forms mix', async () => {
+  expect(await sessions('source_app=a,b&source_app=c')).toHaveLength(5);
+});
+
+test('fields combine with AND, values within a field with OR', async () => {
+  expect(await sessions('source_app=a,b&hook_event_type=PreToolUse')).toEqual(['a-1', 'b-1']);
+  expect(await sessions('source_app=b,c&hook_event_type=PreToolUse,Stop&session_id=c-1,a-2')).toEqual(['c-1']);
+});
+
+test('blank entries are ignored', async () => {
+  expect(await sessions('source_app=a,,%20')).toEqual(['a-1', 'a-2']);
+});
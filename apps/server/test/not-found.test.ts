@@ -0,0 +1,31 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+test('an unknown path is a JSON 404', async () => {
+  const response = await request('/no/such/route');
+  const body = await response.json();
+  
+  expect(response.status).toBe(404);
+  expect(response.headers.get('content-type')).toStartWith('application/json');
+  expect(body.error.code).toBe('NOT_FOUND');
+  expect(response.headers.get('allow')).toBeNull();
+});
+
+test('a POST to a GET-only route is a 405 listing the allowed methods', async () => {
+  const response = await request('/events/count', { method: 'POST' });
+  const body = await response.json();
+  
+  expect(response.status).toBe(405);
+  expect(body.error.code).toBe('METHOD_NOT_ALLOWED');
+  expect(response.headers.get('allow')).toBe('GET, OPTIONS');
+});
+
+test('the Allow header covers every method of a multi-method route', async () => {
+  const response = await request('/events/1', { method: 'PUT' });
+  
+  expect(response.status).toBe(405);
+  expect(response.headers.get('allow')).toBe('GET, DELETE, OPTIONS');
+});
@@ -0,0 +1,45 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+test('only Notification events are returned, oldest first', async () => {
+  const now = Date.now();
+  await insertEvents([
+    makeEvent({ hook_event_type: 'Notification', source_app: 'app-b', timestamp: now - 1000, payload: { message: 'second' } }),
+    makeEvent({ hook_event_type: 'PreToolUse', timestamp: now - 1500 }),
+    makeEvent({ hook_event_type: 'Notification', source_app: 'app-a', timestamp: now - 2000, payload: { message: 'first' } }),
+    makeEvent({ hook_event_type: 'Stop', timestamp: now - 500 })
+  ]);
+  
+  const response = await request('/events/notifications');
+  const events = await response.json() as any[];
+  
+  expect(response.status).toBe(200);
+  expect(events.map(event => event.hook_event_type)).toEqual(['Notification', 'Notification']);
+  expect(events.map(event => event.source_app)).toEqual(['app-a', 'app-b']);
+  expect(events[0].payload).toEqual({ message: 'first' });
+});
+
+test('start and end bound the time range', async () => {
+  const now = Date.now();
+  await insertEvents([
+    makeEvent({ hook_event_type: 'Notification', timestamp: now - 3000, payload: { message: 'before' } }),
+    makeEvent({ hook_event_type: 'Notification', timestamp: now - 2000, payload: { message: 'inside' } }),
+    makeEvent({ hook_event_type: 'Notification', timestamp: now - 1000, payload: { message: 'after' } })
+  ]);
+  
+  const events = await (await request(`/events/notifications?start=${now - 2500}&end=${now - 1500}`)).json() as any[];
+  
+  expect(events.map(event => event.payload.message)).toEqual(['inside']);
+});
+
+test('non-numeric bounds are rejected', async () => {
+  const response = await request('/events/notifications?start=yesterday');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(400);
+  expect(body.error.code).toBe('INVALID_PARAMETER');
+});
@@ -0,0 +1,50 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getRecentEvents } from '../src/db';
+import { searchThemes } from '../src/theme';
+import { makeEvent, makeTheme, resetDatabase, seedEvents, setConfig } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+test('/config exposes the page sizes and stream settings', async () => {
+  setConfig({ DEFAULT_PAGE_SIZE: 25, MAX_PAGE_SIZE: 200, WS_HEARTBEAT_INTERVAL: 15000 });
+  
+  const response = await request('/config');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(body.defaultPageSize).toBe(25);
+  expect(body.maxPageSize).toBe(200);
+  expect(body.wsHeartbeatIntervalMs).toBe(15000);
+});
+
+test('/config never reveals secrets or paths', async () => {
+  setConfig({ JWT_SECRET: 'top-secret', API_KEY: 'admin-secret' });
+  
+  const text = await (await request('/config')).text();
+  
+  expect(text).not.toContain('top-secret');
+  expect(text).not.toContain('admin-secret');
+  expect(text).not.toContain(':memory:');
+  expect(JSON.parse(text).authEnabled).toBe(true);
+});
+
+test('a default larger than the maximum is reported as the maximum', async () => {
+  setConfig({ DEFAULT_PAGE_SIZE: 500, MAX_PAGE_SIZE: 100 });
+  
+  expect((await (await request('/config')).json() as any).defaultPageSize).toBe(100);
+});
+
+test('event and theme listings honor the configured default', async () => {
+  setConfig({ DEFAULT_PAGE_SIZE: 2 });
+  await seedEvents(Array.from({ length: 5 }, () => makeEvent()));
+  for (let i = 0; i < 3; i++) {
+    await requestJson('/api/themes', 'POST', makeTheme({ name: `paged-${i}` }));
+  }
+  
+  expect(await (await request('/events/recent')).json()).toHaveLength(2);
+  expect((await (await request('/api/themes')).json() as any).data).toHaveLength(2);
+  // The data layer reads the same default
+  expect(getRecentEvents()).toHaveLength(2);
+  expect((await searchThemes({})).data).toHaveLength(2);
+});
@@ -0,0 +1,66 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getEventById } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { requestJson } from './server';
+
+const LIMIT = 100;
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ MAX_PAYLOAD_BYTES: LIMIT });
+});
+
+// A payload whose JSON encoding is exactly `size` bytes
+function payloadOfSize(size: number): Record<string, string> {
+  return { text: 'x'.repeat(size - JSON.stringify({ text: '' }).length) };
+}
+
+function post(size: number): Promise<Response> {
+  return requestJson('/events', 'POST', makeEvent({ payload: payloadOfSize(size) }));
+}
+
+test('reject: a payload at the limit is stored unchanged', async () => {
+  setConfig({ PAYLOAD_OVERFLOW_POLICY: 'reject' });
+  
+  const response = await post(LIMIT);
+  
+  expect(response.status).toBe(200);
+  expect((await response.json() as any).payload).toEqual(payloadOfSize(LIMIT));
+});
+
+test('reject: one byte over the limit is a 422', async () => {
+  setConfig({ PAYLOAD_OVERFLOW_POLICY: 'reject' });
+  
+  const response = await post(LIMIT + 1);
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(422);
+  expect(body.error.code).toBe('EVENT_INVALID');
+  expect(body.error.details.validationErrors).toContainEqual(expect.objectContaining({ field: 'payload', code: 'TOO_LARGE' }));
+});
+
+test('truncate: a payload at the limit is stored unchanged', async () => {
+  setConfig({ PAYLOAD_OVERFLOW_POLICY: 'truncate' });
+  
+  const response = await post(LIMIT);
+  
+  expect(response.status).toBe(200);
+  expect((await response.json() as any).payload).toEqual(payloadOfSize(LIMIT));
+});
+
+test('truncate: one byte over the limit is stored as a marker', async () => {
+  setConfig({ PAYLOAD_OVERFLOW_POLICY: 'truncate' });
+  
+  const response = await post(LIMIT + 1);
+  const event = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(event.payload).toEqual({ _truncated: true, _originalSize: LIMIT + 1 });
+  expect(getEventById(event.id)!.payload).toEqual({ _truncated: true, _originalSize: LIMIT + 1 });
+});
+
+test('a zero limit accepts any size', async () => {
+  setConfig({ MAX_PAYLOAD_BYTES: 0, PAYLOAD_OVERFLOW_POLICY: 'reject' });
+  
+  expect((await post(100_000)).status).toBe(200);
+});
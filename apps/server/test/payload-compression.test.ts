@@ -0,0 +1,74 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { Database } from 'bun:sqlite';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { closeDatabase, getEventById, initDatabase, insertEvent, payloadFiltersSupported } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { request } from './server';
+
+let dir: string;
+let path: string;
+
+beforeEach(() => {
+  dir = mkdtempSync(join(tmpdir(), 'payload-compression-'));
+  path = join(dir, 'events.db');
+  closeDatabase();
+  initDatabase(path);
+});
+
+afterEach(() => {
+  resetDatabase();
+  rmSync(dir, { recursive: true, force: true });
+});
+
+const payload = { tool_name: 'Bash', tool_input: { command: 'cat README.md' }, output: 'line\n'.repeat(200) };
+
+// The stored column values, read through a separate connection
+function storedRow(id: number): { payload: string | Uint8Array; payload_compressed: number } {
+  const raw = new Database(path, { readonly: true });
+  try {
+    return raw.prepare('SELECT payload, payload_compressed FROM events WHERE id = ?').get(id) as any;
+  } finally {
+    raw.close();
+  }
+}
+
+test('with COMPRESS_PAYLOADS on, payloads are stored gzipped and read back intact', async () => {
+  setConfig({ COMPRESS_PAYLOADS: true });
+  
+  const { event } = await insertEvent(makeEvent({ payload }));
+  const row = storedRow(event.id!);
+  
+  expect(row.payload_compressed).toBe(1);
+  expect(row.payload).toBeInstanceOf(Uint8Array);
+  expect((row.payload as Uint8Array).byteLength).toBeLessThan(JSON.stringify(payload).length);
+  expect(getEventById(event.id!)!.payload).toEqual(payload);
+});
+
+test('legacy plain rows and compressed rows are read side by side', async () => {
+  setConfig({ COMPRESS_PAYLOADS: false });
+  const legacy = (await insertEvent(makeEvent({ session_id: 'legacy', payload }))).event;
+  setConfig({ COMPRESS_PAYLOADS: true });
+  const compressed = (await insertEvent(makeEvent({ session_id: 'compressed', payload }))).event;
+  
+  expect(storedRow(legacy.id!)).toEqual({ payload: JSON.stringify(payload), payload_compressed: 0 });
+  expect(storedRow(compressed.id!).payload_compressed).toBe(1);
+  
+  const events = await (await request('/events/recent?limit=10')).json() as any[];
+  expect(events.map(event => event.session_id).sort()).toEqual(['compressed', 'legacy']);
+  for (const event of events) {
+    expect(event.payload).toEqual(payload);
+  }
+});
+
+test('compressed rows found on reopen disable SQL payload filters', async () => {
+  setConfig({ COMPRESS_PAYLOADS: true });
+  await insertEvent(makeEvent({ payload }));
+  setConfig({ COMPRESS_PAYLOADS: false });
+  
+  closeDatabase();
+  initDatabase(path);
+  
+  expect(payloadFiltersSupported()).toBe(false);
+});
@@ -0,0 +1,63 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  await insertEvents([
+    makeEvent({ session_id: 'bash-ls', payload: { tool_name: 'Bash', tool_input: { command: 'ls', timeout: 30 } } }),
+    makeEvent({ session_id: 'bash-rm', payload: { tool_name: 'Bash', tool_input: { command: 'rm -rf build', timeout: 60 } } }),
+    makeEvent({ session_id: 'read', payload: { tool_name: 'Read', tool_input: { file_path: '/etc/hosts' }, cached: true } }),
+    makeEvent({ session_id: 'quote', payload: { tool_name: "Bash'; DROP TABLE events; --" } })
+  ]);
+});
+
+async function sessionsMatching(query: string): Promise<string[]> {
+  const response = await request(`/events?${query}`);
+  expect(response.status).toBe(200);
+  return (await response.json() as any[]).map(event => event.session_id).sort();
+}
+
+test('a top-level field is matched exactly', async () => {
+  expect(await sessionsMatching('payload.tool_name=Bash')).toEqual(['bash-ls', 'bash-rm']);
+});
+
+test('nested fields are reached with dotted paths', async () => {
+  expect(await sessionsMatching('payload.tool_input.command=ls')).toEqual(['bash-ls']);
+  expect(await sessionsMatching(`payload.tool_input.file_path=${encodeURIComponent('/etc/hosts')}`)).toEqual(['read']);
+});
+
+test('numbers and booleans match their typed JSON values', async () => {
+  expect(await sessionsMatching('payload.tool_input.timeout=60')).toEqual(['bash-rm']);
+  expect(await sessionsMatching('payload.cached=true')).toEqual(['read']);
+});
+
+test('several payload filters combine with AND', async () => {
+  expect(await sessionsMatching('payload.tool_name=Bash&payload.tool_input.timeout=30')).toEqual(['bash-ls']);
+  expect(await sessionsMatching('payload.tool_name=Read&payload.tool_input.timeout=30')).toEqual([]);
+});
+
+test('values are bound, never spliced into SQL', async () => {
+  const value = encodeURIComponent("Bash'; DROP TABLE events; --");
+  
+  expect(await sessionsMatching(`payload.tool_name=${value}`)).toEqual(['quote']);
+  expect(await sessionsMatching('')).toHaveLength(4);
+});
+
+test('paths other than dotted identifiers are rejected', async () => {
+  for (const path of ["tool_name')--", 'tool_input[0]', 'a..b', '$.tool_name']) {
+    const response = await request(`/events?payload.${encodeURIComponent(path)}=x`);
+    expect(response.status).toBe(400);
+    expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+  }
+});
+
+test('payload filters are refused while payloads are stored compressed', async () => {
+  setConfig({ COMPRESS_PAYLOADS: true });
+  
+  const response = await request('/events?payload.tool_name=Bash');
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.message).toContain('COMPRESS_PAYLOADS');
+});
@@ -0,0 +1,44 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase, setConfig } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+test('debug endpoints are absent when disabled', async () => {
+  setConfig({ ENABLE_PPROF: false });
+  
+  for (const path of ['/debug/pprof', '/debug/pprof/heap']) {
+    const response = await request(path);
+    expect(response.status).toBe(404);
+    expect((await response.json() as any).error.code).toBe('NOT_FOUND');
+  }
+});
+
+test('the summary is served when enabled', async () => {
+  setConfig({ ENABLE_PPROF: true });
+  
+  const response = await request('/debug/pprof');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(body.heap.objectCount).toBeGreaterThan(0);
+  expect(body.memory.rss).toBeGreaterThan(0);
+  expect(typeof body.websocketClients).toBe('number');
+  expect(typeof body.ingestQueueDepth).toBe('number');
+});
+
+test('the heap snapshot downloads when enabled', async () => {
+  setConfig({ ENABLE_PPROF: true });
+  
+  const response = await request('/debug/pprof/heap');
+  
+  expect(response.status).toBe(200);
+  expect(response.headers.get('content-disposition')).toMatch(/^attachment; filename="heap-\d+\.heapsnapshot"$/);
+  expect(await response.json()).toHaveProperty('nodes');
+});
+
+test('unknown paths under the prefix stay 404 when enabled', async () => {
+  setConfig({ ENABLE_PPROF: true });
+  
+  expect((await request('/debug/pprof/goroutine')).status).toBe(404);
+});
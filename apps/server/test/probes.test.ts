@@ -0,0 +1,63 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { closeDatabase } from '../src/db';
+import { setDraining } from '../src/index';
+import { resetDatabase, setConfig } from './helpers';
+import { request, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+afterEach(() => {
+  setDraining(false);
+  resetDatabase();
+});
+
+async function readyz(): Promise<{ status: number; body: any }> {
+  const response = await request('/readyz');
+  return { status: response.status, body: await response.json() };
+}
+
+test('livez answers 200 while the process is up', async () => {
+  const response = await request('/livez');
+  
+  expect(response.status).toBe(200);
+  expect(await response.text()).toBe('ok');
+});
+
+test('readyz is ready with a healthy database and heartbeat', async () => {
+  const { status, body } = await readyz();
+  
+  expect(status).toBe(200);
+  expect(body).toEqual({ status: 'ready', checks: { database: 'ok', websocket: 'ok', draining: false } });
+});
+
+test('readyz fails while draining, but livez stays up', async () => {
+  setDraining(true);
+  
+  const { status, body } = await readyz();
+  
+  expect(status).toBe(503);
+  expect(body.status).toBe('not_ready');
+  expect(body.checks.draining).toBe(true);
+  expect((await request('/livez')).status).toBe(200);
+});
+
+test('readyz fails when the database is unreachable', async () => {
+  closeDatabase();
+  
+  const { status, body } = await readyz();
+  
+  expect(status).toBe(503);
+  expect(body.checks.database).toBe('fail');
+  expect((await request('/livez')).status).toBe(200);
+});
+
+test('readyz fails when the heartbeat sweep has stalled', async () => {
+  // The last sweep now looks overdue
+  setConfig({ WS_HEARTBEAT_INTERVAL: 1 });
+  await sleep(10);
+  
+  const { status, body } = await readyz();
+  
+  expect(status).toBe(503);
+  expect(body.checks.websocket).toBe('fail');
+});
@@ -0,0 +1,108 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { countEvents } from '../src/db';
+import { EventRejectedError } from '../src/errors';
+import { noopProcessor, registerEventProcessor, runEventProcessors } from '../src/processors';
+import type { EventProcessor } from '../src/processors';
+import { makeEvent, resetDatabase } from './helpers';
+import { postEvent, requestJson } from './server';
+
+// The registry is process-wide, so every test removes what it registered
+const registered: (() => void)[] = [];
+
+function use(...processors: EventProcessor[]): void {
+  for (const processor of processors) {
+    registered.push(registerEventProcessor(processor));
+  }
+}
+
+beforeEach(resetDatabase);
+
+afterEach(() => {
+  registered.splice(0).forEach(unregister => unregister());
+});
+
+const enrich: EventProcessor = {
+  name: 'enrich',
+  process(event) {
+    event.payload = { ...event.payload, team: 'platform' };
+  }
+};
+
+test('a processor can enrich the payload before it is stored', async () => {
+  use(enrich);
+  
+  const event = await postEvent({ session_id: 'processors-enrich' });
+  
+  expect(event.payload).toMatchObject({ tool_name: 'Bash', team: 'platform' });
+});
+
+test('processors run in registration order and may return a replacement', async () => {
+  use(enrich, {
+    name: 'tag-by-team',
+    process: event => ({ ...event, source_app: `${event.source_app}-${event.payload.team}` })
+  });
+  
+  const event = await postEvent({ session_id: 'processors-order' });
+  
+  expect(event.source_app).toBe('test-app-platform');
+});
+
+test('a rejected event is answered with 422 naming the processor and is not stored', async () => {
+  use({
+    name: 'no-rm',
+    process(event) {
+      if (String(event.payload.tool_input?.command).startsWith('rm ')) {
+        throw new EventRejectedError('Destructive commands are not recorded');
+      }
+    }
+  });
+  
+  const response = await requestJson('/events', 'POST', makeEvent({ payload: { tool_input: { command: 'rm -rf /tmp/x' } } }));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(422);
+  expect(body.error).toEqual({
+    code: 'EVENT_REJECTED',
+    message: 'Destructive commands are not recorded',
+    details: { processor: 'no-rm' }
+  });
+  expect(countEvents()).toBe(0);
+  
+  expect((await requestJson('/events', 'POST', makeEvent())).ok).toBe(true);
+});
+
+test('an event a processor broke is rejected as invalid', async () => {
+  use({ name: 'breaker', process: event => ({ ...event, session_id: '' }) });
+  
+  const response = await requestJson('/events', 'POST', makeEvent());
+  
+  expect(response.status).toBe(422);
+  expect((await response.json() as any).error.code).toBe('EVENT_INVALID');
+  expect(countEvents()).toBe(0);
+});
+
+test('an unexpected processor failure is a 500', async () => {
+  use({ name: 'buggy', process: () => { throw new TypeError('oops'); } });
+  
+  const response = await requestJson('/events', 'POST', makeEvent());
+  
+  expect(response.status).toBe(500);
+  expect((await response.json() as any).error.code).toBe('INTERNAL');
+  expect(countEvents()).toBe(0);
+});
+
+test('the no-op processor leaves events unchanged', async () => {
+  use(noopProcessor);
+  const event = makeEvent({ session_id: 'processors-noop' });
+  
+  expect(await runEventProcessors(structuredClone(event))).toEqual(event);
+});
+
+test('unregistering a processor stops it running', async () => {
+  const unregister = registerEventProcessor(enrich);
+  unregister();
+  
+  const event = await postEvent({ session_id: 'processors-removed' });
+  
+  expect(event.payload.team).toBeUndefined();
+});
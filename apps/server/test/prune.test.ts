@@ -0,0 +1,62 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { countEvents, getEventById, insertEvents } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeEvent, resetDatabase, setConfig } from './helpers';
+import { requestJson } from './server';
+import type { HookEvent } from '../src/types';
+
+const base = Date.now() - 60_000;
+let events: HookEvent[];
+
+beforeEach(async () => {
+  resetDatabase();
+  setConfig({ API_KEY: ADMIN_KEY });
+  // Five events one second apart, oldest first
+  const inserted = await insertEvents(Array.from({ length: 5 }, (_, i) => makeEvent({ session_id: `prune-${i}`, timestamp: base + i * 1000 })));
+  events = inserted.map(result => result.event);
+});
+
+function prune(body: unknown, headers: Record<string, string> = adminHeaders): Promise<Response> {
+  return requestJson('/admin/events/prune', 'POST', body, headers);
+}
+
+test('before deletes strictly older events and reports the count', async () => {
+  const response = await prune({ before: base + 2000 });
+  
+  expect(response.status).toBe(200);
+  expect(await response.json()).toEqual({ deleted: 2 });
+  expect(countEvents()).toBe(3);
+  expect(getEventById(events[1]!.id!)).toBeNull();
+  expect(getEventById(events[2]!.id!)).not.toBeNull();
+});
+
+test('keepLast keeps only the newest events', async () => {
+  const response = await prune({ keepLast: 2 });
+  
+  expect(await response.json()).toEqual({ deleted: 3 });
+  expect(countEvents()).toBe(2);
+  expect(getEventById(events[3]!.id!)).not.toBeNull();
+  expect(getEventById(events[4]!.id!)).not.toBeNull();
+});
+
+test('keepLast larger than the table deletes nothing', async () => {
+  expect(await (await prune({ keepLast: 10 })).json()).toEqual({ deleted: 0 });
+  expect(countEvents()).toBe(5);
+});
+
+test('exactly one of before or keepLast is required', async () => {
+  for (const body of [{}, { before: base, keepLast: 1 }, { keepLast: -1 }, { keepLast: 1.5 }, { before: 'yesterday' }]) {
+    const response = await prune(body);
+    expect(response.status).toBe(400);
+    expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+  }
+  expect(countEvents()).toBe(5);
+});
+
+test('the endpoint needs the admin key', async () => {
+  const missing = await prune({ keepLast: 0 }, {});
+  const wrong = await prune({ keepLast: 0 }, { 'X-API-Key': 'not-the-key' });
+  
+  expect(missing.status).toBe(401);
+  expect(wrong.status).toBe(401);
+  expect(countEvents()).toBe(5);
+});
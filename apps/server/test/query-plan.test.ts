@@ -0,0 +1,29 @@
+import { expect, test } from 'bun:test';
+import { Database } from 'bun:sqlite';
+import { runMigrations } from '../src/migrations';
+
+function planOf(db: Database, sql: string, ...params: (string | number)[]): string {
+  return (db.prepare(`EXPLAIN QUERY PLAN ${sql}`).all(...params) as { detail: string }[])
+    .map(row => row.detail)
+    .join('\n');
+}
+
+test('source app listings walk the (source_app, timestamp) index without sorting', () => {
+  const db = new Database(':memory:');
+  runMigrations(db);
+  
+  const plan = planOf(db, 'SELECT id FROM events WHERE is_deleted = 0 AND source_app = ? ORDER BY timestamp DESC LIMIT ? OFFSET ?', 'app', 50, 0);
+  
+  expect(plan).toContain('USING INDEX idx_source_app_timestamp');
+  expect(plan).not.toContain('TEMP B-TREE');
+});
+
+test('session listings walk the (session_id, timestamp) index without sorting', () => {
+  const db = new Database(':memory:');
+  runMigrations(db);
+  
+  const plan = planOf(db, 'SELECT id FROM events WHERE is_deleted = 0 AND session_id = ? AND timestamp >= ? ORDER BY timestamp ASC, id ASC LIMIT ? OFFSET ?', 'session', 0, 50, 0);
+  
+  expect(plan).toContain('USING INDEX idx_session_id_timestamp');
+  expect(plan).not.toContain('TEMP B-TREE');
+});
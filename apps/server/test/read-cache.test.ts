@@ -0,0 +1,63 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { cached, invalidateCache } from '../src/cache';
+import { makeEvent, resetDatabase, seedEvents, setConfig } from './helpers';
+import { postEvent, request } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  invalidateCache('');
+});
+
+test('a second call within the TTL is served without recomputing', async () => {
+  setConfig({ READ_CACHE_TTL_MS: 1000 });
+  let calls = 0;
+  const compute = () => ++calls;
+  
+  expect(await cached('cache-test:hit', compute)).toEqual([1, false]);
+  expect(await cached('cache-test:hit', compute)).toEqual([1, true]);
+  expect(calls).toBe(1);
+});
+
+test('entries expire after the TTL and are never kept when disabled', async () => {
+  setConfig({ READ_CACHE_TTL_MS: 20 });
+  let calls = 0;
+  const compute = () => ++calls;
+  
+  await cached('cache-test:expiry', compute);
+  await Bun.sleep(40);
+  expect(await cached('cache-test:expiry', compute)).toEqual([2, false]);
+  
+  setConfig({ READ_CACHE_TTL_MS: 0 });
+  await cached('cache-test:disabled', compute);
+  expect(await cached('cache-test:disabled', compute)).toEqual([4, false]);
+});
+
+test('cached endpoints report HIT and MISS, and writes invalidate them', async () => {
+  setConfig({ READ_CACHE_TTL_MS: 60000 });
+  await postEvent({ session_id: 'cache-1' });
+  
+  const first = await request('/events/count');
+  expect(first.headers.get('x-cache')).toBe('MISS');
+  expect(await first.json()).toEqual({ count: 1 });
+  
+  // Bypassing the API leaves the cached count stale until the TTL runs out
+  await seedEvents([makeEvent({ session_id: 'cache-1' })]);
+  const second = await request('/events/count');
+  expect(second.headers.get('x-cache')).toBe('HIT');
+  expect(await second.json()).toEqual({ count: 1 });
+  
+  // Ingesting through the API invalidates event caches
+  await postEvent({ session_id: 'cache-1' });
+  const third = await request('/events/count');
+  expect(third.headers.get('x-cache')).toBe('MISS');
+  expect(await third.json()).toEqual({ count: 3 });
+});
+
+test('theme stats and filter options are cached too', async () => {
+  setConfig({ READ_CACHE_TTL_MS: 60000 });
+  
+  for (const path of ['/api/themes/stats', '/events/filter-options']) {
+    expect((await request(path)).headers.get('x-cache')).toBe('MISS');
+    expect((await request(path)).headers.get('x-cache')).toBe('HIT');
+  }
+});
@@ -0,0 +1,78 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { ADMIN_KEY, adminHeaders, makeEvent, makeTheme, resetDatabase, setConfig } from './helpers';
+import { postEvent, request, requestJson } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ API_KEY: ADMIN_KEY });
+});
+
+function setReadOnly(enabled: unknown): Promise<Response> {
+  return requestJson('/admin/read-only', 'PUT', { enabled }, adminHeaders);
+}
+
+// Run body in read-only mode, switching it back off even when body fails
+async function whileReadOnly(body: () => Promise<void>): Promise<void> {
+  expect((await setReadOnly(true)).status).toBe(200);
+  try {
+    await body();
+  } finally {
+    await setReadOnly(false);
+  }
+}
+
+test('writes are rejected with 503 and a maintenance error', async () => {
+  const event = await postEvent({ session_id: 'read-only-1' });
+  
+  await whileReadOnly(async () => {
+    const writes = [
+      await requestJson('/events', 'POST', makeEvent({ session_id: 'read-only-1' })),
+      await requestJson(`/events/${event.id}/summary`, 'PATCH', { summary: 'blocked' }),
+      await request(`/events/${event.id}`, { method: 'DELETE' }),
+      await requestJson('/api/themes', 'POST', makeTheme({ name: 'read-only-theme' }))
+    ];
+    
+    for (const response of writes) {
+      expect(response.status).toBe(503);
+      expect(response.headers.get('retry-after')).toBe('60');
+      expect((await response.json() as any).error.code).toBe('READ_ONLY');
+    }
+  });
+  
+  const stored = await (await request('/events?session_id=read-only-1')).json() as any[];
+  expect(stored).toHaveLength(1);
+  expect(stored[0].summary).toBeUndefined();
+});
+
+test('reads keep working', async () => {
+  const event = await postEvent({ session_id: 'read-only-2' });
+  
+  await whileReadOnly(async () => {
+    expect((await request('/events/recent')).status).toBe(200);
+    expect((await request(`/events/${event.id}`)).status).toBe(200);
+    expect((await request('/api/themes')).status).toBe(200);
+    expect((await request('/health')).status).toBe(200);
+  });
+});
+
+test('the toggle and the dry-run validator stay reachable', async () => {
+  await whileReadOnly(async () => {
+    const state = await (await request('/admin/read-only', { headers: adminHeaders })).json() as any;
+    expect(state.readOnly).toBe(true);
+    expect((await requestJson('/events/validate', 'POST', makeEvent())).status).not.toBe(503);
+  });
+  
+  expect(((await (await request('/admin/read-only', { headers: adminHeaders })).json()) as any).readOnly).toBe(false);
+  expect((await requestJson('/events', 'POST', makeEvent({ session_id: 'read-only-3' }))).ok).toBe(true);
+});
+
+test('toggling requires the admin key and a boolean', async () => {
+  const anonymous = await requestJson('/admin/read-only', 'PUT', { enabled: true });
+  expect(anonymous.status).toBe(401);
+  
+  const invalid = await setReadOnly('yes');
+  expect(invalid.status).toBe(400);
+  
+  const state = await (await request('/admin/read-only', { headers: adminHeaders })).json() as any;
+  expect(state.readOnly).toBe(false);
+});
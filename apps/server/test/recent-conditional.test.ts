@@ -0,0 +1,94 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+// Whole seconds in the past, so Last-Modified is sent and round-trips exactly
+let newest: number;
+let newestId: number;
+
+beforeEach(async () => {
+  resetDatabase();
+  newest = Math.floor(Date.now() / 1000) * 1000 - 10_000;
+  const saved = await insertEvents([
+    makeEvent({ session_id: 'conditional-1', timestamp: newest - 5000 }),
+    makeEvent({ session_id: 'conditional-1', timestamp: newest })
+  ]);
+  newestId = saved[1]!.event.id!;
+});
+
+function recent(headers: Record<string, string> = {}): Promise<Response> {
+  return request('/events/recent', { headers });
+}
+
+async function addNewerEvent(): Promise<number> {
+  const [saved] = await insertEvents([makeEvent({ session_id: 'conditional-1', timestamp: newest + 3000 })]);
+  return saved!.event.id!;
+}
+
+test('responses carry a weak ETag of the newest id and Last-Modified of its timestamp', async () => {
+  const response = await recent();
+  
+  expect(response.status).toBe(200);
+  expect(response.headers.get('etag')).toBe(`W/"${newestId}"`);
+  expect(response.headers.get('last-modified')).toBe(new Date(newest).toUTCString());
+});
+
+test('If-Modified-Since gets 304 until a newer event arrives', async () => {
+  const lastModified = (await recent()).headers.get('last-modified')!;
+  
+  const unchanged = await recent({ 'If-Modified-Since': lastModified });
+  expect(unchanged.status).toBe(304);
+  expect(await unchanged.text()).toBe('');
+  expect(unchanged.headers.get('last-modified')).toBe(lastModified);
+  
+  await addNewerEvent();
+  const changed = await recent({ 'If-Modified-Since': lastModified });
+  expect(changed.status).toBe(200);
+  expect(changed.headers.get('last-modified')).toBe(new Date(newest + 3000).toUTCString());
+  expect(await changed.json()).toHaveLength(3);
+});
+
+test('If-None-Match gets 304 until a newer event arrives', async () => {
+  const etag = (await recent()).headers.get('etag')!;
+  
+  expect((await recent({ 'If-None-Match': etag })).status).toBe(304);
+  // Weak comparison: the strong form of the same tag also matches
+  expect((await recent({ 'If-None-Match': `"${newestId}"` })).status).toBe(304);
+  
+  const newId = await addNewerEvent();
+  const changed = await recent({ 'If-None-Match': etag });
+  expect(changed.status).toBe(200);
+  expect(changed.headers.get('etag')).toBe(`W/"${newId}"`);
+});
+
+test('If-None-Match takes precedence over If-Modified-Since', async () => {
+  const fresh = await recent();
+  const etag = fresh.headers.get('etag')!;
+  const lastModified = fresh.headers.get('last-modified')!;
+  
+  // A stale tag wins over a current date...
+  expect((await recent({ 'If-None-Match': 'W/"stale"', 'If-Modified-Since': lastModified })).status).toBe(200);
+  // ...and a current tag wins over a stale date
+  expect((await recent({ 'If-None-Match': etag, 'If-Modified-Since': new Date(0).toUTCString() })).status).toBe(304);
+});
+
+test('Last-Modified is withheld while the newest event\'s second is still running', async () => {
+  await insertEvents([makeEvent({ session_id: 'conditional-1', timestamp: Date.now() + 5000 })]);
+  
+  const response = await recent();
+  
+  expect(response.headers.get('last-modified')).toBeNull();
+  // Without a date to compare, If-Modified-Since alone never yields 304
+  expect((await recent({ 'If-Modified-Since': new Date().toUTCString() })).status).toBe(200);
+});
+
+test('an empty database still has a validator', async () => {
+  resetDatabase();
+  
+  const response = await recent();
+  
+  expect(response.headers.get('etag')).toBe('W/"0"');
+  expect(response.headers.get('last-modified')).toBeNull();
+  expect((await recent({ 'If-None-Match': 'W/"0"' })).status).toBe(304);
+});
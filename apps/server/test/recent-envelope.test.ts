@@ -0,0 +1,50 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  const now = Date.now();
+  await seedEvents(Array.from({ length: 5 }, (_, i) => makeEvent({ timestamp: now - 5000 + i, payload: { n: i } })));
+});
+
+async function getPage(query: string): Promise<any> {
+  const response = await request(`/events/recent?envelope=true&${query}`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('first page', async () => {
+  const page = await getPage('limit=2&offset=0');
+  
+  expect(page.data.map((event: any) => event.payload.n)).toEqual([3, 4]);
+  expect(page).toMatchObject({ total: 5, limit: 2, offset: 0, hasMore: true });
+});
+
+test('middle page', async () => {
+  const page = await getPage('limit=2&offset=2');
+  
+  expect(page.data.map((event: any) => event.payload.n)).toEqual([1, 2]);
+  expect(page).toMatchObject({ total: 5, limit: 2, offset: 2, hasMore: true });
+});
+
+test('last page', async () => {
+  const page = await getPage('limit=2&offset=4');
+  
+  expect(page.data.map((event: any) => event.payload.n)).toEqual([0]);
+  expect(page).toMatchObject({ total: 5, limit: 2, offset: 4, hasMore: false });
+});
+
+test('a page ending exactly at the total has no more', async () => {
+  const page = await getPage('limit=5&offset=0');
+  
+  expect(page.data).toHaveLength(5);
+  expect(page.hasMore).toBe(false);
+});
+
+test('without envelope=true the bare array is kept', async () => {
+  const body = await (await request('/events/recent?limit=2')).json();
+  
+  expect(Array.isArray(body)).toBe(true);
+  expect(body).toHaveLength(2);
+});
@@ -0,0 +1,88 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { redactPayload } from '../src/event';
+import { resetDatabase, setConfig } from './helpers';
+import { connectClient, postEvent, request } from './server';
+
+beforeEach(resetDatabase);
+
+const payload = {
+  tool_name: 'Bash',
+  tool_input: {
+    command: 'deploy',
+    env: { API_TOKEN: 'tok-123', HOME: '/root' },
+    headers: [{ authorization: 'Bearer abc' }, { accept: 'json' }]
+  },
+  password: { hash: 'x', salt: 'y' }
+};
+
+const redacted = {
+  tool_name: 'Bash',
+  tool_input: {
+    command: 'deploy',
+    env: { API_TOKEN: '***', HOME: '/root' },
+    headers: [{ authorization: '***' }, { accept: 'json' }]
+  },
+  password: '***'
+};
+
+const KEYS = ['api_token', 'authorization', 'password'];
+
+test('matching keys are masked at any depth, case-insensitively', () => {
+  setConfig({ REDACT_KEYS: KEYS, REDACT_MODE: 'write' });
+  
+  expect(redactPayload(payload, 'write')).toEqual(redacted);
+  // The input is not modified
+  expect(payload.tool_input.env.API_TOKEN).toBe('tok-123');
+});
+
+test('nothing is masked without keys or at the other stage', () => {
+  setConfig({ REDACT_KEYS: [], REDACT_MODE: 'write' });
+  expect(redactPayload(payload, 'write')).toBe(payload);
+  
+  setConfig({ REDACT_KEYS: KEYS, REDACT_MODE: 'write' });
+  expect(redactPayload(payload, 'read')).toBe(payload);
+});
+
+async function stored(id: number | undefined): Promise<any> {
+  return (await (await request(`/events/${id}`)).json() as any).payload;
+}
+
+test('write mode masks before storage, so the secret is gone for good', async () => {
+  setConfig({ REDACT_KEYS: KEYS, REDACT_MODE: 'write' });
+  const event = await postEvent({ session_id: 'redact-write', payload });
+  
+  expect(event.payload).toEqual(redacted);
+  expect(await stored(event.id)).toEqual(redacted);
+  
+  setConfig({ REDACT_KEYS: [] });
+  expect(await stored(event.id)).toEqual(redacted);
+});
+
+test('read mode stores the original and masks every response', async () => {
+  setConfig({ REDACT_KEYS: KEYS, REDACT_MODE: 'read' });
+  const client = await connectClient();
+  try {
+    const event = await postEvent({ session_id: 'redact-read', payload });
+    const frame = await client.next('event');
+    
+    expect(event.payload).toEqual(redacted);
+    expect(frame.data.payload).toEqual(redacted);
+    expect(await stored(event.id)).toEqual(redacted);
+    
+    setConfig({ REDACT_KEYS: [] });
+    expect(await stored(event.id)).toEqual(payload);
+  } finally {
+    client.close();
+  }
+});
+
+test('read mode refuses filters on masked fields', async () => {
+  setConfig({ REDACT_KEYS: KEYS, REDACT_MODE: 'read' });
+  await postEvent({ session_id: 'redact-filter', payload });
+  
+  const response = await request('/events?payload.tool_input.env.API_TOKEN=tok-123');
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+  expect((await request('/events?payload.tool_input.command=deploy')).status).toBe(200);
+});
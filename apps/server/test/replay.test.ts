@@ -0,0 +1,120 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { connectClient, sleep } from './server';
+import type { TestClient } from './server';
+
+beforeEach(resetDatabase);
+
+// Store a session whose events are gapMs apart, oldest first
+async function seedSession(sessionId: string, count: number, gapMs: number): Promise<number[]> {
+  const start = Date.now() - 60_000;
+  const saved = await insertEvents(Array.from({ length: count }, (_, i) => makeEvent({
+    session_id: sessionId,
+    timestamp: start + i * gapMs,
+    payload: { n: i }
+  })));
+  return saved.map(result => result!.event.id!);
+}
+
+function replay(client: TestClient, command: Record<string, unknown>): void {
+  client.ws.send(JSON.stringify({ type: 'replay', ...command }));
+}
+
+function replayed(client: TestClient): any[] {
+  return client.messages.filter(message => message.type === 'replay_event');
+}
+
+test('events arrive in order, paced by their gaps divided by speed', async () => {
+  const ids = await seedSession('replay-timing', 4, 200);
+  const client = await connectClient();
+  const arrivals: number[] = [];
+  client.ws.addEventListener('message', event => {
+    if (JSON.parse(String(event.data)).type === 'replay_event') arrivals.push(performance.now());
+  });
+  try {
+    replay(client, { session_id: 'replay-timing', speed: 2 });
+    const started = await client.next('replay_started');
+    const complete = await client.next('replay_complete');
+    
+    expect(started.data).toEqual({ session_id: 'replay-timing', speed: 2, count: 4 });
+    expect(complete.data).toEqual({ session_id: 'replay-timing', count: 4 });
+    expect(replayed(client).map(message => message.data.id)).toEqual(ids);
+    
+    // Three 200ms gaps at double speed
+    const elapsed = arrivals[3]! - arrivals[0]!;
+    expect(elapsed).toBeGreaterThanOrEqual(250);
+    expect(elapsed).toBeLessThan(600);
+    for (let i = 1; i < arrivals.length; i++) {
+      expect(arrivals[i]! - arrivals[i - 1]!).toBeGreaterThanOrEqual(80);
+    }
+  } finally {
+    client.close();
+  }
+});
+
+test('a cancelled replay stops sending events', async () => {
+  await seedSession('replay-cancel', 4, 300);
+  const client = await connectClient();
+  try {
+    replay(client, { session_id: 'replay-cancel' });
+    await client.next('replay_event');
+    client.ws.send(JSON.stringify({ type: 'replay_cancel' }));
+    await client.next('replay_cancelled');
+    await sleep(700);
+    
+    expect(replayed(client)).toHaveLength(1);
+    expect(client.messages.some(message => message.type === 'replay_complete')).toBe(false);
+  } finally {
+    client.close();
+  }
+});
+
+test('long sessions play through page by page', async () => {
+  setConfig({ MAX_PAGE_SIZE: 2 });
+  const ids = await seedSession('replay-pages', 5, 1);
+  const client = await connectClient();
+  try {
+    replay(client, { session_id: 'replay-pages', speed: 1000 });
+    const complete = await client.next('replay_complete');
+    
+    expect(complete.data.count).toBe(5);
+    expect(replayed(client).map(message => message.data.id)).toEqual(ids);
+  } finally {
+    client.close();
+  }
+});
+
+test('replayed events go only to the client that asked', async () => {
+  await seedSession('replay-private', 2, 1);
+  const requester = await connectClient();
+  const bystander = await connectClient();
+  try {
+    replay(requester, { session_id: 'replay-private', speed: 1000 });
+    await requester.next('replay_complete');
+    await sleep(50);
+    
+    expect(replayed(requester)).toHaveLength(2);
+    expect(replayed(bystander)).toHaveLength(0);
+  } finally {
+    requester.close();
+    bystander.close();
+  }
+});
+
+test('unknown sessions and bad speeds get an error frame', async () => {
+  await seedSession('replay-errors', 1, 1);
+  const client = await connectClient();
+  try {
+    replay(client, { session_id: 'replay-nobody' });
+    expect((await client.next('error')).data.code).toBe('SESSION_NOT_FOUND');
+    
+    for (const speed of [0, -1, 'fast', 5000]) {
+      replay(client, { session_id: 'replay-errors', speed });
+      expect((await client.next('error')).data.code).toBe('INVALID_PARAMETER');
+    }
+    expect(replayed(client)).toHaveLength(0);
+  } finally {
+    client.close();
+  }
+});
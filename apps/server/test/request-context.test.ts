@@ -0,0 +1,76 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { Database } from 'bun:sqlite';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { closeDatabase, countEvents, initDatabase } from '../src/db';
+import { RequestAbortedError } from '../src/errors';
+import { getRequestDeadline, getRequestId, getRequestSignal, runWithRequestId } from '../src/logger';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { request, sleep } from './server';
+
+let dir: string;
+
+beforeEach(() => {
+  dir = mkdtempSync(join(tmpdir(), 'request-context-'));
+  resetDatabase();
+});
+
+afterEach(() => {
+  resetDatabase();
+  rmSync(dir, { recursive: true, force: true });
+});
+
+test('the request context survives awaits into the database layer', async () => {
+  const controller = new AbortController();
+  const deadline = Date.now() + 1000;
+  
+  const seen = await runWithRequestId('ctx-1', async () => {
+    await sleep(5);
+    return { id: getRequestId(), deadline: getRequestDeadline(), signal: getRequestSignal() };
+  }, deadline, controller.signal);
+  
+  expect(seen).toEqual({ id: 'ctx-1', deadline, signal: controller.signal });
+  expect(getRequestId()).toBeUndefined();
+});
+
+test('an abort mid-request stops the database work that follows', async () => {
+  const controller = new AbortController();
+  
+  const result = runWithRequestId('ctx-abort', async () => {
+    expect(countEvents()).toBe(0);
+    controller.abort();
+    await sleep(5);
+    return countEvents();
+  }, undefined, controller.signal);
+  
+  await expect(result).rejects.toThrow(RequestAbortedError);
+});
+
+test('a client that disconnects while its write waits on a lock stores nothing', async () => {
+  const path = join(dir, 'events.db');
+  setConfig({ REQUEST_TIMEOUT_MS: 0, DB_BUSY_TIMEOUT_MS: 0, DB_BUSY_RETRIES: 20, DB_BUSY_BACKOFF_MS: 10 });
+  closeDatabase();
+  initDatabase(path);
+  
+  const other = new Database(path);
+  other.exec('BEGIN IMMEDIATE');
+  
+  const controller = new AbortController();
+  const write = request('/events', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(makeEvent({ session_id: 'abandoned' })),
+    signal: controller.signal
+  });
+  setTimeout(() => controller.abort(), 50);
+  await expect(write).rejects.toThrow();
+  
+  // The handler's next retry sees the aborted signal and gives up
+  await sleep(50);
+  other.exec('COMMIT');
+  other.close();
+  await sleep(700);
+  
+  expect(countEvents()).toBe(0);
+});
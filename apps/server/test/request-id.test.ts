@@ -0,0 +1,44 @@
+import { afterAll, afterEach, expect, spyOn, test } from 'bun:test';
+import { formatLogLine } from '../src/logger';
+import { setConfig } from './helpers';
+import { request } from './server';
+
+const errorSpy = spyOn(console, 'error').mockImplementation(() => {});
+
+afterEach(() => errorSpy.mockClear());
+afterAll(() => errorSpy.mockRestore());
+
+test('a provided request id is echoed back', async () => {
+  const response = await request('/events/count', { headers: { 'X-Request-ID': 'req-abc-123' } });
+  
+  expect(response.headers.get('x-request-id')).toBe('req-abc-123');
+});
+
+test('a fresh id is generated when none is sent', async () => {
+  const first = (await request('/events/count')).headers.get('x-request-id');
+  const second = (await request('/events/count')).headers.get('x-request-id');
+  
+  expect(first).toMatch(/^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$/);
+  expect(second).not.toBe(first);
+});
+
+test('log lines written while handling a request carry its id', async () => {
+  setConfig({ LOG_FORMAT: 'text' });
+  const response = await request('/events', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json', 'X-Request-ID': 'req-log-1' },
+    body: 'not json'
+  });
+  
+  expect(response.status).toBe(400);
+  const lines = errorSpy.mock.calls.map(args => String(args[0]));
+  expect(lines.some(line => line.startsWith('[error] [req req-log-1] Error processing event:'))).toBe(true);
+});
+
+test('JSON log lines put the id in its own field', () => {
+  setConfig({ LOG_FORMAT: 'json' });
+  
+  const line = JSON.parse(formatLogLine('warn', ['slow query'], 'req-json-1'));
+  
+  expect(line).toMatchObject({ level: 'warn', request_id: 'req-json-1', msg: 'slow query' });
+});
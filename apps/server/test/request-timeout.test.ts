@@ -0,0 +1,77 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { Database } from 'bun:sqlite';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { closeDatabase, countEvents, getRecentEvents, initDatabase, insertEvent } from '../src/db';
+import { RequestAbortedError, RequestTimeoutError } from '../src/errors';
+import { runWithRequestId } from '../src/logger';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { request, requestJson, sleep } from './server';
+
+let dir: string;
+
+beforeEach(() => {
+  dir = mkdtempSync(join(tmpdir(), 'request-timeout-'));
+  resetDatabase();
+});
+
+afterEach(() => {
+  resetDatabase();
+  rmSync(dir, { recursive: true, force: true });
+});
+
+test('a write stuck behind a lock gets a 504 and is never stored', async () => {
+  const path = join(dir, 'events.db');
+  setConfig({ REQUEST_TIMEOUT_MS: 100, DB_BUSY_TIMEOUT_MS: 0, DB_BUSY_RETRIES: 20, DB_BUSY_BACKOFF_MS: 10 });
+  closeDatabase();
+  initDatabase(path);
+  
+  // Another connection holds the write lock well past the deadline, so the
+  // insert spends its time awaiting busy-retry backoff
+  const other = new Database(path);
+  other.exec('BEGIN IMMEDIATE');
+  setTimeout(() => other.exec('COMMIT'), 300);
+  
+  const started = Date.now();
+  const response = await requestJson('/events', 'POST', makeEvent({ session_id: 'timed-out' }), { 'X-Request-ID': 'slow-write' });
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(504);
+  expect(body.error.code).toBe('TIMEOUT');
+  expect(body.error.details).toEqual({ requestId: 'slow-write' });
+  expect(Date.now() - started).toBeLessThan(300);
+  
+  // Once the lock is gone the handler's next retry sees the passed deadline
+  // and gives up instead of writing
+  await sleep(900);
+  other.close();
+  expect(countEvents()).toBe(0);
+});
+
+test('queries started past the deadline throw instead of running', () => {
+  const expired = Date.now() - 1;
+  
+  expect(() => runWithRequestId('late-read', () => getRecentEvents(), expired)).toThrow(RequestTimeoutError);
+  expect(runWithRequestId('on-time-read', () => getRecentEvents(), Date.now() + 1000)).toEqual([]);
+});
+
+test('writes started past the deadline throw instead of running', async () => {
+  const write = runWithRequestId('late-write', () => insertEvent(makeEvent()), Date.now() - 1);
+  
+  await expect(write).rejects.toThrow(RequestTimeoutError);
+  expect(countEvents()).toBe(0);
+});
+
+test('queries for a client that went away are skipped', () => {
+  const controller = new AbortController();
+  controller.abort();
+  
+  expect(() => runWithRequestId('gone', () => countEvents(), undefined, controller.signal)).toThrow(RequestAbortedError);
+});
+
+test('a zero timeout disables the deadline', async () => {
+  setConfig({ REQUEST_TIMEOUT_MS: 0 });
+  
+  expect((await request('/events/recent')).status).toBe(200);
+});
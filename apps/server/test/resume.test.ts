@@ -0,0 +1,86 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase, setConfig } from './helpers';
+import { connectClient, postEvent, sleep } from './server';
+import type { TestClient } from './server';
+
+beforeEach(resetDatabase);
+
+// Connect, see one event so the session's filter values are known, then
+// disconnect. Returns the client id and the last seq the client saw.
+async function connectAndLeave(sessionId: string): Promise<{ clientId: string; lastSeq: number }> {
+  const client = await connectClient();
+  const clientId = (await client.next('connected')).data.client_id;
+  await postEvent({ session_id: sessionId });
+  await client.next('event');
+  await sleep(50);
+  const lastSeq = Math.max(...client.messages.map(message => message.seq));
+  client.close();
+  // Let the server run its close handler
+  await sleep(50);
+  return { clientId, lastSeq };
+}
+
+function resume(client: TestClient, clientId: string, lastSeq: number): void {
+  client.ws.send(JSON.stringify({ type: 'resume', client_id: clientId, last_seq: lastSeq }));
+}
+
+test('frames broadcast while disconnected are replayed on resume', async () => {
+  const { clientId, lastSeq } = await connectAndLeave('resume-hit');
+  const missed = [await postEvent({ session_id: 'resume-hit' }), await postEvent({ session_id: 'resume-hit' })];
+  
+  const client = await connectClient();
+  try {
+    resume(client, clientId, lastSeq);
+    const resumed = await client.next('resumed');
+    
+    expect(resumed.data.client_id).toBe(clientId);
+    expect(resumed.data.replayed).toBe(2);
+    const replayed = client.messages.filter(message => message.type === 'event');
+    expect(replayed.map(message => message.data.id)).toEqual(missed.map(event => event.id));
+    expect(replayed.map(message => message.seq)).toEqual([lastSeq + 1, lastSeq + 2]);
+    // The stream continues from the old client's numbering
+    expect(resumed.seq).toBe(lastSeq + 3);
+  } finally {
+    client.close();
+  }
+});
+
+test('resume after the buffer overflowed asks the client to resync', async () => {
+  setConfig({ WS_RESUME_BUFFER_SIZE: 2 });
+  const { clientId, lastSeq } = await connectAndLeave('resume-miss');
+  // One more frame than the buffer holds, so the first missed one is gone
+  for (let i = 0; i < 3; i++) {
+    await postEvent({ session_id: 'resume-miss' });
+  }
+  
+  const client = await connectClient();
+  try {
+    resume(client, clientId, lastSeq);
+    const resync = await client.next('resync_required');
+    
+    expect(resync.data.client_id).not.toBe(clientId);
+    expect(client.messages.filter(message => message.type === 'event')).toHaveLength(0);
+    expect(client.messages.some(message => message.type === 'resumed')).toBe(false);
+  } finally {
+    client.close();
+  }
+});
+
+test('past MAX_TRACKED_SESSIONS the longest detached client is forgotten', async () => {
+  setConfig({ MAX_TRACKED_SESSIONS: 1 });
+  const first = await connectAndLeave('resume-evicted');
+  const second = await connectAndLeave('resume-kept');
+  
+  const evicted = await connectClient();
+  const kept = await connectClient();
+  try {
+    resume(evicted, first.clientId, first.lastSeq);
+    expect((await evicted.next('resync_required')).data.client_id).not.toBe(first.clientId);
+    
+    resume(kept, second.clientId, second.lastSeq);
+    expect((await kept.next('resumed')).data.client_id).toBe(second.clientId);
+  } finally {
+    evicted.close();
+    kept.close();
+  }
+});
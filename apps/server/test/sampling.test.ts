@@ -0,0 +1,86 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { connectClient, request, requestJson, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+// Sampling counts per source and type for the whole process, so every test
+// uses its own source_app
+async function send(overrides: Record<string, unknown>): Promise<Response> {
+  return requestJson('/events', 'POST', makeEvent(overrides));
+}
+
+async function stored(sourceApp: string): Promise<any[]> {
+  return await (await request(`/events?source_app=${sourceApp}&limit=100`)).json() as any[];
+}
+
+test('a sampled source keeps one event in every N', async () => {
+  setConfig({ SAMPLE_RATE: { 'sampling-chatty': 5 } });
+  
+  const statuses: number[] = [];
+  for (let i = 0; i < 20; i++) {
+    const response = await send({ source_app: 'sampling-chatty', session_id: 'sampling-1' });
+    statuses.push(response.status);
+    if (response.status === 202) {
+      expect((await response.json() as any).status).toBe('sampled_out');
+    }
+  }
+  
+  expect(statuses.filter(status => status === 202)).toHaveLength(16);
+  const kept = await stored('sampling-chatty');
+  expect(kept).toHaveLength(4);
+  expect(kept.every(event => event.sampled === true && event.sample_rate === 5)).toBe(true);
+});
+
+test('stats report the approximate total the sample stands for', async () => {
+  setConfig({ SAMPLE_RATE: { 'sampling-stats': 4 } });
+  for (let i = 0; i < 8; i++) {
+    await send({ source_app: 'sampling-stats', session_id: 'sampling-2' });
+  }
+  await send({ source_app: 'sampling-unsampled', session_id: 'sampling-2' });
+  
+  const stats = await (await request('/events/stats')).json() as any;
+  
+  expect(stats.total).toBe(3);
+  expect(stats.estimated_total).toBe(9);
+});
+
+test('a source_app:hook_event_type rule overrides the source rule', async () => {
+  setConfig({ SAMPLE_RATE: { 'sampling-mixed': 4, 'sampling-mixed:Stop': 1 } });
+  
+  for (let i = 0; i < 4; i++) {
+    await send({ source_app: 'sampling-mixed', hook_event_type: 'Stop' });
+    await send({ source_app: 'sampling-mixed', hook_event_type: 'PreToolUse' });
+  }
+  
+  const kept = await stored('sampling-mixed');
+  expect(kept.filter(event => event.hook_event_type === 'Stop')).toHaveLength(4);
+  expect(kept.filter(event => event.hook_event_type === 'PreToolUse')).toHaveLength(1);
+  expect(kept.find(event => event.hook_event_type === 'Stop').sampled).toBeUndefined();
+});
+
+test('events dropped by sampling are not broadcast', async () => {
+  setConfig({ SAMPLE_RATE: { 'sampling-ws': 3 } });
+  const client = await connectClient();
+  try {
+    for (let i = 0; i < 6; i++) {
+      await send({ source_app: 'sampling-ws', session_id: 'sampling-ws-1' });
+    }
+    await sleep(100);
+    
+    const events = client.messages.filter(message => message.type === 'event');
+    expect(events).toHaveLength(2);
+    expect(events.every(message => message.data.sample_rate === 3)).toBe(true);
+  } finally {
+    client.close();
+  }
+});
+
+test('clients cannot mark their own events as sampled', async () => {
+  const response = await send({ source_app: 'sampling-forged', sampled: true, sample_rate: 100 });
+  expect(response.ok).toBe(true);
+  
+  const [event] = await stored('sampling-forged');
+  expect(event.sampled).toBeUndefined();
+  expect(event.sample_rate).toBeUndefined();
+});
@@ -0,0 +1,56 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+async function search(query: string): Promise<any[]> {
+  const response = await request(`/events/search?${new URLSearchParams({ q: query })}`);
+  expect(response.status).toBe(200);
+  return response.json() as Promise<any[]>;
+}
+
+test('only events whose payload mentions the query are returned, newest first', async () => {
+  const now = Date.now();
+  await insertEvents([
+    makeEvent({ timestamp: now - 3000, payload: { tool_name: 'Read', tool_input: { file_path: '/src/server.ts' } } }),
+    makeEvent({ timestamp: now - 2000, payload: { tool_name: 'Bash', tool_input: { command: 'ls' } } }),
+    makeEvent({ timestamp: now - 1000, payload: { tool_name: 'Edit', tool_input: { file_path: '/src/server.ts' } } })
+  ]);
+  
+  const matches = await search('server.ts');
+  
+  expect(matches.map(event => event.payload.tool_name)).toEqual(['Edit', 'Read']);
+  expect((await search('Bash')).map(event => event.payload.tool_name)).toEqual(['Bash']);
+  expect(await search('nothing-mentions-this')).toEqual([]);
+});
+
+test('matching is case-insensitive and LIKE wildcards are literal', async () => {
+  await insertEvents([
+    makeEvent({ payload: { note: '100% done' } }),
+    makeEvent({ payload: { note: '100 items done' } }),
+    makeEvent({ payload: { note: 'snake_case' } }),
+    makeEvent({ payload: { note: 'snakeXcase' } })
+  ]);
+  
+  expect((await search('100%')).map(event => event.payload.note)).toEqual(['100% done']);
+  expect((await search('SNAKE_CASE')).map(event => event.payload.note)).toEqual(['snake_case']);
+});
+
+test('limit and offset page through the matches', async () => {
+  const now = Date.now();
+  await insertEvents(Array.from({ length: 5 }, (_, i) => makeEvent({ timestamp: now - i * 1000, payload: { n: i, marker: 'paged' } })));
+  await insertEvents([makeEvent({ payload: { marker: 'other' } })]);
+  
+  const page = await (await request('/events/search?q=paged&limit=2&offset=1')).json() as any[];
+  
+  expect(page.map(event => event.payload.n)).toEqual([1, 2]);
+});
+
+test('a missing query is rejected', async () => {
+  const response = await request('/events/search');
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+});
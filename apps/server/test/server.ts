@@ -0,0 +1,165 @@
+import { server } from '../src/index';
+import { wsClients } from '../src/websocket';
+import { makeEvent } from './helpers';
+import type { HookEvent } from '../src/types';
+
+// Importing the entry point starts the server on a random port (PORT=0)
+export function request(path: string, init: RequestInit = {}): Promise<Response> {
+  return fetch(`http://localhost:${server.port}${path}`, init);
+}
+
+export function requestJson(path: string, method: string, body: unknown, headers: Record<string, string> = {}): Promise<Response> {
+  return request(path, {
+    method,
+    headers: { 'Content-Type': 'application/json', ...headers },
+    body: JSON.stringify(body)
+  });
+}
+
+// POST an event through the API and return the stored copy
+export async function postEvent(overrides: Partial<HookEvent> = {}): Promise<HookEvent> {
+  const response = await requestJson('/events', 'POST', makeEvent(overrides));
+  if (!response.ok) throw new Error(`POST /events failed with ${response.status}: ${await response.text()}`);
+  return response.json() as Promise<HookEvent>;
+}
+
+export function wsUrl(path: string = '/stream'): string {
+  return `ws://localhost:${server.port}${path}`;
+}
+
+export interface TestClient {
+  ws: WebSocket;
+  messages: any[];
+  // Resolve with the first message of this type, waiting up to timeoutMs
+  next(type: string, timeoutMs?: number): Promise<any>;
+  close(): void;
+}
+
+// Open a WebSocket to the server and record every frame it receives.
+// Resolves once the initial frame has arrived.
+export async function connectClient(path: string = '/stream', protocols?: string[]): Promise<TestClient> {
+  const ws = new WebSocket(wsUrl(path), protocols);
+  const messages: any[] = [];
+  const waiters: { type: string; resolve: (message: any) => void }[] = [];
+  
+  ws.addEventListener('message', event => {
+    const message = JSON.parse(String(event.data));
+    messages.push(message);
+    const waiter = waiters.find(w => w.type === message.type);
+    if (waiter) {
+      waiters.splice(waiters.indexOf(waiter), 1);
+      waiter.resolve(message);
+    }
+  });
+  
+  const client: TestClient = {
+    ws,
+    messages,
+    next(type, timeoutMs = 2000) {
+      const seen = messages.find(message => message.type === type && !message._consumed);
+      if (seen) {
+        seen._consumed = true;
+        return Promise.resolve(seen);
+      }
+      return new Promise((resolve, reject) => {
+        const timer = setTimeout(() => reject(new Error(`No ${type} frame within ${timeoutMs}ms`)), timeoutMs);
+        waiters.push({ type, resolve: message => {
+          clearTimeout(timer);
+          message._consumed = true;
+          resolve(message);
+        } });
+      });
+    },
+    close() {
+      ws.close();
+    }
+  };
+  
+  await client.next('initial');
+  return client;
+}
+
+// The server-side socket of a test client, found by its announced id
+export async function serverSocketOf(client: TestClient) {
+  const id = (await client.next('connected')).data.client_id;
+  const socket = [...wsClients].find(ws => ws.data.id === id);
+  if (!socket) throw new Error(`No server socket for client ${id}`);
+  return socket;
+}
+
+export interface SseFrame {
+  id?: string;
+  event: string;
+  data: any;
+}
+
+export interface SseClient {
+  response: Response;
+  frames: SseFrame[];
+  // Resolve with the first unread frame of this event type
+  next(event: string, timeoutMs?: number): Promise<SseFrame>;
+  close(): void;
+}
+
+// Open /events/stream and parse its frames as they arrive. Comment and
+// retry-only frames are skipped.
+export async function connectSse(headers: Record<string, string> = {}): Promise<SseClient> {
+  const abort = new AbortController();
+  const response = await request('/events/stream', { headers, signal: abort.signal });
+  const frames: SseFrame[] = [];
+  const consumed = new Set<SseFrame>();
+  let notify = () => {};
+  
+  (async () => {
+    const reader = response.body!.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = '';
+    try {
+      while (true) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += value;
+        let end: number;
+        while ((end = buffer.indexOf('\n\n')) !== -1) {
+          const block = buffer.slice(0, end);
+          buffer = buffer.slice(end + 2);
+          const fields = new Map<string, string>();
+          for (const line of block.split('\n')) {
+            const colon = line.indexOf(':');
+            if (colon <= 0) continue;
+            fields.set(line.slice(0, colon), line.slice(colon + 1).trimStart());
+          }
+          if (!fields.has('event')) continue;
+          frames.push({ id: fields.get('id'), event: fields.get('event')!, data: JSON.parse(fields.get('data') ?? 'null') });
+          notify();
+        }
+      }
+    } catch (err) {
+      // Aborted by close()
+    }
+  })();
+  
+  return {
+    response,
+    frames,
+    async next(event, timeoutMs = 2000) {
+      const giveUp = Date.now() + timeoutMs;
+      while (true) {
+        const frame = frames.find(f => f.event === event && !consumed.has(f));
+        if (frame) {
+          consumed.add(frame);
+          return frame;
+        }
+        if (Date.now() >= giveUp) throw new Error(`No ${event} SSE frame within ${timeoutMs}ms`);
+        await new Promise<void>(resolve => {
+          notify = resolve;
+          setTimeout(resolve, 50);
+        });
+      }
+    },
+    close() {
+      abort.abort();
+    }
+  };
+}
+
+export const sleep = (ms: number) => new Promise(resolve => setTimeout(resolve, ms));
@@ -0,0 +1,62 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { countEvents, insertEvents, softDeleteEvent } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeEvent, resetDatabase, setConfig } from './helpers';
+import { connectClient, request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  setConfig({ API_KEY: ADMIN_KEY });
+  await insertEvents([
+    makeEvent({ session_id: 'purge-me' }),
+    makeEvent({ session_id: 'purge-me', hook_event_type: 'PostToolUse' }),
+    makeEvent({ session_id: 'purge-me', hook_event_type: 'Stop' }),
+    makeEvent({ session_id: 'keep-me' }),
+    makeEvent({ session_id: 'purge-me-too' })
+  ]);
+});
+
+function deleteSession(sessionId: string, headers: Record<string, string> = adminHeaders): Promise<Response> {
+  return request(`/events/sessions/${encodeURIComponent(sessionId)}`, { method: 'DELETE', headers });
+}
+
+test("only the target session's events are removed", async () => {
+  const response = await deleteSession('purge-me');
+  
+  expect(response.status).toBe(200);
+  expect(await response.json()).toEqual({ session_id: 'purge-me', deleted: 3 });
+  expect(countEvents({ session_id: ['purge-me'], includeDeleted: true })).toBe(0);
+  expect(countEvents({ session_id: ['keep-me'] })).toBe(1);
+  expect(countEvents({ session_id: ['purge-me-too'] })).toBe(1);
+});
+
+test('soft-deleted events of the session are purged as well', async () => {
+  const [first] = await insertEvents([makeEvent({ session_id: 'half-deleted' }), makeEvent({ session_id: 'half-deleted' })]);
+  await softDeleteEvent(first!.event.id!);
+  
+  expect(await (await deleteSession('half-deleted')).json()).toEqual({ session_id: 'half-deleted', deleted: 2 });
+  expect(countEvents({ session_id: ['half-deleted'], includeDeleted: true })).toBe(0);
+});
+
+test('dashboards are told the session is gone', async () => {
+  const client = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  try {
+    await deleteSession('purge-me');
+    
+    const frame = await client.next('session_deleted');
+    expect(frame.data).toEqual({ session_id: 'purge-me', deleted: 3 });
+  } finally {
+    client.close();
+  }
+});
+
+test('an unknown session is a 404', async () => {
+  const response = await deleteSession('never-existed');
+  
+  expect(response.status).toBe(404);
+  expect((await response.json() as any).error.code).toBe('SESSION_NOT_FOUND');
+});
+
+test('purging needs the admin key', async () => {
+  expect((await deleteSession('purge-me', {})).status).toBe(401);
+  expect(countEvents({ session_id: ['purge-me'] })).toBe(3);
+});
@@ -0,0 +1,42 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+const base = Date.now() - 60000;
+
+async function getSessionEvents(sessionId: string, query: string = ''): Promise<any[]> {
+  const response = await request(`/events/sessions/${encodeURIComponent(sessionId)}${query}`);
+  expect(response.status).toBe(200);
+  return response.json() as Promise<any[]>;
+}
+
+test('only the requested session is returned, oldest first', async () => {
+  // Inserted out of order, interleaved with another session
+  await seedEvents([
+    makeEvent({ session_id: 'replay-me', timestamp: base + 2000, payload: { n: 2 } }),
+    makeEvent({ session_id: 'other', timestamp: base + 1500, payload: { n: -1 } }),
+    makeEvent({ session_id: 'replay-me', timestamp: base, payload: { n: 0 } }),
+    makeEvent({ session_id: 'replay-me', timestamp: base + 1000, payload: { n: 1 } })
+  ]);
+  
+  const events = await getSessionEvents('replay-me');
+  
+  expect(events.map(event => event.payload.n)).toEqual([0, 1, 2]);
+  expect(events.every(event => event.session_id === 'replay-me')).toBe(true);
+});
+
+test('long sessions are paged in order', async () => {
+  await seedEvents(Array.from({ length: 5 }, (_, n) => makeEvent({ session_id: 'long run', timestamp: base + n * 1000, payload: { n } })));
+  
+  const first = await getSessionEvents('long run', '?limit=2');
+  const second = await getSessionEvents('long run', '?limit=2&offset=2');
+  const last = await getSessionEvents('long run', '?limit=2&offset=4');
+  
+  expect([first, second, last].map(page => page.map(event => event.payload.n))).toEqual([[0, 1], [2, 3], [4]]);
+});
+
+test('an unknown session has no events', async () => {
+  expect(await getSessionEvents('nobody')).toEqual([]);
+});
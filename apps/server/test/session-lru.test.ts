@@ -0,0 +1,86 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { config } from '../src/config';
+import { insertEvent } from '../src/db';
+import { introducesNewFilterValue } from '../src/filters';
+import { createSessionCache, LRUCache } from '../src/lru';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+
+beforeEach(resetDatabase);
+
+test('the least recently used entry is evicted past the limit', () => {
+  const cache = new LRUCache<string, number>(2);
+  cache.set('a', 1);
+  cache.set('b', 2);
+  // Touching a makes b the least recently used
+  expect(cache.get('a')).toBe(1);
+  cache.set('c', 3);
+  
+  expect(cache.size).toBe(2);
+  expect(cache.has('a')).toBe(true);
+  expect(cache.has('b')).toBe(false);
+  expect(cache.has('c')).toBe(true);
+});
+
+test('iterating does not refresh entries', () => {
+  const cache = new LRUCache<string, number>(2);
+  cache.set('a', 1);
+  cache.set('b', 2);
+  const seen: string[] = [];
+  cache.forEach((_, key) => seen.push(key));
+  cache.set('c', 3);
+  
+  expect(seen).toEqual(['a', 'b']);
+  expect(cache.has('a')).toBe(false);
+});
+
+test('session caches are bounded by MAX_TRACKED_SESSIONS', () => {
+  setConfig({ MAX_TRACKED_SESSIONS: 3 });
+  const cache = createSessionCache<true>();
+  for (let i = 0; i < 10; i++) {
+    cache.set(`session-${i}`, true);
+  }
+  
+  expect(cache.size).toBe(3);
+  expect(cache.has('session-9')).toBe(true);
+  expect(cache.has('session-6')).toBe(false);
+});
+
+test('a session evicted from filter tracking falls back to the database', async () => {
+  setConfig({ MAX_TRACKED_SESSIONS: 5 });
+  const values = { source_app: 'lru-app', session_id: 'lru-evicted', hook_event_type: 'LruType' };
+  expect(introducesNewFilterValue((await insertEvent(makeEvent(values))).event)).toBe(true);
+  
+  // Push enough other sessions through to evict it from the tracker
+  for (let i = 0; i < config.MAX_TRACKED_SESSIONS; i++) {
+    introducesNewFilterValue(makeEvent({ ...values, session_id: `lru-filler-${i}`, id: 1 }));
+  }
+  
+  // Not tracked any more, but stored, so not new
+  expect(introducesNewFilterValue((await insertEvent(makeEvent(values))).event)).toBe(false);
+  // A session that was never stored is still reported
+  expect(introducesNewFilterValue((await insertEvent(makeEvent({ ...values, session_id: 'lru-fresh' }))).event)).toBe(true);
+});
+
+test('source apps and event types are bounded and fall back to the database too', async () => {
+  setConfig({ MAX_TRACKED_SESSIONS: 5 });
+  const values = { source_app: 'lru-evicted-app', session_id: 'lru-apps', hook_event_type: 'LruEvictedType' };
+  expect(introducesNewFilterValue((await insertEvent(makeEvent(values))).event)).toBe(true);
+  
+  // Enough other apps and types to evict both from their trackers
+  for (let i = 0; i < config.MAX_TRACKED_SESSIONS; i++) {
+    introducesNewFilterValue(makeEvent({ ...values, source_app: `lru-filler-app-${i}`, hook_event_type: `LruFillerType${i}`, id: 1 }));
+  }
+  
+  expect(introducesNewFilterValue((await insertEvent(makeEvent(values))).event)).toBe(false);
+  expect(introducesNewFilterValue((await insertEvent(makeEvent({ ...values, source_app: 'lru-fresh-app' }))).event)).toBe(true);
+});
+
+test('an existing cache picks up a lowered MAX_TRACKED_SESSIONS', () => {
+  const cache = createSessionCache<true>();
+  setConfig({ MAX_TRACKED_SESSIONS: 2 });
+  for (let i = 0; i < 5; i++) {
+    cache.set(`session-${i}`, true);
+  }
+  
+  expect(cache.size).toBe(2);
+});
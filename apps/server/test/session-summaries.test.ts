@@ -0,0 +1,39 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+const base = Date.now() - 60000;
+
+async function getSessions(query: string = ''): Promise<any[]> {
+  const response = await request(`/events/sessions${query}`);
+  expect(response.status).toBe(200);
+  return response.json() as Promise<any[]>;
+}
+
+test('each session is summarised, most recently active first', async () => {
+  await seedEvents([
+    makeEvent({ session_id: 'alpha', source_app: 'web', timestamp: base, summary: 'started' }),
+    makeEvent({ session_id: 'alpha', source_app: 'web', timestamp: base + 3000, summary: 'finished' }),
+    makeEvent({ session_id: 'alpha', source_app: 'web', timestamp: base + 4000 }),
+    makeEvent({ session_id: 'beta', source_app: 'cli', timestamp: base + 1000 }),
+    makeEvent({ session_id: 'gamma', source_app: 'cli', timestamp: base + 5000, summary: 'only one' })
+  ]);
+  
+  const sessions = await getSessions();
+  
+  expect(sessions).toEqual([
+    { session_id: 'gamma', source_app: 'cli', event_count: 1, first_timestamp: base + 5000, last_timestamp: base + 5000, latest_summary: 'only one' },
+    { session_id: 'alpha', source_app: 'web', event_count: 3, first_timestamp: base, last_timestamp: base + 4000, latest_summary: 'finished' },
+    { session_id: 'beta', source_app: 'cli', event_count: 1, first_timestamp: base + 1000, last_timestamp: base + 1000, latest_summary: null }
+  ]);
+});
+
+test('limit and offset page through sessions', async () => {
+  await seedEvents(['s1', 's2', 's3'].map((session_id, i) => makeEvent({ session_id, timestamp: base + i * 1000 })));
+  
+  const page = await getSessions('?limit=1&offset=1');
+  
+  expect(page.map(session => session.session_id)).toEqual(['s2']);
+});
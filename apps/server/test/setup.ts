@@ -0,0 +1,12 @@
+import { afterEach } from 'bun:test';
+
+// Config is read once on import, so these must be set before any server
+// module loads. Tests override individual values with setConfig instead.
+process.env.NODE_ENV = 'test';
+process.env.DATABASE_PATH = ':memory:';
+process.env.PORT = '0';
+process.env.LOG_LEVEL = 'error';
+process.env.READ_CACHE_TTL_MS = '0';
+
+const { restoreConfig } = await import('./helpers');
+afterEach(restoreConfig);
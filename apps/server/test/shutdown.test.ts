@@ -0,0 +1,27 @@
+import { expect, test } from 'bun:test';
+import { getClientCount, shutdownWebSockets, startClientSweep } from '../src/websocket';
+import { connectClient } from './server';
+import type { TestClient } from './server';
+
+function closeEventOf(client: TestClient): Promise<CloseEvent> {
+  return new Promise(resolve => client.ws.addEventListener('close', resolve));
+}
+
+test('shutdown sends every client a going-away close frame', async () => {
+  const clients = [await connectClient(), await connectClient()];
+  const closes = clients.map(closeEventOf);
+  
+  try {
+    shutdownWebSockets();
+    
+    for (const close of await Promise.all(closes)) {
+      expect(close.code).toBe(1001);
+      expect(close.reason).toBe('Server shutting down');
+      expect(close.wasClean).toBe(true);
+    }
+    expect(getClientCount()).toBe(0);
+  } finally {
+    // Later tests rely on the heartbeat sweep
+    startClientSweep();
+  }
+});
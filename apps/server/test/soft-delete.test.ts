@@ -0,0 +1,55 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { ADMIN_KEY, adminHeaders, resetDatabase, setConfig } from './helpers';
+import { postEvent, request } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ API_KEY: ADMIN_KEY });
+});
+
+async function recentIds(query: string = ''): Promise<number[]> {
+  const response = await request(`/events/recent${query}`, { headers: adminHeaders });
+  return (await response.json() as any[]).map(event => event.id);
+}
+
+async function count(query: string = ''): Promise<number> {
+  return (await (await request(`/events/count${query}`, { headers: adminHeaders })).json() as any).count;
+}
+
+test('deleted events disappear from recent, count and search but reappear with the flag', async () => {
+  const now = Date.now();
+  const kept = await postEvent({ session_id: 'soft-delete', timestamp: now - 2000, payload: { note: 'findme kept' } });
+  const deleted = await postEvent({ session_id: 'soft-delete', timestamp: now - 1000, payload: { note: 'findme deleted' } });
+  
+  const response = await request(`/events/${deleted.id}`, { method: 'DELETE', headers: adminHeaders });
+  expect(response.status).toBe(200);
+  expect(await response.json()).toEqual({ success: true, id: deleted.id });
+  
+  expect(await recentIds()).toEqual([kept.id!]);
+  expect(await count()).toBe(1);
+  const search = await (await request('/events/search?q=findme')).json() as any[];
+  expect(search.map(event => event.id)).toEqual([kept.id]);
+  expect((await request(`/events/${deleted.id}`)).status).toBe(404);
+  
+  expect(await recentIds('?includeDeleted=true')).toEqual([kept.id!, deleted.id!]);
+  expect(await count('?includeDeleted=true')).toBe(2);
+  expect((await request(`/events/${deleted.id}?includeDeleted=true`, { headers: adminHeaders })).status).toBe(200);
+});
+
+test('the row is kept, so deleting again still finds nothing visible', async () => {
+  const event = await postEvent({ session_id: 'soft-delete-twice' });
+  
+  await request(`/events/${event.id}`, { method: 'DELETE', headers: adminHeaders });
+  const again = await request(`/events/${event.id}`, { method: 'DELETE', headers: adminHeaders });
+  
+  expect(again.status).toBe(404);
+  expect(await count('?includeDeleted=true')).toBe(1);
+});
+
+test('deleting and the includeDeleted flag need the admin key', async () => {
+  const event = await postEvent({ session_id: 'soft-delete-auth' });
+  
+  expect((await request(`/events/${event.id}`, { method: 'DELETE' })).status).toBe(401);
+  expect((await request('/events/recent?includeDeleted=true')).status).toBe(401);
+  expect(await count()).toBe(1);
+});
@@ -0,0 +1,62 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { RateLimiter } from '../src/ratelimit';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { requestJson } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ PER_SOURCE_RATE_LIMIT: 2, RATE_LIMIT_WINDOW_MS: 60_000 });
+});
+
+function post(sourceApp: string): Promise<Response> {
+  return requestJson('/events', 'POST', makeEvent({ source_app: sourceApp }));
+}
+
+// Counts persist across tests, so each test uses its own source names
+test('each source app is limited independently', async () => {
+  const statuses = [];
+  for (const sourceApp of ['limit-a', 'limit-a', 'limit-b', 'limit-a', 'limit-b', 'limit-b']) {
+    statuses.push((await post(sourceApp)).status);
+  }
+  
+  expect(statuses).toEqual([200, 200, 200, 429, 200, 429]);
+});
+
+test('the 429 names the limited source and when to retry', async () => {
+  await post('limit-named');
+  await post('limit-named');
+  
+  const response = await post('limit-named');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(429);
+  expect(body.error.code).toBe('RATE_LIMITED');
+  expect(body.error.message).toContain('"limit-named"');
+  expect(body.error.details).toEqual({ source_app: 'limit-named' });
+  expect(Number(response.headers.get('retry-after'))).toBeGreaterThan(0);
+  expect(Number(response.headers.get('retry-after'))).toBeLessThanOrEqual(60);
+});
+
+test('invalid events do not use up the allowance', async () => {
+  for (let i = 0; i < 3; i++) {
+    await requestJson('/events', 'POST', { source_app: 'limit-invalid' });
+  }
+  
+  expect((await post('limit-invalid')).status).toBe(200);
+});
+
+test('a zero limit disables the limiter', async () => {
+  setConfig({ PER_SOURCE_RATE_LIMIT: 0 });
+  
+  for (let i = 0; i < 5; i++) {
+    expect((await post('limit-disabled')).status).toBe(200);
+  }
+});
+
+test('a window resets its count once it has elapsed', () => {
+  const limiter = new RateLimiter(1, 1000);
+  
+  expect(limiter.hit('app', 0)).toBe(0);
+  expect(limiter.hit('app', 400)).toBe(600);
+  expect(limiter.hit('app', 1000)).toBe(0);
+});
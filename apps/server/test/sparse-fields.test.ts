@@ -0,0 +1,59 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { resetDatabase } from './helpers';
+import { postEvent, request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  await postEvent({ session_id: 'fields-1', hook_event_type: 'PreToolUse' });
+  await postEvent({ session_id: 'fields-1', hook_event_type: 'Stop', summary: 'Done' });
+});
+
+async function recent(query: string): Promise<any> {
+  const response = await request(`/events/recent?${query}`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('only the requested fields are returned', async () => {
+  const events = await recent('fields=id,timestamp,hook_event_type');
+  
+  expect(events).toHaveLength(2);
+  for (const event of events) {
+    expect(Object.keys(event).sort()).toEqual(['hook_event_type', 'id', 'timestamp']);
+  }
+  expect(events.map((event: any) => event.hook_event_type)).toEqual(['PreToolUse', 'Stop']);
+});
+
+test('fields missing from an event are omitted rather than null', async () => {
+  const events = await recent('fields=id, summary');
+  
+  expect(events[0]).toEqual({ id: events[0].id });
+  expect(events[1]).toEqual({ id: events[1].id, summary: 'Done' });
+});
+
+test('the projection applies to the envelope and cursor pages too', async () => {
+  const page = await recent('fields=id&envelope=true');
+  expect(page.total).toBe(2);
+  expect(page.data.every((event: any) => Object.keys(event).join() === 'id')).toBe(true);
+  
+  const cursorPage = await recent('fields=session_id&before_id=');
+  expect(cursorPage.data.every((event: any) => Object.keys(event).join() === 'session_id')).toBe(true);
+});
+
+test('an empty selection returns whole events', async () => {
+  const events = await recent('fields=');
+  
+  expect(events[0].payload).toBeDefined();
+  expect(events[0].source_app).toBe('test-app');
+});
+
+test('unknown field names are a 400 listing them and the allowed fields', async () => {
+  const response = await request('/events/recent?fields=id,password,payload.secret');
+  
+  expect(response.status).toBe(400);
+  const body = await response.json() as any;
+  expect(body.error.code).toBe('INVALID_PARAMETER');
+  expect(body.error.message).toContain('password');
+  expect(body.error.message).toContain('payload.secret');
+  expect(body.error.details.allowed).toContain('hook_event_type');
+});
@@ -0,0 +1,69 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getSseClientCount } from '../src/sse';
+import { resetDatabase } from './helpers';
+import { connectSse, postEvent, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+test('a new stream starts with the initial batch', async () => {
+  const earlier = await postEvent({ session_id: 'sse-initial' });
+  const client = await connectSse();
+  try {
+    expect(client.response.headers.get('content-type')).toBe('text/event-stream');
+    expect(client.response.headers.get('cache-control')).toBe('no-cache');
+    
+    const initial = await client.next('initial');
+    expect(initial.data.map((event: any) => event.id)).toEqual([earlier.id]);
+  } finally {
+    client.close();
+  }
+});
+
+test('a posted event arrives as an SSE frame carrying its id', async () => {
+  const client = await connectSse();
+  try {
+    await client.next('initial');
+    const event = await postEvent({ session_id: 'sse-live', source_app: 'sse-live-app' });
+    
+    const frame = await client.next('event');
+    expect(frame.id).toBe(String(event.id));
+    expect(frame.data.id).toBe(event.id);
+    expect(frame.data.session_id).toBe('sse-live');
+    
+    // The same fan-out delivers filter updates for unseen values
+    const update = await client.next('filters_updated');
+    expect(update.data.source_apps).toContain('sse-live-app');
+  } finally {
+    client.close();
+  }
+});
+
+test('Last-Event-ID replays only the missed events', async () => {
+  const seen = await postEvent({ session_id: 'sse-resume' });
+  const missed = [await postEvent({ session_id: 'sse-resume' }), await postEvent({ session_id: 'sse-resume' })];
+  
+  const client = await connectSse({ 'Last-Event-ID': String(seen.id) });
+  try {
+    await client.next('event');
+    await client.next('event');
+    await sleep(50);
+    
+    const events = client.frames.filter(frame => frame.event === 'event');
+    expect(events.map(frame => frame.data.id)).toEqual(missed.map(event => event.id));
+    expect(client.frames.some(frame => frame.event === 'initial')).toBe(false);
+  } finally {
+    client.close();
+  }
+});
+
+test('closing the stream unregisters the client', async () => {
+  const before = getSseClientCount();
+  const client = await connectSse();
+  await client.next('initial');
+  expect(getSseClientCount()).toBe(before + 1);
+  
+  client.close();
+  await sleep(100);
+  
+  expect(getSseClientCount()).toBe(before);
+});
@@ -0,0 +1,45 @@
+import { afterEach, beforeEach, expect, test } from 'bun:test';
+import { getClientCount, startStatsBroadcast, stopStatsBroadcast } from '../src/websocket';
+import { makeEvent, resetDatabase, seedEvents, setConfig } from './helpers';
+import { connectClient, sleep } from './server';
+
+beforeEach(resetDatabase);
+afterEach(stopStatsBroadcast);
+
+test('heartbeat frames arrive at roughly the configured interval', async () => {
+  const interval = 100;
+  setConfig({ WS_STATS_INTERVAL_MS: interval });
+  await seedEvents([makeEvent(), makeEvent()]);
+  const client = await connectClient();
+  try {
+    startStatsBroadcast();
+    await sleep(interval * 5 + interval / 2);
+    
+    const heartbeats = client.messages.filter(message => message.type === 'heartbeat');
+    expect(heartbeats.length).toBeGreaterThanOrEqual(4);
+    expect(heartbeats.length).toBeLessThanOrEqual(6);
+    
+    const gaps = heartbeats.slice(1).map((frame, i) => frame.data.time - heartbeats[i].data.time);
+    gaps.forEach(gap => {
+      expect(gap).toBeGreaterThanOrEqual(interval * 0.5);
+      expect(gap).toBeLessThanOrEqual(interval * 2);
+    });
+    
+    expect(heartbeats[0].data.count).toBe(2);
+    expect(heartbeats[0].data.clients).toBe(getClientCount());
+  } finally {
+    client.close();
+  }
+});
+
+test('no heartbeat frames are sent while disabled', async () => {
+  setConfig({ WS_STATS_INTERVAL_MS: 0 });
+  const client = await connectClient();
+  try {
+    startStatsBroadcast();
+    await sleep(300);
+    expect(client.messages.some(message => message.type === 'heartbeat')).toBe(false);
+  } finally {
+    client.close();
+  }
+});
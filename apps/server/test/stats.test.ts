@@ -0,0 +1,56 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeEvent, resetDatabase, seedEvents } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+async function getStats(query: string = ''): Promise<any> {
+  const response = await request(`/events/stats${query}`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('counts are grouped by type, source and session', async () => {
+  await seedEvents([
+    makeEvent({ source_app: 'web', session_id: 's1', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'web', session_id: 's1', hook_event_type: 'PostToolUse' }),
+    makeEvent({ source_app: 'web', session_id: 's2', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'cli', session_id: 's3', hook_event_type: 'Stop' })
+  ]);
+  
+  const stats = await getStats();
+  
+  expect(stats.total).toBe(4);
+  expect(stats.by_type).toEqual({ PreToolUse: 2, PostToolUse: 1, Stop: 1 });
+  expect(stats.by_source).toEqual({ web: 3, cli: 1 });
+  expect(stats.by_session).toEqual({ s1: 2, s2: 1, s3: 1 });
+});
+
+test('since scopes every breakdown to the window', async () => {
+  const now = Date.now();
+  await seedEvents([
+    makeEvent({ source_app: 'old', hook_event_type: 'Stop', timestamp: now - 60000 }),
+    makeEvent({ source_app: 'new', hook_event_type: 'PreToolUse', timestamp: now - 1000 })
+  ]);
+  
+  const stats = await getStats(`?since=${now - 10000}`);
+  
+  expect(stats.total).toBe(1);
+  expect(stats.by_type).toEqual({ PreToolUse: 1 });
+  expect(stats.by_source).toEqual({ new: 1 });
+});
+
+test('an empty database has zero counts', async () => {
+  const stats = await getStats();
+  
+  expect(stats.total).toBe(0);
+  expect(stats.by_type).toEqual({});
+  expect(stats.by_source).toEqual({});
+  expect(stats.by_session).toEqual({});
+});
+
+test('a non-numeric since is rejected', async () => {
+  const response = await request('/events/stats?since=today');
+  
+  expect(response.status).toBe(400);
+});
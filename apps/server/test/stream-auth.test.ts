@@ -0,0 +1,110 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { ADMIN_KEY, adminHeaders, resetDatabase, setConfig, signJwt } from './helpers';
+import { connectClient, connectSse, request, serverSocketOf, wsUrl } from './server';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ JWT_SECRET: SECRET });
+});
+
+const tokenFor = (sub: string) => signJwt({ sub, exp: Math.floor(Date.now() / 1000) + 3600 }, SECRET);
+
+// Resolves true if the socket opened, false if the upgrade was refused
+function opens(ws: WebSocket): Promise<boolean> {
+  return new Promise(resolve => {
+    ws.addEventListener('open', () => {
+      ws.close();
+      resolve(true);
+    });
+    ws.addEventListener('error', () => resolve(false));
+    ws.addEventListener('close', () => resolve(false));
+  });
+}
+
+test('an upgrade without a token is refused with 401 before upgrading', async () => {
+  const response = await request('/stream');
+  
+  expect(response.status).toBe(401);
+  expect(response.headers.get('www-authenticate')).toBe('Bearer');
+  expect((await response.json() as any).error.code).toBe('UNAUTHORIZED');
+  expect(await opens(new WebSocket(wsUrl()))).toBe(false);
+});
+
+test('an invalid token is refused', async () => {
+  const forged = signJwt({ sub: 'mallory', exp: Math.floor(Date.now() / 1000) + 3600 }, 'wrong-secret');
+  
+  expect((await request(`/stream?token=${forged}`)).status).toBe(401);
+  expect(await opens(new WebSocket(wsUrl(`/stream?token=${forged}`)))).toBe(false);
+});
+
+test('a token in the query string authenticates the upgrade', async () => {
+  const client = await connectClient(`/stream?token=${tokenFor('alice')}`);
+  try {
+    expect((await serverSocketOf(client)).data.subject).toBe('alice');
+  } finally {
+    client.close();
+  }
+});
+
+test('a token offered as a subprotocol authenticates the upgrade', async () => {
+  const client = await connectClient('/stream', ['bearer', tokenFor('bob')]);
+  try {
+    // Only the marker protocol is echoed back, never the token
+    expect(client.ws.protocol).toBe('bearer');
+    expect((await serverSocketOf(client)).data.subject).toBe('bob');
+  } finally {
+    client.close();
+  }
+});
+
+test('with only API_KEY set the key is required', async () => {
+  setConfig({ JWT_SECRET: undefined, API_KEY: ADMIN_KEY });
+  
+  expect((await request('/stream')).status).toBe(401);
+  expect((await request('/events/stream')).status).toBe(401);
+  expect((await request('/stream?token=wrong-key')).status).toBe(401);
+  expect(await opens(new WebSocket(wsUrl('/stream?token=wrong-key')))).toBe(false);
+});
+
+test('with only API_KEY set the key is accepted as a header, query or subprotocol', async () => {
+  setConfig({ JWT_SECRET: undefined, API_KEY: ADMIN_KEY });
+  
+  const sse = await connectSse(adminHeaders);
+  expect(sse.response.status).toBe(200);
+  sse.close();
+  
+  const byQuery = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  try {
+    expect((await serverSocketOf(byQuery)).data.subject).toBe('admin');
+  } finally {
+    byQuery.close();
+  }
+  
+  const byProtocol = await connectClient('/stream', ['bearer', ADMIN_KEY]);
+  try {
+    expect(byProtocol.ws.protocol).toBe('bearer');
+    expect((await serverSocketOf(byProtocol)).data.subject).toBe('admin');
+  } finally {
+    byProtocol.close();
+  }
+});
+
+test('the API key is accepted alongside JWTs', async () => {
+  setConfig({ API_KEY: ADMIN_KEY });
+  
+  const client = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  try {
+    expect((await serverSocketOf(client)).data.subject).toBe('admin');
+  } finally {
+    client.close();
+  }
+});
+
+test('without JWT_SECRET or API_KEY the stream stays open to everyone', async () => {
+  setConfig({ JWT_SECRET: undefined, API_KEY: undefined });
+  
+  const client = await connectClient();
+  client.close();
+});
@@ -0,0 +1,33 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  await insertEvents([
+    makeEvent({ source_app: 'preview-a', hook_event_type: 'PreToolUse' }),
+    makeEvent({ source_app: 'preview-a', hook_event_type: 'PostToolUse' }),
+    makeEvent({ source_app: 'preview-a', hook_event_type: 'PreToolUse', session_id: 'preview-other' }),
+    makeEvent({ source_app: 'preview-b', hook_event_type: 'PreToolUse' })
+  ]);
+});
+
+test('reports how many stored events a filter matches', async () => {
+  const response = await request('/stream/subscriptions/preview?source_app=preview-a&hook_event_type=PreToolUse');
+  
+  expect(response.status).toBe(200);
+  expect(await response.json()).toEqual({ valid: true, matching_event_count: 2 });
+});
+
+test('an empty filter matches everything', async () => {
+  expect(await (await request('/stream/subscriptions/preview')).json()).toEqual({ valid: true, matching_event_count: 4 });
+});
+
+test('unknown filter fields are rejected', async () => {
+  const response = await request('/stream/subscriptions/preview?source_app=preview-a&colour=red');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(400);
+  expect(body.error.details).toEqual({ valid: false, unknownFields: ['colour'] });
+});
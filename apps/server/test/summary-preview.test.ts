@@ -0,0 +1,62 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { summaryPreview } from '../src/event';
+import { resetDatabase, setConfig } from './helpers';
+import { connectClient, postEvent, request } from './server';
+
+beforeEach(resetDatabase);
+
+const LONG = 'Listed the repository, read the README and ran the full test suite twice';
+
+test('previews are off by default', () => {
+  expect(summaryPreview(LONG)).toBeUndefined();
+});
+
+test('short summaries are previewed unchanged', () => {
+  setConfig({ SUMMARY_PREVIEW_CHARS: 20 });
+  
+  expect(summaryPreview('Ran ls')).toBe('Ran ls');
+  expect(summaryPreview('x'.repeat(20))).toBe('x'.repeat(20));
+  expect(summaryPreview(undefined)).toBeUndefined();
+});
+
+test('long summaries are cut to the limit with an ellipsis', () => {
+  setConfig({ SUMMARY_PREVIEW_CHARS: 20 });
+  
+  expect(summaryPreview(LONG)).toBe('Listed the repositor…');
+  // A cut right after a space does not leave it before the ellipsis
+  expect(summaryPreview('Wrote nineteen char more')).toBe('Wrote nineteen char…');
+});
+
+test('multibyte characters are never split', () => {
+  setConfig({ SUMMARY_PREVIEW_CHARS: 4 });
+  
+  expect(summaryPreview('🚀🚀🚀🚀🚀')).toBe('🚀🚀🚀🚀…');
+  expect(summaryPreview('héllo wörld')).toBe('héll…');
+  expect(summaryPreview('日本語のテキスト')).toBe('日本語の…');
+  expect(summaryPreview('🚀🚀🚀🚀')).toBe('🚀🚀🚀🚀');
+});
+
+test('listings carry the preview and the single-event endpoint keeps the full summary', async () => {
+  setConfig({ SUMMARY_PREVIEW_CHARS: 20 });
+  const event = await postEvent({ session_id: 'preview-1', summary: LONG });
+  
+  const [listed] = await (await request('/events/recent')).json() as any[];
+  expect(listed.summary_preview).toBe('Listed the repositor…');
+  
+  const single = await (await request(`/events/${event.id}`)).json() as any;
+  expect(single.summary).toBe(LONG);
+  expect(single.summary_preview).toBe('Listed the repositor…');
+});
+
+test('broadcast events include the preview', async () => {
+  setConfig({ SUMMARY_PREVIEW_CHARS: 6 });
+  const client = await connectClient();
+  try {
+    await postEvent({ session_id: 'preview-2', summary: 'Refactored the parser' });
+    const frame = await client.next('event');
+    
+    expect(frame.data.summary_preview).toBe('Refact…');
+  } finally {
+    client.close();
+  }
+});
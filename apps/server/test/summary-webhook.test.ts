@@ -0,0 +1,115 @@
+import { afterAll, beforeEach, expect, test } from 'bun:test';
+import { resetDatabase, setConfig } from './helpers';
+import { connectClient, postEvent, request, sleep } from './server';
+
+// Stand-in summarization service; each test decides how it answers
+let answer: (event: any) => Response | Promise<Response> = () => new Response('', { status: 500 });
+const received: any[] = [];
+const stub = Bun.serve({
+  port: 0,
+  async fetch(req) {
+    const event = await req.json();
+    received.push(event);
+    return answer(event);
+  }
+});
+
+afterAll(() => stub.stop(true));
+
+beforeEach(() => {
+  resetDatabase();
+  received.length = 0;
+  setConfig({ SUMMARY_WEBHOOK_URL: `http://localhost:${stub.port}/summarize` });
+});
+
+async function storedSummary(id: number | undefined): Promise<string | null | undefined> {
+  return (await (await request(`/events/${id}`)).json() as any).summary;
+}
+
+test('a JSON summary is attached and broadcast as event_updated', async () => {
+  answer = event => Response.json({ summary: `Ran ${event.payload.tool_input.command}` });
+  const client = await connectClient();
+  try {
+    const event = await postEvent({ session_id: 'summary-webhook-json' });
+    const update = await client.next('event_updated');
+    
+    expect(received).toHaveLength(1);
+    expect(received[0].id).toBe(event.id);
+    expect(update.data.id).toBe(event.id);
+    expect(update.data.summary).toBe('Ran ls');
+    expect(await storedSummary(event.id)).toBe('Ran ls');
+  } finally {
+    client.close();
+  }
+});
+
+test('a plain-text answer is used as the summary, trimmed', async () => {
+  answer = () => new Response('  Listed files\n', { headers: { 'Content-Type': 'text/plain' } });
+  const client = await connectClient();
+  try {
+    const event = await postEvent({ session_id: 'summary-webhook-text' });
+    const update = await client.next('event_updated');
+    
+    expect(update.data.summary).toBe('Listed files');
+    expect(await storedSummary(event.id)).toBe('Listed files');
+  } finally {
+    client.close();
+  }
+});
+
+test('events that already have a summary are not sent', async () => {
+  answer = () => Response.json({ summary: 'replaced' });
+  
+  const event = await postEvent({ session_id: 'summary-webhook-has', summary: 'Written by the hook' });
+  await sleep(100);
+  
+  expect(received).toHaveLength(0);
+  expect(await storedSummary(event.id)).toBe('Written by the hook');
+});
+
+test('a failing webhook leaves the event unsummarized without affecting ingestion', async () => {
+  answer = () => new Response('overloaded', { status: 503 });
+  const client = await connectClient();
+  try {
+    const event = await postEvent({ session_id: 'summary-webhook-fail' });
+    await sleep(100);
+    
+    expect(received).toHaveLength(1);
+    expect(await storedSummary(event.id)).toBeFalsy();
+    expect(client.messages.some(message => message.type === 'event_updated')).toBe(false);
+  } finally {
+    client.close();
+  }
+});
+
+test('an empty or malformed answer is ignored', async () => {
+  answer = () => Response.json({ text: 'wrong field' });
+  const event = await postEvent({ session_id: 'summary-webhook-empty' });
+  await sleep(100);
+  expect(await storedSummary(event.id)).toBeFalsy();
+  
+  answer = () => new Response('{not json', { headers: { 'Content-Type': 'application/json' } });
+  const other = await postEvent({ session_id: 'summary-webhook-empty' });
+  await sleep(100);
+  expect(await storedSummary(other.id)).toBeFalsy();
+  expect(received).toHaveLength(2);
+});
+
+test('an unreachable or slow webhook does not delay the POST', async () => {
+  answer = async () => {
+    await sleep(500);
+    return Response.json({ summary: 'too late' });
+  };
+  setConfig({ SUMMARY_WEBHOOK_TIMEOUT_MS: 50 });
+  
+  const started = performance.now();
+  const event = await postEvent({ session_id: 'summary-webhook-slow' });
+  expect(performance.now() - started).toBeLessThan(400);
+  
+  await sleep(600);
+  expect(await storedSummary(event.id)).toBeFalsy();
+  
+  setConfig({ SUMMARY_WEBHOOK_URL: 'http://127.0.0.1:1/summarize' });
+  const unreachable = await postEvent({ session_id: 'summary-webhook-slow' });
+  expect(unreachable.id).toBeDefined();
+});
@@ -0,0 +1,71 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getTheme, insertTheme } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeTheme, resetDatabase, setConfig, signJwt } from './helpers';
+import { request } from './server';
+import type { Theme } from '../src/types';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(async () => {
+  resetDatabase();
+  await store('ocean', 'alice');
+  await store('forest', 'bob');
+  await store('hidden', 'alice', false);
+});
+
+async function store(id: string, authorId: string, isPublic: boolean = true): Promise<void> {
+  const now = Date.now();
+  await insertTheme({
+    ...makeTheme({ name: id, displayName: id, isPublic }),
+    id,
+    authorId,
+    createdAt: now,
+    updatedAt: now,
+    downloadCount: 0,
+    rating: 0,
+    ratingCount: 0
+  } as Theme);
+}
+
+async function fetchIds(ids: string, headers: Record<string, string> = {}): Promise<string[]> {
+  const response = await request(`/api/themes?ids=${ids}`, { headers });
+  expect(response.status).toBe(200);
+  return ((await response.json() as any).data as Theme[]).map(theme => theme.id);
+}
+
+test('existing ids are returned in the requested order and missing ones are absent', async () => {
+  expect(await fetchIds('forest,missing,ocean')).toEqual(['forest', 'ocean']);
+});
+
+test('repeated ids and stray commas are collapsed', async () => {
+  expect(await fetchIds('ocean,,ocean, forest ,')).toEqual(['ocean', 'forest']);
+});
+
+test('an empty list returns no themes', async () => {
+  expect(await fetchIds('')).toEqual([]);
+});
+
+test('private themes are only returned to their author or an admin', async () => {
+  expect(await fetchIds('hidden,ocean')).toEqual(['ocean']);
+  
+  setConfig({ JWT_SECRET: SECRET, API_KEY: ADMIN_KEY });
+  const token = (sub: string) => ({ Authorization: `Bearer ${signJwt({ sub, exp: Math.floor(Date.now() / 1000) + 60 }, SECRET)}` });
+  expect(await fetchIds('hidden,ocean', token('bob'))).toEqual(['ocean']);
+  expect(await fetchIds('hidden,ocean', token('alice'))).toEqual(['hidden', 'ocean']);
+  expect(await fetchIds('hidden,ocean', adminHeaders)).toEqual(['hidden', 'ocean']);
+});
+
+test('fetching by id does not count as a download', async () => {
+  await fetchIds('ocean');
+  
+  expect(getTheme('ocean')!.downloadCount).toBe(0);
+});
+
+test('more ids than a page holds is a 400', async () => {
+  setConfig({ MAX_PAGE_SIZE: 2 });
+  
+  const response = await request('/api/themes?ids=a,b,c');
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+});
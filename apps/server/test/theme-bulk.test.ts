@@ -0,0 +1,70 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getThemes } from '../src/db';
+import { makeTheme, resetDatabase, setConfig } from './helpers';
+import { requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+function bulk(themes: unknown): Promise<Response> {
+  return requestJson('/api/themes/bulk', 'POST', themes);
+}
+
+test('a clean batch is created with one result per theme, in order', async () => {
+  const names = ['starter-1', 'starter-2', 'starter-3'];
+  
+  const response = await bulk(names.map(name => makeTheme({ name })));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(201);
+  expect(body.success).toBe(true);
+  expect(body.data.map((result: any) => result.data.name)).toEqual(names);
+  expect(new Set(body.data.map((result: any) => result.data.id)).size).toBe(3);
+  expect(getThemes({ limit: 10 })).toHaveLength(3);
+});
+
+test('duplicates within the batch are reported, the rest created', async () => {
+  const response = await bulk([makeTheme({ name: 'twin' }), makeTheme({ name: 'twin' }), makeTheme({ name: 'single' })]);
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(207);
+  expect(body.success).toBe(false);
+  expect(body.data.map((result: any) => result.success)).toEqual([true, false, true]);
+  expect(body.data[1].validationErrors[0]).toMatchObject({ field: 'name', code: 'DUPLICATE' });
+  expect(getThemes({ limit: 10 }).map(theme => theme.name).sort()).toEqual(['single', 'twin']);
+});
+
+test('duplicates of stored themes are reported, not skipped silently', async () => {
+  await bulk([makeTheme({ name: 'existing' })]);
+  
+  const response = await bulk([makeTheme({ name: 'existing' }), makeTheme({ name: 'fresh' })]);
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(207);
+  expect(body.data[0].success).toBe(false);
+  expect(body.data[0].validationErrors.some((error: any) => error.code === 'DUPLICATE')).toBe(true);
+  expect(body.data[1].success).toBe(true);
+});
+
+test('invalid entries fail individually', async () => {
+  const response = await bulk([makeTheme({ name: 'ok' }), makeTheme({ name: 'broken', colors: { primary: 'nope' } }), 'not a theme']);
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(207);
+  expect(body.data.map((result: any) => result.success)).toEqual([true, false, false]);
+  expect(body.data[2].error).toBe('Theme must be an object');
+});
+
+test('a batch where nothing is valid is a 400', async () => {
+  const response = await bulk([makeTheme({ name: 'bad', colors: {} })]);
+  
+  expect(response.status).toBe(400);
+  expect(getThemes({ limit: 10 })).toHaveLength(0);
+});
+
+test('the body must be a non-empty array within the page size', async () => {
+  setConfig({ MAX_PAGE_SIZE: 2 });
+  
+  expect((await bulk({ name: 'object' })).status).toBe(400);
+  expect((await bulk([])).status).toBe(400);
+  expect((await bulk([1, 2, 3].map(n => makeTheme({ name: `over-${n}` })))).status).toBe(413);
+});
@@ -0,0 +1,101 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { deleteTheme, insertTheme, updateTheme } from '../src/db';
+import { generatePalette } from '../src/theme';
+import { makeTheme, resetDatabase, setConfig, signJwt } from './helpers';
+import { request, requestJson } from './server';
+import type { Theme } from '../src/types';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(resetDatabase);
+
+// A popular theme, stored directly so it can carry stats
+async function insertSource(overrides: Partial<Theme> = {}): Promise<Theme> {
+  const now = Date.now() - 60_000;
+  return insertTheme({
+    ...makeTheme({ name: 'sunset', displayName: 'Sunset', description: 'Warm', tags: ['warm', 'orange'] }),
+    id: crypto.randomUUID(),
+    authorId: 'alice',
+    createdAt: now,
+    updatedAt: now,
+    downloadCount: 42,
+    rating: 4.5,
+    ratingCount: 10,
+    ...overrides
+  } as Theme);
+}
+
+function clone(id: string, body: Record<string, unknown> = {}, headers: Record<string, string> = {}): Promise<Response> {
+  return requestJson(`/api/themes/${id}/clone`, 'POST', body, headers);
+}
+
+test('a clone copies colors and tags but resets stats and timestamps', async () => {
+  const source = await insertSource();
+  
+  const response = await clone(source.id);
+  const copy = (await response.json() as any).data;
+  
+  expect(response.status).toBe(201);
+  expect(copy.id).not.toBe(source.id);
+  expect(copy.name).toBe('sunset-copy');
+  expect(copy.displayName).toBe('Sunset (Copy)');
+  expect(copy.colors).toEqual(source.colors);
+  expect(copy.tags).toEqual(source.tags);
+  expect(copy.downloadCount).toBe(0);
+  expect(copy.rating).toBe(0);
+  expect(copy.ratingCount).toBe(0);
+  expect(copy.isPublic).toBe(false);
+  expect(copy.createdAt).toBeGreaterThan(source.createdAt);
+  expect(copy.updatedAt).toBeGreaterThanOrEqual(copy.createdAt);
+});
+
+test('the clone belongs to the caller, not the source author', async () => {
+  setConfig({ JWT_SECRET: SECRET });
+  const source = await insertSource();
+  const token = signJwt({ sub: 'bob', exp: Math.floor(Date.now() / 1000) + 3600 }, SECRET);
+  
+  const response = await clone(source.id, { name: 'my-sunset' }, { 'Authorization': `Bearer ${token}` });
+  const copy = (await response.json() as any).data;
+  
+  expect(response.status).toBe(201);
+  expect(copy.authorId).toBe('bob');
+  expect(copy.name).toBe('my-sunset');
+});
+
+test('the clone is independent of the original', async () => {
+  const source = await insertSource();
+  // Public, so the anonymous reads below can see it
+  const copy = (await (await clone(source.id, { isPublic: true })).json() as any).data;
+  
+  await updateTheme(source.id, { colors: generatePalette('#993300'), tags: ['changed'] });
+  const fetched = (await (await request(`/api/themes/${copy.id}`)).json() as any).data;
+  expect(fetched.colors).toEqual(source.colors);
+  expect(fetched.tags).toEqual(['warm', 'orange']);
+  
+  deleteTheme(source.id);
+  expect((await request(`/api/themes/${copy.id}`)).status).toBe(200);
+});
+
+test('repeated clones pick the next free name', async () => {
+  const source = await insertSource();
+  
+  const names = [];
+  for (let i = 0; i < 3; i++) {
+    names.push((await (await clone(source.id)).json() as any).data.name);
+  }
+  
+  expect(names).toEqual(['sunset-copy', 'sunset-copy-2', 'sunset-copy-3']);
+});
+
+test('cloning a missing theme is a 404', async () => {
+  const response = await clone('no-such-theme');
+  
+  expect(response.status).toBe(404);
+  expect((await response.json() as any).error.code).toBe('THEME_NOT_FOUND');
+});
+
+test("another author's private theme cannot be cloned", async () => {
+  const source = await insertSource({ isPublic: false });
+  
+  expect((await clone(source.id)).status).toBe(404);
+});
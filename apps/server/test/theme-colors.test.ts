@@ -0,0 +1,55 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { generatePalette, validateColors } from '../src/theme';
+import { makeTheme, resetDatabase } from './helpers';
+import { requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+const palette = generatePalette('#3366cc')!;
+
+test('3, 6 and 8 digit hex colors are valid', () => {
+  for (const color of ['#fff', '#FFF', '#1a2b3c', '#1A2B3C', '#1a2b3c80']) {
+    expect(validateColors({ ...palette, primary: color })).toEqual([]);
+  }
+});
+
+test('malformed values get one error each, naming the field', () => {
+  const errors = validateColors({
+    ...palette,
+    primary: 'not-a-color',
+    primaryHover: '#12',
+    primaryLight: '#12345',
+    primaryDark: '#gggggg',
+    bgPrimary: '123456',
+    bgSecondary: '#1234567'
+  });
+  
+  expect(errors.map(error => error.field)).toEqual([
+    'colors.primary',
+    'colors.primaryHover',
+    'colors.primaryLight',
+    'colors.primaryDark',
+    'colors.bgPrimary',
+    'colors.bgSecondary'
+  ]);
+  expect(errors.every(error => error.code === 'INVALID_COLOR')).toBe(true);
+});
+
+test('missing colors are reported as required', () => {
+  const { focusRing, ...incomplete } = palette;
+  
+  expect(validateColors(incomplete)).toEqual([{
+    field: 'colors.focusRing',
+    message: 'Color focusRing is required',
+    code: 'REQUIRED'
+  }]);
+});
+
+test('a theme with a bad color is not saved', async () => {
+  const response = await requestJson('/api/themes', 'POST', makeTheme({ colors: { ...palette, accentError: 'reddish' } }));
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(400);
+  expect(body.error.code).toBe('THEME_INVALID');
+  expect(body.error.details.validationErrors).toEqual([expect.objectContaining({ field: 'colors.accentError', code: 'INVALID_COLOR' })]);
+});
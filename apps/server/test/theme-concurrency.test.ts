@@ -0,0 +1,58 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getTheme, updateTheme } from '../src/db';
+import { makeTheme, resetDatabase } from './helpers';
+import { requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+async function createTheme(): Promise<any> {
+  const response = await requestJson('/api/themes', 'POST', makeTheme({ name: 'shared' }));
+  return (await response.json() as any).data;
+}
+
+function putTheme(id: string, body: Record<string, unknown>): Promise<Response> {
+  return requestJson(`/api/themes/${id}`, 'PUT', body);
+}
+
+test('an update carrying the current updatedAt succeeds and bumps it', async () => {
+  const theme = await createTheme();
+  
+  const response = await putTheme(theme.id, { displayName: 'Mine', updatedAt: theme.updatedAt });
+  const updated = (await response.json() as any).data;
+  
+  expect(response.status).toBe(200);
+  expect(updated.displayName).toBe('Mine');
+  expect(updated.updatedAt).toBeGreaterThan(theme.updatedAt);
+});
+
+test('a stale updatedAt is rejected with 409 and the current copy', async () => {
+  const theme = await createTheme();
+  await putTheme(theme.id, { displayName: 'First', updatedAt: theme.updatedAt });
+  
+  // The second editor still holds the original version
+  const response = await putTheme(theme.id, { displayName: 'Second', updatedAt: theme.updatedAt });
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(409);
+  expect(body.error.code).toBe('THEME_CONFLICT');
+  expect(body.error.details.current.displayName).toBe('First');
+  expect(getTheme(theme.id)!.displayName).toBe('First');
+});
+
+test('an update without updatedAt is refused', async () => {
+  const theme = await createTheme();
+  
+  const response = await putTheme(theme.id, { displayName: 'Blind' });
+  
+  expect(response.status).toBe(428);
+  expect((await response.json() as any).error.code).toBe('THEME_VERSION_REQUIRED');
+  expect(getTheme(theme.id)!.displayName).toBe('Test Theme');
+});
+
+test('the stored write only applies while updatedAt still matches', async () => {
+  const theme = await createTheme();
+  
+  expect(await updateTheme(theme.id, { displayName: 'Stale', updatedAt: theme.updatedAt + 5 }, theme.updatedAt - 1)).toBe(false);
+  expect(await updateTheme(theme.id, { displayName: 'Fresh', updatedAt: theme.updatedAt + 5 }, theme.updatedAt)).toBe(true);
+  expect(getTheme(theme.id)!.displayName).toBe('Fresh');
+});
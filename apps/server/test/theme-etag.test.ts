@@ -0,0 +1,64 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeTheme, resetDatabase } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+async function createTheme(): Promise<any> {
+  const response = await requestJson('/api/themes', 'POST', makeTheme({ name: 'etag-theme' }));
+  return (await response.json() as any).data;
+}
+
+test('the ETag is stable across reads', async () => {
+  const theme = await createTheme();
+  
+  const first = await request(`/api/themes/${theme.id}`);
+  const second = await request(`/api/themes/${theme.id}`);
+  
+  expect(first.status).toBe(200);
+  expect(first.headers.get('etag')).toMatch(/^".+"$/);
+  expect(second.headers.get('etag')).toBe(first.headers.get('etag'));
+});
+
+test('a matching If-None-Match yields 304 with no body', async () => {
+  const theme = await createTheme();
+  const etag = (await request(`/api/themes/${theme.id}`)).headers.get('etag')!;
+  
+  for (const ifNoneMatch of [etag, `W/${etag}`, `"other", ${etag}`, '*']) {
+    const response = await request(`/api/themes/${theme.id}`, { headers: { 'If-None-Match': ifNoneMatch } });
+    expect(response.status).toBe(304);
+    expect(response.headers.get('etag')).toBe(etag);
+    expect(await response.text()).toBe('');
+  }
+  
+  const stale = await request(`/api/themes/${theme.id}`, { headers: { 'If-None-Match': '"something-else"' } });
+  expect(stale.status).toBe(200);
+});
+
+test('the ETag changes after an update', async () => {
+  const theme = await createTheme();
+  const before = (await request(`/api/themes/${theme.id}`)).headers.get('etag')!;
+  // updatedAt has millisecond resolution
+  await Bun.sleep(5);
+  
+  const update = await requestJson(`/api/themes/${theme.id}`, 'PUT', { displayName: 'Renamed', updatedAt: theme.updatedAt });
+  expect(update.status).toBe(200);
+  
+  const after = await request(`/api/themes/${theme.id}`, { headers: { 'If-None-Match': before } });
+  expect(after.status).toBe(200);
+  expect(after.headers.get('etag')).not.toBe(before);
+  expect((await after.json() as any).data.displayName).toBe('Renamed');
+});
+
+test('a 304 is not counted as a download', async () => {
+  const theme = await createTheme();
+  const etag = (await request(`/api/themes/${theme.id}`)).headers.get('etag')!;
+  
+  for (let i = 0; i < 3; i++) {
+    expect((await request(`/api/themes/${theme.id}`, { headers: { 'If-None-Match': etag } })).status).toBe(304);
+  }
+  
+  // The body carries the count from before this read is counted
+  const response = await request(`/api/themes/${theme.id}`);
+  expect((await response.json() as any).data.downloadCount).toBe(1);
+});
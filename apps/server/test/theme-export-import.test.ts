@@ -0,0 +1,77 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { makeTheme, resetDatabase } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+async function createTheme(overrides: Record<string, unknown> = {}): Promise<any> {
+  const response = await requestJson('/api/themes', 'POST', makeTheme(overrides));
+  expect(response.status).toBe(201);
+  return (await response.json() as any).data;
+}
+
+async function exportTheme(id: string): Promise<any> {
+  const response = await request(`/api/themes/${id}/export`);
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+function importTheme(bundle: unknown, query: string): Promise<Response> {
+  return requestJson(`/api/themes/import?${query}`, 'POST', bundle);
+}
+
+test('a theme round-trips through export and import', async () => {
+  const original = await createTheme({ name: 'ocean', displayName: 'Ocean', description: 'Blue', tags: ['blue'], authorId: 'alice' });
+  
+  const bundle = await exportTheme(original.id);
+  for (const field of ['id', 'authorId', 'downloadCount', 'rating', 'ratingCount', 'createdAt', 'updatedAt']) {
+    expect(bundle.theme).not.toHaveProperty(field);
+  }
+  
+  const response = await importTheme(bundle, 'authorId=bob');
+  const imported = (await response.json() as any).data;
+  
+  expect(response.status).toBe(201);
+  expect(imported.id).not.toBe(original.id);
+  expect(imported.authorId).toBe('bob');
+  expect(imported.isPublic).toBe(false);
+  expect(imported.createdAt).toBeGreaterThanOrEqual(original.createdAt);
+  expect(imported.downloadCount).toBe(0);
+  for (const field of ['name', 'displayName', 'description', 'colors', 'tags']) {
+    expect(imported[field]).toEqual(original[field]);
+  }
+});
+
+test('importing over an existing name needs overwrite=true', async () => {
+  const original = await createTheme({ name: 'forest', authorId: 'carol' });
+  const bundle = await exportTheme(original.id);
+  bundle.theme.displayName = 'Forest v2';
+  
+  const conflict = await importTheme(bundle, 'authorId=carol');
+  expect(conflict.status).toBe(409);
+  expect((await conflict.json() as any).error.code).toBe('THEME_DUPLICATE');
+  
+  const replaced = await importTheme(bundle, 'authorId=carol&overwrite=true');
+  const theme = (await replaced.json() as any).data;
+  expect(replaced.ok).toBe(true);
+  expect(theme.id).toBe(original.id);
+  expect(theme.displayName).toBe('Forest v2');
+});
+
+test('overwrite never replaces another author\'s theme', async () => {
+  const original = await createTheme({ name: 'desert', authorId: 'dave' });
+  const bundle = await exportTheme(original.id);
+  bundle.theme.id = original.id;
+  
+  const response = await importTheme(bundle, 'authorId=eve&overwrite=true');
+  
+  expect(response.status).toBe(403);
+  expect((await (await request(`/api/themes/${original.id}`)).json() as any).data.authorId).toBe('dave');
+});
+
+test('exporting an unknown theme is a 404', async () => {
+  const response = await request('/api/themes/missing-theme/export');
+  
+  expect(response.status).toBe(404);
+  expect((await response.json() as any).error.code).toBe('THEME_NOT_FOUND');
+});
@@ -0,0 +1,65 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { getThemes } from '../src/db';
+import { checkContrast, generatePalette, validateColors } from '../src/theme';
+import { resetDatabase } from './helpers';
+import { requestJson } from './server';
+
+beforeEach(resetDatabase);
+
+const HEX = /^#[0-9a-f]{6}$/i;
+
+function generate(body: unknown, query: string = ''): Promise<Response> {
+  return requestJson(`/api/themes/generate${query}`, 'POST', body);
+}
+
+test('the same seed always gives the same palette', () => {
+  expect(generatePalette('#3366cc')).toEqual(generatePalette('#3366cc')!);
+  expect(generatePalette('#36c')).toEqual(generatePalette('#3366cc')!);
+  expect(generatePalette('#3366cc', 'dark')).not.toEqual(generatePalette('#3366cc', 'light')!);
+});
+
+test('every generated color is valid hex and the palette is complete', () => {
+  for (const seed of ['#3366cc', '#ff0000', '#000000', '#ffffff', '#808080']) {
+    for (const mode of ['light', 'dark'] as const) {
+      const palette = generatePalette(seed, mode)!;
+      
+      expect(Object.values(palette).every(color => HEX.test(color))).toBe(true);
+      expect(validateColors(palette)).toEqual([]);
+    }
+  }
+});
+
+test('generated palettes meet the contrast minimums', () => {
+  for (const seed of ['#3366cc', '#ffcc00', '#111111']) {
+    expect(checkContrast(generatePalette(seed, 'light')!)).toEqual([]);
+    expect(checkContrast(generatePalette(seed, 'dark')!)).toEqual([]);
+  }
+});
+
+test('generating without save returns the theme and stores nothing', async () => {
+  const response = await generate({ seed: '#3366cc', mode: 'dark', name: 'generated-dark', displayName: 'Generated Dark' });
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(200);
+  expect(body.data.colors).toEqual(generatePalette('#3366cc', 'dark'));
+  expect(body.data.tags).toEqual(['dark', 'generated']);
+  expect(body.data).not.toHaveProperty('id');
+  expect(getThemes({})).toHaveLength(0);
+});
+
+test('save=true stores the generated theme', async () => {
+  const response = await generate({ seed: '#3366cc', name: 'generated-saved', displayName: 'Saved' }, '?save=true');
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(201);
+  expect(body.data.id).toBeString();
+  expect(getThemes({}).map(theme => theme.name)).toEqual(['generated-saved']);
+});
+
+test('a bad seed or mode is rejected', async () => {
+  for (const body of [{ seed: 'blue' }, { seed: '#12345' }, {}, { seed: '#3366cc', mode: 'sepia' }]) {
+    const response = await generate(body);
+    expect(response.status).toBe(400);
+    expect((await response.json() as any).error.code).toBe('THEME_INVALID');
+  }
+});
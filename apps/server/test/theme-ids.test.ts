@@ -0,0 +1,68 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { slugify } from '../src/theme';
+import { makeTheme, resetDatabase, setConfig } from './helpers';
+import { requestJson } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ THEME_ID_STRATEGY: 'slug' });
+});
+
+async function create(overrides: Record<string, unknown>): Promise<any> {
+  const response = await requestJson('/api/themes', 'POST', makeTheme(overrides));
+  expect(response.status).toBe(201);
+  return (await response.json() as any).data;
+}
+
+test('slugify produces lowercase kebab-case', () => {
+  expect(slugify('My Dark_Theme!')).toBe('my-dark-theme');
+  expect(slugify('  --Ocean   Blue--  ')).toBe('ocean-blue');
+  expect(slugify('v2.0 Release')).toBe('v2-0-release');
+  expect(slugify('!!!')).toBe('');
+});
+
+test('a theme without an id gets a slug of its name', async () => {
+  expect((await create({ name: 'Ocean Blue' })).id).toBe('ocean-blue');
+});
+
+test('colliding slugs get a numeric suffix', async () => {
+  const first = await create({ name: 'midnight', authorId: 'alice' });
+  const second = await create({ name: 'midnight', authorId: 'bob' });
+  const third = await create({ name: 'midnight', authorId: 'carol' });
+  
+  expect([first.id, second.id, third.id]).toEqual(['midnight', 'midnight-2', 'midnight-3']);
+});
+
+test('names that differ only in punctuation share a slug base', async () => {
+  const first = await create({ name: 'ocean-blue' });
+  const second = await create({ name: 'ocean_blue' });
+  
+  expect(first.id).toBe('ocean-blue');
+  expect(second.id).toBe('ocean-blue-2');
+});
+
+test('suffixes stay unique within a bulk batch', async () => {
+  const response = await requestJson('/api/themes/bulk', 'POST', [
+    makeTheme({ name: 'dawn', authorId: 'alice' }),
+    makeTheme({ name: 'dawn', authorId: 'bob' })
+  ]);
+  const body = await response.json() as any;
+  
+  expect(body.data.map((result: any) => result.data.id)).toEqual(['dawn', 'dawn-2']);
+});
+
+test('an explicitly provided id is preserved on import', async () => {
+  const response = await requestJson('/api/themes/import', 'POST', { theme: { ...makeTheme({ name: 'Custom Name' }), id: 'My_Custom-ID' } });
+  
+  expect(response.status).toBe(201);
+  expect((await response.json() as any).data.id).toBe('My_Custom-ID');
+});
+
+test('the random strategy ignores the name', async () => {
+  setConfig({ THEME_ID_STRATEGY: 'random' });
+  
+  const theme = await create({ name: 'Ocean Blue' });
+  
+  expect(theme.id).not.toContain('ocean');
+  expect(theme.id).toMatch(/^[a-z0-9]+$/);
+});
@@ -0,0 +1,85 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertTheme } from '../src/db';
+import { makeTheme, resetDatabase, setConfig, signJwt } from './helpers';
+import { requestJson } from './server';
+import type { Theme } from '../src/types';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(resetDatabase);
+
+function create(overrides: Record<string, unknown>, headers: Record<string, string> = {}): Promise<Response> {
+  return requestJson('/api/themes', 'POST', makeTheme(overrides), headers);
+}
+
+function bearer(sub: string): Record<string, string> {
+  return { Authorization: `Bearer ${signJwt({ sub, exp: Math.floor(Date.now() / 1000) + 60 }, SECRET)}` };
+}
+
+test('an author cannot have two themes with the same name', async () => {
+  expect((await create({ name: 'midnight', authorId: 'alice' })).status).toBe(201);
+  
+  const response = await create({ name: 'midnight', authorId: 'alice', displayName: 'Midnight Again' });
+  const body = await response.json() as any;
+  
+  expect(response.status).toBe(409);
+  expect(body.error.code).toBe('THEME_DUPLICATE');
+  expect(body.error.details.validationErrors).toEqual([
+    expect.objectContaining({ field: 'name', code: 'DUPLICATE' })
+  ]);
+});
+
+test('different authors may reuse a name', async () => {
+  const alice = await create({ name: 'midnight', authorId: 'alice' });
+  const bob = await create({ name: 'midnight', authorId: 'bob' });
+  
+  expect(alice.status).toBe(201);
+  expect(bob.status).toBe(201);
+  const [first, second] = [(await alice.json() as any).data, (await bob.json() as any).data];
+  expect(first.id).not.toBe(second.id);
+});
+
+test('authorless themes share one namespace', async () => {
+  expect((await create({ name: 'anonymous' })).status).toBe(201);
+  expect((await create({ name: 'anonymous' })).status).toBe(409);
+  expect((await create({ name: 'anonymous', authorId: 'alice' })).status).toBe(201);
+});
+
+test('with JWT auth the token subject is the author that must be unique', async () => {
+  setConfig({ JWT_SECRET: SECRET });
+  
+  expect((await create({ name: 'dawn' }, bearer('alice'))).status).toBe(201);
+  // The body cannot dodge the check by naming another author
+  expect((await create({ name: 'dawn', authorId: 'someone-else' }, bearer('alice'))).status).toBe(409);
+  expect((await create({ name: 'dawn' }, bearer('bob'))).status).toBe(201);
+});
+
+test('a bulk create rejects a repeated name within one author', async () => {
+  const response = await requestJson('/api/themes/bulk', 'POST', [
+    makeTheme({ name: 'batch', authorId: 'alice' }),
+    makeTheme({ name: 'batch', authorId: 'alice' }),
+    makeTheme({ name: 'batch', authorId: 'bob' })
+  ]);
+  const results = (await response.json() as any).data;
+  
+  expect(response.status).toBe(207);
+  expect(results.map((result: any) => result.success)).toEqual([true, false, true]);
+  expect(results[1].validationErrors[0]).toMatchObject({ field: 'name', code: 'DUPLICATE' });
+});
+
+test('the database index backs up the check', async () => {
+  const now = Date.now();
+  const theme = (id: string) => ({
+    ...makeTheme({ name: 'indexed' }),
+    id,
+    authorId: 'alice',
+    createdAt: now,
+    updatedAt: now,
+    downloadCount: 0,
+    rating: 0,
+    ratingCount: 0
+  } as Theme);
+  
+  await insertTheme(theme('indexed-1'));
+  await expect(insertTheme(theme('indexed-2'))).rejects.toThrow(/UNIQUE constraint failed/);
+});
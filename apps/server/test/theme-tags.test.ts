@@ -0,0 +1,52 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { invalidateCache } from '../src/cache';
+import { makeTheme, resetDatabase } from './helpers';
+import { request, requestJson } from './server';
+
+beforeEach(async () => {
+  resetDatabase();
+  invalidateCache('');
+  const themes = [
+    makeTheme({ name: 'ocean', tags: ['blue', 'calm'] }),
+    makeTheme({ name: 'sky', tags: ['blue', 'light'] }),
+    makeTheme({ name: 'night', tags: ['dark', 'blue', 'calm'] }),
+    makeTheme({ name: 'plain', tags: [] })
+  ];
+  for (const theme of themes) {
+    expect((await requestJson('/api/themes', 'POST', theme)).status).toBe(201);
+  }
+});
+
+async function tags(query: string = ''): Promise<any> {
+  const response = await request(`/api/themes/tags${query}`);
+  expect(response.status).toBe(200);
+  return (await response.json() as any).data;
+}
+
+test('overlapping tags are listed once, sorted', async () => {
+  expect(await tags()).toEqual(['blue', 'calm', 'dark', 'light']);
+});
+
+test('counts give the number of themes using each tag', async () => {
+  expect(await tags('?counts=true')).toEqual([
+    { tag: 'blue', count: 3 },
+    { tag: 'calm', count: 2 },
+    { tag: 'dark', count: 1 },
+    { tag: 'light', count: 1 }
+  ]);
+});
+
+test('a tag repeated within one theme counts that theme once', async () => {
+  await requestJson('/api/themes', 'POST', makeTheme({ name: 'echo', tags: ['echo', 'echo'] }));
+  
+  const counts = await tags('?counts=true');
+  
+  expect(counts.filter((row: any) => row.tag === 'echo')).toEqual([{ tag: 'echo', count: 1 }]);
+});
+
+test('a new theme invalidates the cached tag list', async () => {
+  await tags();
+  await requestJson('/api/themes', 'POST', makeTheme({ name: 'forest', tags: ['green'] }));
+  
+  expect(await tags()).toContain('green');
+});
@@ -0,0 +1,112 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertTheme } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeTheme, resetDatabase, setConfig, signJwt } from './helpers';
+import { request } from './server';
+import type { Theme } from '../src/types';
+
+const SECRET = 'test-jwt-secret';
+
+beforeEach(async () => {
+  resetDatabase();
+  await store('alice-public', 'alice', true);
+  await store('alice-private', 'alice', false);
+  await store('bob-public', 'bob', true);
+  await store('bob-private', 'bob', false);
+});
+
+async function store(id: string, authorId: string, isPublic: boolean): Promise<void> {
+  const now = Date.now();
+  await insertTheme({
+    ...makeTheme({ name: id, displayName: id, description: 'visibility fixture', isPublic }),
+    id,
+    authorId,
+    createdAt: now,
+    updatedAt: now,
+    downloadCount: 0,
+    rating: 0,
+    ratingCount: 0
+  } as Theme);
+}
+
+function bearer(sub: string): Record<string, string> {
+  return { Authorization: `Bearer ${signJwt({ sub, exp: Math.floor(Date.now() / 1000) + 60 }, SECRET)}` };
+}
+
+async function search(query: string = '', headers: Record<string, string> = {}): Promise<string[]> {
+  const response = await request(`/api/themes${query}`, { headers });
+  expect(response.status).toBe(200);
+  return ((await response.json() as any).data as Theme[]).map(theme => theme.id).sort();
+}
+
+test('anonymous searches never return private themes', async () => {
+  expect(await search()).toEqual(['alice-public', 'bob-public']);
+  expect(await search('?query=private')).toEqual([]);
+  expect(await search('?authorId=alice')).toEqual(['alice-public']);
+  // Asking for private themes narrows to nothing rather than widening
+  expect(await search('?isPublic=false')).toEqual([]);
+});
+
+test('anonymous id lookups never return private themes', async () => {
+  expect(await search('?ids=alice-private,bob-public,bob-private')).toEqual(['bob-public']);
+});
+
+test('an authenticated author also sees their own private themes', async () => {
+  setConfig({ JWT_SECRET: SECRET });
+  
+  expect(await search('', bearer('alice'))).toEqual(['alice-private', 'alice-public', 'bob-public']);
+  expect(await search('?isPublic=false', bearer('alice'))).toEqual(['alice-private']);
+  expect(await search('?authorId=bob', bearer('alice'))).toEqual(['bob-public']);
+  expect(await search('?ids=alice-private,bob-private', bearer('alice'))).toEqual(['alice-private']);
+});
+
+test('an invalid token is treated as anonymous', async () => {
+  setConfig({ JWT_SECRET: SECRET });
+  const forged = { Authorization: `Bearer ${signJwt({ sub: 'alice', exp: Math.floor(Date.now() / 1000) + 60 }, 'wrong-secret')}` };
+  
+  expect(await search('', forged)).toEqual(['alice-public', 'bob-public']);
+});
+
+test('an admin sees every theme', async () => {
+  setConfig({ API_KEY: ADMIN_KEY });
+  
+  expect(await search('', adminHeaders)).toEqual(['alice-private', 'alice-public', 'bob-private', 'bob-public']);
+  expect(await search('?isPublic=false', adminHeaders)).toEqual(['alice-private', 'bob-private']);
+});
+
+test('fetching or exporting a private theme is a 404 unless the viewer may see it', async () => {
+  setConfig({ JWT_SECRET: SECRET, API_KEY: ADMIN_KEY });
+  
+  for (const path of ['/api/themes/alice-private', '/api/themes/alice-private/export']) {
+    const anonymous = await request(path);
+    expect(anonymous.status).toBe(404);
+    expect((await anonymous.json() as any).error.code).toBe('THEME_NOT_FOUND');
+    expect((await request(path, { headers: bearer('bob') })).status).toBe(404);
+    
+    expect((await request(path, { headers: bearer('alice') })).status).toBe(200);
+    expect((await request(path, { headers: adminHeaders })).status).toBe(200);
+    expect((await request(path.replace('alice-private', 'bob-public'))).status).toBe(200);
+  }
+});
+
+test('a hidden theme answers 404 even to a matching If-None-Match', async () => {
+  setConfig({ JWT_SECRET: SECRET });
+  const etag = (await request('/api/themes/alice-private', { headers: bearer('alice') })).headers.get('etag')!;
+  
+  const response = await request('/api/themes/alice-private', { headers: { 'If-None-Match': etag } });
+  
+  expect(response.status).toBe(404);
+});
+
+test('author listings follow the same rule', async () => {
+  setConfig({ JWT_SECRET: SECRET, API_KEY: ADMIN_KEY });
+  const listed = async (headers: Record<string, string> = {}) => {
+    const response = await request('/api/authors/alice/themes', { headers });
+    expect(response.status).toBe(200);
+    return ((await response.json() as any).data.themes as Theme[]).map(theme => theme.id).sort();
+  };
+  
+  expect(await listed()).toEqual(['alice-public']);
+  expect(await listed(bearer('bob'))).toEqual(['alice-public']);
+  expect(await listed(bearer('alice'))).toEqual(['alice-private', 'alice-public']);
+  expect(await listed(adminHeaders)).toEqual(['alice-private', 'alice-public']);
+});
@@ -0,0 +1,61 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+// Start of a whole minute a little in the past, so buckets line up with it
+const minute = 60000;
+const base = Math.floor((Date.now() - 10 * minute) / minute) * minute;
+
+async function getTimeline(query: string): Promise<Response> {
+  return request(`/events/timeline?${query}`);
+}
+
+test('events are counted per bucket, oldest bucket first', async () => {
+  await insertEvents([
+    makeEvent({ timestamp: base }),
+    makeEvent({ timestamp: base + minute - 1 }),
+    makeEvent({ timestamp: base + minute }),
+    makeEvent({ timestamp: base + 3 * minute + 30000 })
+  ]);
+  
+  const response = await getTimeline('bucket=60');
+  
+  expect(response.status).toBe(200);
+  expect(await response.json()).toEqual([
+    { bucketStart: base, count: 2 },
+    { bucketStart: base + minute, count: 1 },
+    { bucketStart: base + 3 * minute, count: 1 }
+  ]);
+});
+
+test('since and until limit the range', async () => {
+  await insertEvents([
+    makeEvent({ timestamp: base }),
+    makeEvent({ timestamp: base + minute }),
+    makeEvent({ timestamp: base + 2 * minute })
+  ]);
+  
+  const response = await getTimeline(`bucket=60&since=${base + minute}&until=${base + minute + 1}`);
+  
+  expect(await response.json()).toEqual([{ bucketStart: base + minute, count: 1 }]);
+});
+
+test('an empty range returns no buckets', async () => {
+  await insertEvents([makeEvent({ timestamp: base })]);
+  
+  const response = await getTimeline(`bucket=60&since=${base + minute}&until=${base + 2 * minute}`);
+  
+  expect(response.status).toBe(200);
+  expect(await response.json()).toEqual([]);
+});
+
+test('non-positive bucket sizes are rejected', async () => {
+  for (const bucket of ['0', '-60', 'abc']) {
+    const response = await getTimeline(`bucket=${bucket}`);
+    expect(response.status).toBe(400);
+    expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+  }
+});
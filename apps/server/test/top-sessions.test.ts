@@ -0,0 +1,64 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+const MINUTE = 60 * 1000;
+let now: number;
+
+beforeEach(async () => {
+  resetDatabase();
+  now = Date.now();
+  const seed = (sessionId: string, sourceApp: string, count: number, ageMs: number) =>
+    Array.from({ length: count }, (_, i) => makeEvent({ session_id: sessionId, source_app: sourceApp, timestamp: now - ageMs + i }));
+  await insertEvents([
+    ...seed('busy', 'app-a', 5, 10 * MINUTE),
+    ...seed('steady', 'app-b', 3, 20 * MINUTE),
+    ...seed('quiet', 'app-a', 1, 5 * MINUTE),
+    // Busiest overall, but all before the default one-hour window
+    ...seed('yesterday', 'app-c', 8, 24 * 60 * MINUTE)
+  ]);
+});
+
+async function topSessions(query: string = ''): Promise<any[]> {
+  const response = await request(`/events/top-sessions${query}`);
+  expect(response.status).toBe(200);
+  return response.json() as Promise<any[]>;
+}
+
+test('sessions in the last hour are ranked by event count', async () => {
+  expect(await topSessions()).toEqual([
+    { session_id: 'busy', source_app: 'app-a', count: 5 },
+    { session_id: 'steady', source_app: 'app-b', count: 3 },
+    { session_id: 'quiet', source_app: 'app-a', count: 1 }
+  ]);
+});
+
+test('limit keeps only the busiest sessions', async () => {
+  const sessions = await topSessions('?limit=2');
+  
+  expect(sessions.map(session => session.session_id)).toEqual(['busy', 'steady']);
+});
+
+test('since widens or narrows the window', async () => {
+  const allDay = await topSessions(`?since=${now - 25 * 60 * MINUTE}`);
+  expect(allDay[0]).toEqual({ session_id: 'yesterday', source_app: 'app-c', count: 8 });
+  
+  const recent = await topSessions(`?since=${now - 15 * MINUTE}`);
+  expect(recent.map(session => session.session_id)).toEqual(['busy', 'quiet']);
+});
+
+test('equal counts are ordered by session id', async () => {
+  await insertEvents([makeEvent({ session_id: 'also-quiet', timestamp: now - MINUTE })]);
+  
+  const sessions = await topSessions();
+  
+  expect(sessions.slice(-2).map(session => session.session_id)).toEqual(['also-quiet', 'quiet']);
+});
+
+test('a malformed since is a 400', async () => {
+  const response = await request('/events/top-sessions?since=yesterday');
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.code).toBe('INVALID_PARAMETER');
+});
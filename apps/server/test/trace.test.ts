@@ -0,0 +1,82 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { makeEvent, resetDatabase } from './helpers';
+import { request } from './server';
+
+beforeEach(resetDatabase);
+
+const base = Date.now() - 10000;
+
+function at(offset: number, hook_event_type: string, payload: Record<string, any> = {}) {
+  return makeEvent({ session_id: 'trace-1', hook_event_type, payload, timestamp: base + offset });
+}
+
+async function getTrace(sessionId: string = 'trace-1') {
+  const response = await request(`/events/sessions/${sessionId}/trace`);
+  expect(response.status).toBe(200);
+  return (await response.json() as any).trace;
+}
+
+test('events between a tool call\'s pre and post nest under it', async () => {
+  await insertEvents([
+    at(0, 'UserPromptSubmit', { prompt: 'go' }),
+    at(10, 'PreToolUse', { tool_name: 'Bash' }),
+    at(20, 'Notification', { message: 'during bash' }),
+    at(30, 'PostToolUse', { tool_name: 'Bash' }),
+    at(40, 'Notification', { message: 'after bash' })
+  ]);
+  
+  const trace = await getTrace();
+  
+  expect(trace.map((node: any) => node.type)).toEqual(['event', 'tool_call', 'event']);
+  const call = trace[1];
+  expect(call.tool_name).toBe('Bash');
+  expect(call.start).toBe(base + 10);
+  expect(call.end).toBe(base + 30);
+  expect(call.children).toHaveLength(1);
+  expect(call.children[0].event.payload.message).toBe('during bash');
+  expect(trace[2].event.payload.message).toBe('after bash');
+});
+
+test('nested calls hold their own events and an unmatched call stays open', async () => {
+  await insertEvents([
+    at(0, 'PreToolUse', { tool_name: 'Task' }),
+    at(10, 'PreToolUse', { tool_name: 'Read' }),
+    at(20, 'Notification', { message: 'inside read' }),
+    at(30, 'PostToolUse', { tool_name: 'Read' }),
+    at(40, 'Notification', { message: 'inside task' }),
+    at(50, 'PostToolUse', { tool_name: 'Task' }),
+    at(60, 'PreToolUse', { tool_name: 'Write' }),
+    at(70, 'Notification', { message: 'inside write' })
+  ]);
+  
+  const [task, write] = await getTrace();
+  
+  expect(task.tool_name).toBe('Task');
+  expect(task.children.map((node: any) => node.type)).toEqual(['tool_call', 'event']);
+  expect(task.children[0].tool_name).toBe('Read');
+  expect(task.children[0].children[0].event.payload.message).toBe('inside read');
+  expect(task.children[1].event.payload.message).toBe('inside task');
+  
+  expect(write.tool_name).toBe('Write');
+  expect(write.end).toBeNull();
+  expect(write.children[0].event.payload.message).toBe('inside write');
+});
+
+test('a post without a matching pre is kept as a plain event', async () => {
+  await insertEvents([
+    at(0, 'PostToolUse', { tool_name: 'Bash' }),
+    at(10, 'Stop')
+  ]);
+  
+  const trace = await getTrace();
+  
+  expect(trace.map((node: any) => node.event.hook_event_type)).toEqual(['PostToolUse', 'Stop']);
+});
+
+test('an unknown session is a 404', async () => {
+  const response = await request('/events/sessions/no-such-session/trace');
+  
+  expect(response.status).toBe(404);
+  expect((await response.json() as any).error.code).toBe('SESSION_NOT_FOUND');
+});
@@ -0,0 +1,72 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { clientIp, isTrustedProxy } from '../src/proxy';
+import { ADMIN_KEY, adminHeaders, resetDatabase, setConfig } from './helpers';
+import { request, sleep, wsUrl } from './server';
+
+beforeEach(resetDatabase);
+
+function forwardedFor(value: string): Request {
+  return new Request('http://localhost/stream', { headers: { 'X-Forwarded-For': value } });
+}
+
+test('with no trusted proxies the peer address is used and the header ignored', () => {
+  expect(clientIp(forwardedFor('1.2.3.4'), '10.0.0.5', [])).toBe('10.0.0.5');
+});
+
+test('an untrusted peer cannot spoof its address', () => {
+  expect(clientIp(forwardedFor('1.2.3.4'), '203.0.113.9', ['10.0.0.0/8'])).toBe('203.0.113.9');
+});
+
+test('behind a trusted proxy the forwarded client address is used', () => {
+  expect(clientIp(forwardedFor('198.51.100.7'), '10.0.0.5', ['10.0.0.0/8'])).toBe('198.51.100.7');
+});
+
+test('the chain is read right to left, skipping trusted hops', () => {
+  // The client prepended a fake address; the proxies appended the real one
+  const req = forwardedFor('1.2.3.4, 198.51.100.7, 10.1.1.1');
+  
+  expect(clientIp(req, '10.0.0.5', ['10.0.0.0/8'])).toBe('198.51.100.7');
+});
+
+test('a chain of only trusted hops falls back to the leftmost', () => {
+  expect(clientIp(forwardedFor('10.2.2.2, 10.1.1.1'), '10.0.0.5', ['10.0.0.0/8'])).toBe('10.2.2.2');
+  expect(clientIp(forwardedFor(''), '10.0.0.5', ['10.0.0.0/8'])).toBe('10.0.0.5');
+});
+
+test('exact IPs, CIDR ranges and IPv4-mapped IPv6 addresses match', () => {
+  expect(isTrustedProxy('192.168.1.1', ['192.168.1.1'])).toBe(true);
+  expect(isTrustedProxy('192.168.1.2', ['192.168.1.1'])).toBe(false);
+  expect(isTrustedProxy('172.16.5.4', ['172.16.0.0/12'])).toBe(true);
+  expect(isTrustedProxy('172.32.0.1', ['172.16.0.0/12'])).toBe(false);
+  expect(isTrustedProxy('::ffff:10.0.0.1', ['10.0.0.0/8'])).toBe(true);
+  expect(clientIp(forwardedFor('x'), '::ffff:203.0.113.9', [])).toBe('203.0.113.9');
+});
+
+test('malformed ranges never match', () => {
+  expect(isTrustedProxy('10.0.0.1', ['10.0.0.0/33'])).toBe(false);
+  expect(isTrustedProxy('10.0.0.1', ['not-an-ip/8'])).toBe(false);
+});
+
+// The resolved address is what the admin client list reports
+async function connectedAddress(forwarded: string): Promise<string> {
+  const ws = new WebSocket(wsUrl(), { headers: { ...adminHeaders, 'X-Forwarded-For': forwarded } } as any);
+  const id = await new Promise<string>(resolve => ws.addEventListener('message', event => {
+    const message = JSON.parse(String(event.data));
+    if (message.type === 'connected') resolve(message.data.client_id);
+  }));
+  try {
+    const body = await (await request('/admin/ws/clients', { headers: adminHeaders })).json() as any;
+    return body.clients.find((client: any) => client.id === id).remoteAddress;
+  } finally {
+    ws.close();
+    await sleep(20);
+  }
+}
+
+test('the stream records the resolved address per configuration', async () => {
+  setConfig({ API_KEY: ADMIN_KEY, TRUSTED_PROXIES: [] });
+  expect(await connectedAddress('198.51.100.7')).toMatch(/127\.0\.0\.1|::1/);
+  
+  setConfig({ TRUSTED_PROXIES: ['127.0.0.1', '::1'] });
+  expect(await connectedAddress('198.51.100.7')).toBe('198.51.100.7');
+});
@@ -0,0 +1,66 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { countEvents } from '../src/db';
+import { makeEvent, resetDatabase, setConfig } from './helpers';
+import { connectClient, request, requestJson, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+async function validate(body: unknown, query: string = ''): Promise<{ status: number; body: any }> {
+  const response = await requestJson(`/events/validate${query}`, 'POST', body);
+  return { status: response.status, body: await response.json() };
+}
+
+test('a valid event is reported valid and nothing is stored or broadcast', async () => {
+  const client = await connectClient();
+  try {
+    const { status, body } = await validate(makeEvent({ session_id: 'validate-ok' }));
+    await sleep(50);
+    
+    expect(status).toBe(200);
+    expect(body.valid).toBe(true);
+    expect(body.validationErrors).toEqual([]);
+    expect(body.event.session_id).toBe('validate-ok');
+    expect(body.event.id).toBeUndefined();
+    expect(countEvents()).toBe(0);
+    expect(client.messages.some(message => message.type === 'event')).toBe(false);
+  } finally {
+    client.close();
+  }
+});
+
+test('an invalid event gets 200 with every validation error', async () => {
+  const { status, body } = await validate({ source_app: '', hook_event_type: 'PreToolUes', payload: [1, 2] });
+  
+  expect(status).toBe(200);
+  expect(body.valid).toBe(false);
+  expect(body.event).toBeUndefined();
+  expect(body.validationErrors.map((error: any) => [error.field, error.code])).toEqual([
+    ['source_app', 'INVALID_FORMAT'],
+    ['session_id', 'REQUIRED'],
+    ['hook_event_type', 'INVALID_ENUM'],
+    ['payload', 'INVALID_FORMAT']
+  ]);
+  expect(countEvents()).toBe(0);
+});
+
+test('allowUnknown=true accepts custom hook types, as on POST /events', async () => {
+  const { body } = await validate(makeEvent({ hook_event_type: 'CustomHook' }), '?allowUnknown=true');
+  
+  expect(body.valid).toBe(true);
+});
+
+test('the would-be event shows write-time redaction', async () => {
+  setConfig({ REDACT_KEYS: ['api_key'], REDACT_MODE: 'write' });
+  
+  const { body } = await validate(makeEvent({ payload: { api_key: 'sk-secret', command: 'ls' } }));
+  
+  expect(body.event.payload.command).toBe('ls');
+  expect(body.event.payload.api_key).not.toBe('sk-secret');
+});
+
+test('a body that is not JSON is a 400', async () => {
+  const response = await request('/events/validate', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: '{oops' });
+  
+  expect(response.status).toBe(400);
+  expect((await response.json() as any).error.code).toBe('INVALID_BODY');
+});
@@ -0,0 +1,117 @@
+import { afterAll, beforeEach, expect, test } from 'bun:test';
+import { CircuitBreaker } from '../src/breaker';
+import type { BreakerState } from '../src/breaker';
+import { resetDatabase, setConfig } from './helpers';
+import { postEvent, request, sleep } from './server';
+
+test('the breaker walks closed, open, half-open and back to closed', () => {
+  const changes: BreakerState[] = [];
+  const breaker = new CircuitBreaker(3, 1000, state => changes.push(state));
+  
+  // Below the threshold every call still goes through
+  breaker.recordFailure(0);
+  breaker.recordFailure(0);
+  expect(breaker.state).toBe('closed');
+  expect(breaker.allowRequest(0)).toBe(true);
+  
+  breaker.recordFailure(0);
+  expect(breaker.state).toBe('open');
+  expect(breaker.allowRequest(999)).toBe(false);
+  
+  // After the cooldown a single trial is let through
+  expect(breaker.allowRequest(1000)).toBe(true);
+  expect(breaker.state).toBe('half_open');
+  expect(breaker.allowRequest(1000)).toBe(false);
+  
+  breaker.recordSuccess();
+  expect(breaker.state).toBe('closed');
+  expect(breaker.allowRequest(1001)).toBe(true);
+  expect(changes).toEqual(['open', 'half_open', 'closed']);
+});
+
+test('a failed trial re-opens the breaker for another cooldown', () => {
+  const breaker = new CircuitBreaker(1, 1000);
+  
+  breaker.recordFailure(0);
+  expect(breaker.allowRequest(1000)).toBe(true);
+  breaker.recordFailure(1000);
+  
+  expect(breaker.state).toBe('open');
+  expect(breaker.allowRequest(1999)).toBe(false);
+  expect(breaker.allowRequest(2000)).toBe(true);
+});
+
+test('a success resets the consecutive failure count', () => {
+  const breaker = new CircuitBreaker(2, 1000);
+  
+  breaker.recordFailure(0);
+  breaker.recordSuccess();
+  breaker.recordFailure(0);
+  
+  expect(breaker.state).toBe('closed');
+});
+
+// Stand-in receiver that is down until a test brings it back; once healthy
+// it answers slowly enough to observe the half-open state
+let healthy = false;
+let hits = 0;
+const stub = Bun.serve({
+  port: 0,
+  async fetch() {
+    hits++;
+    if (!healthy) return new Response('', { status: 503 });
+    await sleep(100);
+    return new Response('ok');
+  }
+});
+
+afterAll(() => stub.stop(true));
+
+beforeEach(() => {
+  resetDatabase();
+  healthy = false;
+  hits = 0;
+});
+
+async function scrape(series: string): Promise<number> {
+  const line = (await (await request('/metrics')).text()).split('\n').find(line => line.startsWith(`${series} `));
+  return line ? Number(line.slice(series.length + 1)) : NaN;
+}
+
+const STATE = 'webhook_breaker_state{webhook="0"}';
+
+async function deliverOne(sessionId: string): Promise<void> {
+  await postEvent({ session_id: sessionId });
+  // Let the background delivery settle
+  await sleep(50);
+}
+
+test('deliveries drive the breaker and its state is exported as a metric', async () => {
+  setConfig({
+    EVENT_WEBHOOKS: [`http://localhost:${stub.port}/breaker`],
+    EVENT_WEBHOOK_RETRIES: 0,
+    EVENT_WEBHOOK_BREAKER_THRESHOLD: 2,
+    EVENT_WEBHOOK_BREAKER_COOLDOWN_MS: 200
+  });
+  const skippedBefore = await scrape('webhook_deliveries_total{result="skipped",webhook="0"}') || 0;
+  
+  await deliverOne('breaker-1');
+  expect(await scrape(STATE)).toBe(0);
+  await deliverOne('breaker-2');
+  expect(hits).toBe(2);
+  expect(await scrape(STATE)).toBe(2);
+  
+  // Open: the endpoint is not contacted and the event counts as skipped
+  await deliverOne('breaker-3');
+  expect(hits).toBe(2);
+  expect(await scrape('webhook_deliveries_total{result="skipped",webhook="0"}')).toBe(skippedBefore + 1);
+  
+  // After the cooldown the trial delivery succeeds and closes the breaker
+  await sleep(250);
+  healthy = true;
+  await postEvent({ session_id: 'breaker-4' });
+  expect(await scrape(STATE)).toBe(1);
+  await sleep(200);
+  expect(hits).toBe(3);
+  expect(await scrape(STATE)).toBe(0);
+});
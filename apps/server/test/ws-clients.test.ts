@@ -0,0 +1,75 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { insertEvents } from '../src/db';
+import { ADMIN_KEY, adminHeaders, makeEvent, resetDatabase, setConfig } from './helpers';
+import { connectClient, request, sleep } from './server';
+
+beforeEach(() => {
+  resetDatabase();
+  setConfig({ API_KEY: ADMIN_KEY });
+});
+
+async function listClients(): Promise<any> {
+  const response = await request('/admin/ws/clients', { headers: adminHeaders });
+  expect(response.status).toBe(200);
+  return response.json();
+}
+
+test('a connected client is listed with its metadata', async () => {
+  const before = Date.now();
+  const client = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  try {
+    const id = (await client.next('connected')).data.client_id;
+    await sleep(20);
+    
+    const body = await listClients();
+    const entry = body.clients.find((c: any) => c.id === id);
+    
+    expect(body.count).toBe(body.clients.length);
+    expect(entry.remoteAddress).toMatch(/127\.0\.0\.1|::1/);
+    expect(entry.connectedAt).toBeGreaterThanOrEqual(before);
+    expect(entry.connectedAt).toBeLessThanOrEqual(Date.now());
+    // connected and initial
+    expect(entry.framesSent).toBeGreaterThanOrEqual(2);
+    expect(entry.lastSeq).toBe(entry.framesSent);
+    expect(entry.subscribedSessions).toEqual([]);
+  } finally {
+    client.close();
+  }
+});
+
+test('a replaying client lists the session it is subscribed to', async () => {
+  const now = Date.now();
+  await insertEvents([
+    makeEvent({ session_id: 'ws-clients-replay', timestamp: now - 2000 }),
+    makeEvent({ session_id: 'ws-clients-replay', timestamp: now - 1000 })
+  ]);
+  const client = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  try {
+    const id = (await client.next('connected')).data.client_id;
+    // Slow enough that the replay is still running when we look
+    client.ws.send(JSON.stringify({ type: 'replay', session_id: 'ws-clients-replay', speed: 0.01 }));
+    await client.next('replay_started');
+    
+    const entry = (await listClients()).clients.find((c: any) => c.id === id);
+    expect(entry.subscribedSessions).toEqual(['ws-clients-replay']);
+    
+    client.ws.send(JSON.stringify({ type: 'replay_cancel' }));
+    await client.next('replay_cancelled');
+    expect((await listClients()).clients.find((c: any) => c.id === id).subscribedSessions).toEqual([]);
+  } finally {
+    client.close();
+  }
+});
+
+test('a closed client drops off the list', async () => {
+  const client = await connectClient(`/stream?token=${ADMIN_KEY}`);
+  const id = (await client.next('connected')).data.client_id;
+  client.close();
+  await sleep(50);
+  
+  expect((await listClients()).clients.some((c: any) => c.id === id)).toBe(false);
+});
+
+test('the list needs the admin key', async () => {
+  expect((await request('/admin/ws/clients')).status).toBe(401);
+});
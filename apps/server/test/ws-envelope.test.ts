@@ -0,0 +1,52 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { broadcast } from '../src/websocket';
+import { resetDatabase } from './helpers';
+import { connectClient, postEvent, sleep } from './server';
+
+beforeEach(resetDatabase);
+
+test('every frame to a client carries the next seq and a send time', async () => {
+  const client = await connectClient();
+  try {
+    const before = Date.now();
+    for (let i = 0; i < 3; i++) {
+      await postEvent({ session_id: 'envelope-seq' });
+    }
+    for (let i = 0; i < 3; i++) {
+      await client.next('event');
+    }
+    await sleep(50);
+    
+    const seqs = client.messages.map(message => message.seq);
+    expect(seqs).toEqual(seqs.map((_, i) => seqs[0] + i));
+    // The connected frame opens the numbering
+    expect(client.messages[0].type).toBe('connected');
+    expect(seqs[0]).toBe(1);
+    
+    for (const message of client.messages.filter(message => message.type === 'event')) {
+      expect(message.timestamp).toBeGreaterThanOrEqual(before);
+      expect(message.timestamp).toBeLessThanOrEqual(Date.now());
+    }
+  } finally {
+    client.close();
+  }
+});
+
+test('each connection numbers its frames independently', async () => {
+  const first = await connectClient();
+  await postEvent({ session_id: 'envelope-independent' });
+  await first.next('event');
+  const second = await connectClient();
+  try {
+    broadcast({ type: 'envelope_test', data: { n: 1 } });
+    const a = await first.next('envelope_test');
+    const b = await second.next('envelope_test');
+    
+    expect(a.seq).toBeGreaterThan(b.seq);
+    expect(b.seq).toBe(Math.max(...second.messages.map(message => message.seq)));
+    expect(a.data).toEqual({ n: 1 });
+  } finally {
+    first.close();
+    second.close();
+  }
+});
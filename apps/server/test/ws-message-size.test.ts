@@ -0,0 +1,37 @@
+import { beforeEach, expect, test } from 'bun:test';
+import { config } from '../src/config';
+import { resetDatabase } from './helpers';
+import { connectClient } from './server';
+
+beforeEach(resetDatabase);
+
+// A JSON command of exactly `size` bytes
+function commandOfSize(size: number): string {
+  const empty = JSON.stringify({ type: 'padding', pad: '' });
+  return JSON.stringify({ type: 'padding', pad: 'x'.repeat(size - empty.length) });
+}
+
+// The read limit is applied when the server starts, so these use its value
+test('a frame over WS_MAX_MESSAGE_BYTES closes the connection with 1009', async () => {
+  const client = await connectClient();
+  const closed = new Promise<CloseEvent>(resolve => client.ws.addEventListener('close', resolve));
+  
+  client.ws.send(commandOfSize(config.WS_MAX_MESSAGE_BYTES + 1));
+  const event = await closed;
+  
+  expect(event.code).toBe(1009);
+});
+
+test('a frame at the limit is read and answered', async () => {
+  const client = await connectClient();
+  try {
+    client.ws.send(commandOfSize(config.WS_MAX_MESSAGE_BYTES));
+    
+    // Unknown commands get an error frame on a still-open connection
+    const reply = await client.next('error');
+    expect(reply.data.code).toBe('INVALID_PARAMETER');
+    expect(client.ws.readyState).toBe(WebSocket.OPEN);
+  } finally {
+    client.close();
+  }
+});
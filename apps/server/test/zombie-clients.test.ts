@@ -0,0 +1,72 @@
+import { expect, test } from 'bun:test';
+import { getClientCount, sweepClients } from '../src/websocket';
+import { server } from '../src/index';
+import { setConfig } from './helpers';
+import { sleep } from './server';
+
+// A bare TCP peer that completes the WebSocket upgrade and then never
+// answers anything, like a client whose process died mid-connection
+async function connectRawPeer() {
+  let upgraded!: () => void;
+  const ready = new Promise<void>(resolve => (upgraded = resolve));
+  const socket = await Bun.connect({
+    hostname: 'localhost',
+    port: server.port,
+    socket: {
+      data(_socket, data) {
+        if (data.toString().startsWith('HTTP/1.1 101')) upgraded();
+      }
+    }
+  });
+  
+  const key = Buffer.from(crypto.getRandomValues(new Uint8Array(16))).toString('base64');
+  socket.write([
+    'GET /stream HTTP/1.1',
+    `Host: localhost:${server.port}`,
+    'Upgrade: websocket',
+    'Connection: Upgrade',
+    `Sec-WebSocket-Key: ${key}`,
+    'Sec-WebSocket-Version: 13',
+    '',
+    ''
+  ].join('\r\n'));
+  await ready;
+  // Let the server run its open handler
+  await sleep(50);
+  return socket;
+}
+
+// Clients from other test files may still be closing, so counts are
+// compared against the number connected before each test
+test('a peer that stops answering pings is dropped within the heartbeat window', async () => {
+  const interval = 50;
+  setConfig({ WS_HEARTBEAT_INTERVAL: interval });
+  const before = getClientCount();
+  const peer = await connectRawPeer();
+  try {
+    expect(getClientCount()).toBe(before + 1);
+    
+    // Drive the sweep the way the interval timer would
+    const deadline = Date.now() + interval * 4;
+    while (getClientCount() > before && Date.now() < deadline) {
+      sweepClients();
+      await sleep(interval / 2);
+    }
+    
+    expect(getClientCount()).toBe(before);
+  } finally {
+    peer.end();
+  }
+});
+
+test('a socket killed without a close handshake is removed', async () => {
+  const before = getClientCount();
+  const peer = await connectRawPeer();
+  expect(getClientCount()).toBe(before + 1);
+  
+  peer.terminate();
+  await sleep(100);
+  sweepClients();
+  
+  expect(getClientCount()).toBe(before);
+});